@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dotbinio/taskwarrior-api/internal/config"
+	"go.yaml.in/yaml/v3"
+)
+
+const configUsage = `Usage:
+  taskwarrior-api config check [flags]   Validate the configuration and print it with secrets redacted
+
+Run with -h after the subcommand to list its flags.
+`
+
+// runConfigCommand implements the config subcommand
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("config check", flag.ContinueOnError)
+	configFlags := config.RegisterFlags(flags)
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	cfg, err := config.Load(configFlags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	out, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to print configuration: %v\n", err)
+		return 1
+	}
+	fmt.Print(string(out))
+	return 0
+}
@@ -2,7 +2,8 @@ package main
 
 import (
 	"context"
-	"log"
+	"flag"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,9 +11,13 @@ import (
 	"time"
 
 	"github.com/dotbinio/taskwarrior-api/internal/api"
+	"github.com/dotbinio/taskwarrior-api/internal/api/middleware"
+	"github.com/dotbinio/taskwarrior-api/internal/audit"
 	"github.com/dotbinio/taskwarrior-api/internal/auth"
 	"github.com/dotbinio/taskwarrior-api/internal/config"
-	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/dotbinio/taskwarrior-api/internal/logging"
+	"github.com/dotbinio/taskwarrior-api/internal/tenant"
+	"github.com/dotbinio/taskwarrior-api/internal/tracing"
 )
 
 // @title           Taskwarrior API
@@ -32,25 +37,111 @@ import (
 // @name Authorization
 
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "token":
+			os.Exit(runTokenCommand(os.Args[2:]))
+		case "config":
+			os.Exit(runConfigCommand(os.Args[2:]))
+		}
+	}
+
 	// Load configuration
-	cfg, err := config.Load()
+	configFlags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	cfg, err := config.Load(configFlags)
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal("Failed to load configuration", err)
+	}
+	if err := logging.Setup(os.Stderr, cfg.Logging.Format, cfg.Logging.Level); err != nil {
+		fatal("Failed to set up logging", err)
 	}
 
-	log.Printf("Starting Taskwarrior API server...")
-	log.Printf("Data location: %s", cfg.Taskwarrior.DataLocation)
-	log.Printf("Taskrc location: %s", cfg.Taskwarrior.TaskrcLocation)
-	log.Printf("Server address: %s", cfg.GetAddress())
+	slog.Info("Starting Taskwarrior API server",
+		"data_location", cfg.Taskwarrior.DataLocation,
+		"taskrc_location", cfg.Taskwarrior.TaskrcLocation,
+		"address", cfg.GetAddress())
 
-	// Initialize Taskwarrior client
-	twClient := taskwarrior.NewClient(cfg.Taskwarrior.DataLocation, cfg.Taskwarrior.TaskrcLocation)
+	// Export spans of requests and Taskwarrior commands
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("Failed to set up tracing", err)
+	}
+	if cfg.Tracing.Exporter != tracing.ExporterNone {
+		slog.Info("Tracing enabled", "exporter", cfg.Tracing.Exporter, "sample_ratio", cfg.Tracing.SampleRatio)
+	}
+
+	// Initialize tenants, each with its own Taskwarrior client, event bus,
+	// change watcher and webhook dispatcher
+	registry, err := tenant.NewRegistry(cfg)
+	if err != nil {
+		fatal("Failed to initialize tenants", err)
+	}
+	defer registry.Close()
+	for _, t := range registry.Tenants() {
+		slog.Info("Serving tenant", "tenant", t.Name)
+	}
+	if cfg.Metrics.Enabled {
+		registry.RegisterMetrics()
+	}
+
+	// Open the token store for tokens managed through the API
+	var tokenStore *auth.TokenStore
+	if cfg.Auth.Store.Enabled {
+		tokenStore, err = auth.NewTokenStore(config.ExpandPath(cfg.Auth.Store.DataLocation))
+		if err != nil {
+			fatal("Failed to open token store", err)
+		}
+		defer tokenStore.Close()
+		slog.Info("Token store enabled", "data_location", cfg.Auth.Store.DataLocation)
+	}
+
+	// Open the audit log
+	var auditLog *audit.Log
+	if cfg.Audit.Enabled {
+		auditLog, err = audit.NewLog(config.ExpandPath(cfg.Audit.DataLocation), int64(cfg.Audit.MaxSize)<<20, cfg.Audit.MaxFiles)
+		if err != nil {
+			fatal("Failed to open audit log", err)
+		}
+		defer auditLog.Close()
+		slog.Info("Audit log enabled", "data_location", cfg.Audit.DataLocation)
+	}
 
 	// Initialize token validator
-	validator := auth.NewTokenValidator(cfg.Auth.Tokens)
+	validator := auth.NewTokenValidator(cfg, tokenStore)
+	if cfg.Auth.OIDC.Enabled() {
+		verifier, err := auth.NewJWTVerifier(cfg.Auth.OIDC)
+		if err != nil {
+			fatal("Failed to initialize OIDC", err)
+		}
+		validator.EnableJWT(verifier)
+		slog.Info("Accepting JWTs", "issuer", cfg.Auth.OIDC.Issuer, "audience", cfg.Auth.OIDC.Audience)
+	}
 
 	// Setup router
-	router := api.SetupRouter(cfg, twClient, validator)
+	corsMiddleware, err := middleware.NewCORS(cfg.CORS)
+	if err != nil {
+		fatal("Invalid CORS configuration", err)
+	}
+	router := api.SetupRouter(cfg, registry, validator, tokenStore, auditLog, corsMiddleware)
+
+	// Reload tokens, CORS and the log level on SIGHUP, and optionally when
+	// the configuration files change
+	reload := &reloader{flags: configFlags, validator: validator, cors: corsMiddleware, current: cfg}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			slog.Info("Reloading configuration")
+			reload.reload()
+		}
+	}()
+	stopWatch := make(chan struct{})
+	defer close(stopWatch)
+	if cfg.Server.ReloadInterval > 0 {
+		go reload.watch(cfg.Server.ReloadInterval, stopWatch)
+	}
 
 	// Create HTTP server
 	srv := &http.Server{
@@ -63,26 +154,56 @@ func main() {
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Server listening on %s", cfg.GetAddress())
+		slog.Info("Server listening", "address", cfg.GetAddress())
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
+			fatal("Failed to start server", err)
 		}
 	}()
 
+	// Serve metrics on their own address, so they can be kept off the
+	// network the API is exposed on
+	var metricsSrv *http.Server
+	if cfg.Metrics.Enabled && cfg.Metrics.Address != "" {
+		metricsSrv = &http.Server{
+			Addr:         cfg.Metrics.Address,
+			Handler:      api.SetupMetricsRouter(cfg),
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 15 * time.Second,
+		}
+		go func() {
+			slog.Info("Metrics listening", "address", cfg.Metrics.Address)
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal("Failed to start metrics server", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	slog.Info("Shutting down server")
 
 	// Graceful shutdown with 5 second timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		fatal("Server forced to shutdown", err)
 	}
+	if metricsSrv != nil {
+		metricsSrv.Shutdown(ctx)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Failed to flush traces", "error", err)
+	}
+
+	slog.Info("Server stopped")
+}
 
-	log.Println("Server stopped")
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
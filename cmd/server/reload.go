@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/api/middleware"
+	"github.com/dotbinio/taskwarrior-api/internal/auth"
+	"github.com/dotbinio/taskwarrior-api/internal/config"
+	"github.com/dotbinio/taskwarrior-api/internal/logging"
+)
+
+// reloader applies configuration changes while the server is running.
+// Only tokens, CORS and the log level change; other settings are kept
+// until the next restart.
+type reloader struct {
+	flags     *config.Flags
+	validator *auth.TokenValidator
+	cors      *middleware.CORS
+
+	mu      sync.Mutex
+	current *config.Config
+}
+
+// reload loads the configuration again. An invalid configuration is
+// logged and the last good one stays in use.
+func (r *reloader) reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loaded, err := config.Load(r.flags)
+	if err != nil {
+		slog.Error("Keeping current configuration", "error", err)
+		return
+	}
+	next, restart, err := r.current.Reload(loaded)
+	if err != nil {
+		slog.Error("Keeping current configuration", "error", err)
+		return
+	}
+	if err := r.cors.Update(next.CORS); err != nil {
+		slog.Error("Keeping current configuration", "error", fmt.Errorf("cors: %w", err))
+		return
+	}
+
+	r.validator.Reload(next)
+	logging.SetLevel(next.Logging.Level)
+	r.current = next
+
+	slog.Info("Configuration reloaded")
+	if len(restart) > 0 {
+		slog.Warn("Some changes take effect after a restart", "keys", strings.Join(restart, ", "))
+	}
+}
+
+// watch reloads the configuration whenever the configuration, tokens or
+// tenants file changes, until stop is closed
+func (r *reloader) watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := r.fileStates()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			states := r.fileStates()
+			if states != last {
+				last = states
+				r.reload()
+			}
+		}
+	}
+}
+
+// fileStates describes the size and modification time of the watched
+// files, so that any change yields a different string
+func (r *reloader) fileStates() string {
+	r.mu.Lock()
+	files := []string{r.flags.File(), r.current.Auth.TokensFile, r.current.Auth.TenantsFile}
+	r.mu.Unlock()
+
+	var states strings.Builder
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		states.WriteString(file)
+		if info, err := os.Stat(config.ExpandPath(file)); err == nil {
+			states.WriteString(info.ModTime().String())
+			states.WriteString(strconv.FormatInt(info.Size(), 10))
+		}
+		states.WriteString("\n")
+	}
+	return states.String()
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/auth"
+	"go.yaml.in/yaml/v3"
+)
+
+// tokenFileEntry is the tokens file entry printed for a generated token
+type tokenFileEntry struct {
+	Name      string     `yaml:"name,omitempty"`
+	ID        string     `yaml:"id,omitempty"`
+	Hash      string     `yaml:"hash"`
+	Scope     string     `yaml:"scope,omitempty"`
+	Project   string     `yaml:"project,omitempty"`
+	Tags      []string   `yaml:"tags,omitempty,flow"`
+	ExpiresAt *time.Time `yaml:"expires_at,omitempty"`
+}
+
+const tokenUsage = `Usage:
+  taskwarrior-api token generate [flags]   Generate a new token and print its hash
+  taskwarrior-api token hash [flags]       Hash an existing token read from stdin
+
+Run with -h after the subcommand to list its flags.
+`
+
+// runTokenCommand implements the token subcommand
+func runTokenCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, tokenUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "generate":
+		return runTokenGenerate(args[1:])
+	case "hash":
+		return runTokenHash(args[1:])
+	default:
+		fmt.Fprint(os.Stderr, tokenUsage)
+		return 2
+	}
+}
+
+func runTokenGenerate(args []string) int {
+	flags := flag.NewFlagSet("token generate", flag.ContinueOnError)
+	name := flags.String("name", "", "name identifying the token")
+	scope := flags.String("scope", "admin", "scope: read, write or admin")
+	project := flags.String("project", "", "restrict the token to this project")
+	tags := flags.String("tags", "", "restrict the token to tasks with these comma-separated tags")
+	expires := flags.String("expires", "", "lifetime of the token, e.g. 90d or 720h (never expires if empty)")
+	algorithm := flags.String("algorithm", auth.AlgorithmArgon2id, "hash algorithm: argon2id or bcrypt")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	switch auth.Scope(*scope) {
+	case auth.ScopeRead, auth.ScopeWrite, auth.ScopeAdmin:
+	default:
+		fmt.Fprintf(os.Stderr, "invalid scope: %s\n", *scope)
+		return 2
+	}
+
+	entry := tokenFileEntry{
+		Name:    *name,
+		Scope:   *scope,
+		Project: *project,
+	}
+	if *tags != "" {
+		entry.Tags = strings.Split(*tags, ",")
+	}
+	if *expires != "" {
+		lifetime, err := parseLifetime(*expires)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid expiry: %v\n", err)
+			return 2
+		}
+		expiresAt := time.Now().Add(lifetime).UTC().Truncate(time.Second)
+		entry.ExpiresAt = &expiresAt
+	}
+
+	id, token, err := auth.GenerateToken()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to generate token: %v\n", err)
+		return 1
+	}
+	entry.ID = id
+
+	hash, err := auth.HashToken(token, *algorithm)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to hash token: %v\n", err)
+		return 1
+	}
+	entry.Hash = hash
+
+	out, err := yaml.Marshal([]tokenFileEntry{entry})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode token entry: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Token (shown only once, give it to the client):\n\n  %s\n\n", token)
+	fmt.Printf("Add this entry to the tokens list of TW_API_TOKENS_FILE:\n\n%s", out)
+	return 0
+}
+
+func runTokenHash(args []string) int {
+	flags := flag.NewFlagSet("token hash", flag.ContinueOnError)
+	algorithm := flags.String("algorithm", auth.AlgorithmArgon2id, "hash algorithm: argon2id or bcrypt")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Read the token from stdin so it does not show up in process listings
+	// or shell history
+	scanner := bufio.NewScanner(os.Stdin)
+	if !scanner.Scan() {
+		fmt.Fprintln(os.Stderr, "no token given on stdin")
+		return 2
+	}
+	token := strings.TrimSpace(scanner.Text())
+	if token == "" {
+		fmt.Fprintln(os.Stderr, "no token given on stdin")
+		return 2
+	}
+
+	hash, err := auth.HashToken(token, *algorithm)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to hash token: %v\n", err)
+		return 1
+	}
+
+	fmt.Println(hash)
+	return 0
+}
+
+// parseLifetime parses a Go duration, additionally accepting a number of
+// days such as 90d
+func parseLifetime(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid number of days: %s", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("lifetime must be positive: %s", s)
+	}
+	return d, nil
+}
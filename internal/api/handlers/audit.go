@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/audit"
+	"github.com/dotbinio/taskwarrior-api/internal/auth"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler handles audit log requests
+type AuditHandler struct {
+	log *audit.Log
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(log *audit.Log) *AuditHandler {
+	return &AuditHandler{log: log}
+}
+
+// ListEntries handles GET /api/v1/audit
+// @Summary      Audit log
+// @Description  Requests that changed tasks, newest first
+// @Tags         audit
+// @Produce      json
+// @Param        task   query  string  false  "Task UUID"
+// @Param        token  query  string  false  "Token name"
+// @Param        since  query  string  false  "Start of the time range (RFC 3339, inclusive)"
+// @Param        until  query  string  false  "End of the time range (RFC 3339, exclusive)"
+// @Param        limit  query  int     false  "Maximum number of entries (default 100)"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /audit [get]
+func (h *AuditHandler) ListEntries(c *gin.Context) {
+	filter := audit.Filter{
+		Tenant:   auth.IdentityFromContext(c).Tenant,
+		TaskUUID: c.Query("task"),
+		Token:    c.Query("token"),
+	}
+
+	if filter.TaskUUID != "" && !taskwarrior.ValidateTaskUUID(filter.TaskUUID) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid task UUID format",
+			"code":  "INVALID_UUID",
+		})
+		return
+	}
+
+	var ok bool
+	if filter.Since, ok = timeQuery(c, "since"); !ok {
+		return
+	}
+	if filter.Until, ok = timeQuery(c, "until"); !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "limit must be a positive integer",
+			"code":  "INVALID_LIMIT",
+		})
+		return
+	}
+	filter.Limit = limit
+
+	entries, err := h.log.Query(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to read audit log",
+			"code":  "AUDIT_READ_FAILED",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// timeQuery parses an optional RFC 3339 query parameter, answering 400 Bad
+// Request if it is malformed
+func timeQuery(c *gin.Context, param string) (time.Time, bool) {
+	value := c.Query(param)
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": param + " must be an RFC 3339 time",
+			"code":  "INVALID_TIME",
+		})
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/logging"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/gin-gonic/gin"
+)
+
+// Change types returned by the delta sync endpoint
+const (
+	ChangeCreated  = "created"
+	ChangeModified = "modified"
+	ChangeDeleted  = "deleted"
+	// ChangeRemoved is a task that changed outside a restricted token's
+	// scope. Only its UUID and modification time are returned.
+	ChangeRemoved = "removed"
+)
+
+// maxCursorSeen caps the fingerprints kept in a cursor. Tasks beyond it
+// are returned again until the cursor moves past their second.
+const maxCursorSeen = 100
+
+// TaskChange is a single entry in a delta sync response
+type TaskChange struct {
+	Type string           `json:"type"`
+	Task taskwarrior.Task `json:"task"`
+}
+
+// syncCursor marks a position in the task modification history. Tasks
+// modified in the same second as the cursor are fingerprinted so they are
+// not returned again unless they change, up to maxCursorSeen.
+type syncCursor struct {
+	Time int64    `json:"t"`
+	Seen []string `json:"s,omitempty"`
+}
+
+// ChangesHandler handles delta sync requests
+type ChangesHandler struct{}
+
+// NewChangesHandler creates a new changes handler
+func NewChangesHandler() *ChangesHandler {
+	return &ChangesHandler{}
+}
+
+// GetChanges handles GET /api/v1/changes
+// @Summary      Delta sync
+// @Description  Tasks created, modified, deleted or removed from a restricted token's scope since a cursor. Omit the cursor for a full snapshot of non-deleted tasks. Every response contains the cursor to use for the next call.
+// @Tags         sync
+// @Produce      json
+// @Param        since  query  string  false  "Cursor from a previous response"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /changes [get]
+func (h *ChangesHandler) GetChanges(c *gin.Context) {
+	client := clientFor(c)
+
+	var cursor *syncCursor
+	if since := c.Query("since"); since != "" {
+		decoded, err := decodeCursor(since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid sync cursor",
+				"code":  "INVALID_CURSOR",
+			})
+			return
+		}
+		cursor = decoded
+	}
+
+	restriction := restrictionFor(c)
+
+	// Taken before the export, so nothing written during it is skipped
+	now := time.Now()
+	var tasks []taskwarrior.Task
+	var err error
+	if cursor == nil {
+		tasks, err = client.Export("status.not:deleted")
+	} else {
+		tasks, err = client.ExportModifiedSince(time.Unix(cursor.Time, 0))
+	}
+	if err != nil {
+		logging.FromContext(c).Error("Failed to retrieve changes", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to retrieve changes",
+			"code":  "CHANGES_FAILED",
+		})
+		return
+	}
+
+	changes := make([]TaskChange, 0, len(tasks))
+	for _, task := range tasks {
+		if cursor != nil && modifiedUnix(task) == cursor.Time && slices.Contains(cursor.Seen, taskFingerprint(task)) {
+			continue
+		}
+		if !restriction.IsZero() && !restriction.Allows(task) {
+			// The task may have left the token's scope; the snapshot
+			// only lists tasks within it
+			if cursor != nil {
+				changes = append(changes, TaskChange{
+					Type: ChangeRemoved,
+					Task: taskwarrior.Task{UUID: task.UUID, Modified: task.Modified},
+				})
+			}
+			continue
+		}
+		changes = append(changes, TaskChange{
+			Type: changeType(task, cursor),
+			Task: task,
+		})
+	}
+
+	// Oldest first, so clients can apply changes in order
+	sort.SliceStable(changes, func(i, j int) bool {
+		return modifiedUnix(changes[i].Task) < modifiedUnix(changes[j].Task)
+	})
+
+	next, err := encodeCursor(nextCursor(cursor, tasks, now))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to encode sync cursor",
+			"code":  "CHANGES_FAILED",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"changes": changes,
+		"count":   len(changes),
+		"cursor":  next,
+	})
+}
+
+// nextCursor advances the cursor to the newest modification time seen, or
+// to now, the time the export started, if that is later. tasks are all the
+// tasks exported for the previous cursor, which includes every task
+// modified at or after it.
+func nextCursor(previous *syncCursor, tasks []taskwarrior.Task, now time.Time) syncCursor {
+	next := syncCursor{Time: now.Unix()}
+	if previous != nil && previous.Time > next.Time {
+		next.Time = previous.Time
+	}
+	for _, task := range tasks {
+		next.Time = max(next.Time, modifiedUnix(task))
+	}
+
+	for _, task := range tasks {
+		if modifiedUnix(task) != next.Time || len(next.Seen) == maxCursorSeen {
+			continue
+		}
+		if fp := taskFingerprint(task); !slices.Contains(next.Seen, fp) {
+			next.Seen = append(next.Seen, fp)
+		}
+	}
+
+	return next
+}
+
+// changeType classifies a task relative to the cursor
+func changeType(task taskwarrior.Task, cursor *syncCursor) string {
+	if task.Status == taskwarrior.StatusDeleted {
+		return ChangeDeleted
+	}
+	if cursor == nil || task.Entry == nil || task.Entry.Unix() >= cursor.Time {
+		return ChangeCreated
+	}
+	return ChangeModified
+}
+
+func modifiedUnix(task taskwarrior.Task) int64 {
+	if task.Modified != nil {
+		return task.Modified.Unix()
+	}
+	if task.Entry != nil {
+		return task.Entry.Unix()
+	}
+	return 0
+}
+
+// taskFingerprint identifies a task together with its current contents
+func taskFingerprint(task taskwarrior.Task) string {
+	return task.UUID + ":" + hex.EncodeToString(taskContentHash(task)[:4])
+}
+
+func encodeCursor(cursor syncCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(s string) (*syncCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor syncCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.Time <= 0 {
+		return nil, errors.New("cursor time must be positive")
+	}
+
+	return &cursor, nil
+}
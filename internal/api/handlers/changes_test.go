@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/tasktest"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+type changesResponse struct {
+	Changes []TaskChange `json:"changes"`
+	Cursor  string       `json:"cursor"`
+}
+
+func getChanges(t *testing.T, fake *tasktest.Fake, restriction taskwarrior.Restriction, since string) changesResponse {
+	t.Helper()
+
+	router := newRouter(fake, events.NewBus(0), restriction)
+	router.GET("/changes", NewChangesHandler().GetChanges)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/changes?"+url.Values{"since": {since}}.Encode(), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var response changesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func changeSummary(changes []TaskChange) []string {
+	summary := make([]string, len(changes))
+	for i, change := range changes {
+		summary[i] = change.Type + " " + change.Task.UUID
+	}
+	return summary
+}
+
+// A task that moves out of a restricted token's scope is reported as
+// removed, with nothing but its UUID
+func TestChangesRemovedFromScope(t *testing.T) {
+	fake := tasktest.Install(t, []map[string]any{
+		{"uuid": existingUUID, "description": "Write docs", "status": "pending", "project": "work", "entry": "20260101T090000Z", "modified": "20260101T090000Z"},
+		{"uuid": skippedUUID, "description": "Water plants", "status": "pending", "project": "home", "entry": "20260101T090000Z", "modified": "20260101T090000Z"},
+	})
+	restriction := taskwarrior.Restriction{Project: "work"}
+
+	snapshot := getChanges(t, fake, restriction, "")
+	if got := fmt.Sprint(changeSummary(snapshot.Changes)); got != "[created "+existingUUID+"]" {
+		t.Fatalf("snapshot %s", got)
+	}
+
+	home := "home"
+	client := taskwarrior.NewClient(fake.DataLocation(), "")
+	if err := client.Modify(existingUUID, taskwarrior.TaskModify{Project: &home}); err != nil {
+		t.Fatal(err)
+	}
+
+	delta := getChanges(t, fake, restriction, snapshot.Cursor)
+	if got := fmt.Sprint(changeSummary(delta.Changes)); got != "[removed "+existingUUID+"]" {
+		t.Fatalf("delta %s", got)
+	}
+	if task := delta.Changes[0].Task; task.Description != "" || task.Project != "" {
+		t.Errorf("removed task shows %+v", task)
+	}
+
+	if again := getChanges(t, fake, restriction, delta.Cursor); len(again.Changes) != 0 {
+		t.Errorf("removal reported again: %v", changeSummary(again.Changes))
+	}
+}
+
+func TestNextCursor(t *testing.T) {
+	now := time.Unix(1767261600, 0)
+	at := func(uuid string, unix int64) taskwarrior.Task {
+		modified := taskwarrior.TaskwarriorTime{Time: time.Unix(unix, 0)}
+		return taskwarrior.Task{UUID: uuid, Modified: &modified}
+	}
+
+	// Tasks older than the start of the export leave the cursor there
+	next := nextCursor(&syncCursor{Time: now.Unix() - 60}, []taskwarrior.Task{at(existingUUID, now.Unix()-30)}, now)
+	if next.Time != now.Unix() || len(next.Seen) != 0 {
+		t.Errorf("cursor %+v, want %d without fingerprints", next, now.Unix())
+	}
+
+	// Tasks modified since are fingerprinted at the newest second
+	tasks := []taskwarrior.Task{at(existingUUID, now.Unix()), at(newUUID, now.Unix()+1), at(skippedUUID, now.Unix()+1)}
+	next = nextCursor(nil, tasks, now)
+	if next.Time != now.Unix()+1 || len(next.Seen) != 2 {
+		t.Errorf("cursor %+v, want %d with 2 fingerprints", next, now.Unix()+1)
+	}
+
+	// A previous cursor ahead of the clock is kept
+	next = nextCursor(&syncCursor{Time: now.Unix() + 60}, nil, now)
+	if next.Time != now.Unix()+60 {
+		t.Errorf("cursor %+v moved back", next)
+	}
+
+	// The fingerprints are capped
+	tasks = tasks[:0]
+	for i := range maxCursorSeen + 10 {
+		tasks = append(tasks, at(fmt.Sprintf("%08d-0000-4000-8000-000000000000", i), now.Unix()))
+	}
+	if next = nextCursor(nil, tasks, now); len(next.Seen) != maxCursorSeen {
+		t.Errorf("%d fingerprints, want %d", len(next.Seen), maxCursorSeen)
+	}
+}
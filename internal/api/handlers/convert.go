@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/dotbinio/taskwarrior-api/internal/importers"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/gin-gonic/gin"
+)
+
+// ConvertedRecord is the mapping report entry of one source line
+type ConvertedRecord struct {
+	importers.Record
+	// Result is the import outcome: created, updated, skipped or failed
+	Result string `json:"result"`
+}
+
+// ImportTodoTxt handles POST /api/v1/import/todotxt
+// @Summary      Import todo.txt
+// @Description  Converts a todo.txt file and imports it. Every line is reported with the task it became. With preview=true nothing is written.
+// @Tags         import
+// @Accept       plain
+// @Produce      json
+// @Param        preview  query  bool  false  "Only convert and validate"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      413  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /import/todotxt [post]
+func (h *ImportHandler) ImportTodoTxt(c *gin.Context) {
+	records, err := importers.ParseTodoTxt(importBody(c))
+	if !checkImportPayload(c, err, len(records)) {
+		return
+	}
+	h.importRecords(c, records)
+}
+
+// ImportCSV handles POST /api/v1/import/csv
+// @Summary      Import CSV
+// @Description  Converts a CSV file using the column mapping in the query and imports it. Columns are given by header name or 1-based number. Every row is reported with the task it became. With preview=true nothing is written.
+// @Tags         import
+// @Accept       plain
+// @Produce      json
+// @Param        description    query  string  true   "Description column"
+// @Param        project        query  string  false  "Project column"
+// @Param        tags           query  string  false  "Tags column"
+// @Param        priority       query  string  false  "Priority column (H/M/L, high/medium/low or A/B/C)"
+// @Param        due            query  string  false  "Due date column"
+// @Param        wait           query  string  false  "Wait date column"
+// @Param        scheduled      query  string  false  "Scheduled date column"
+// @Param        status         query  string  false  "Completion column (done, completed, x, yes, true or 1)"
+// @Param        entry          query  string  false  "Creation date column"
+// @Param        end            query  string  false  "Completion date column"
+// @Param        tag_separator  query  string  false  "Separator within the tags column (default ,)"
+// @Param        date_format    query  string  false  "Go time layout of dates, tried before RFC 3339 and 2006-01-02"
+// @Param        delimiter      query  string  false  "Column delimiter (default ,; tab for tabs)"
+// @Param        header         query  bool    false  "Whether the first row names the columns (default true)"
+// @Param        preview        query  bool    false  "Only convert and validate"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      413  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /import/csv [post]
+func (h *ImportHandler) ImportCSV(c *gin.Context) {
+	mapping := importers.CSVMapping{
+		Description:  c.Query("description"),
+		Project:      c.Query("project"),
+		Tags:         c.Query("tags"),
+		Priority:     c.Query("priority"),
+		Due:          c.Query("due"),
+		Wait:         c.Query("wait"),
+		Scheduled:    c.Query("scheduled"),
+		Status:       c.Query("status"),
+		Entry:        c.Query("entry"),
+		End:          c.Query("end"),
+		TagSeparator: c.Query("tag_separator"),
+		DateFormat:   c.Query("date_format"),
+	}
+	if header, err := strconv.ParseBool(c.DefaultQuery("header", "true")); err == nil {
+		mapping.NoHeader = !header
+	}
+	switch delimiter := c.Query("delimiter"); {
+	case delimiter == "tab":
+		mapping.Delimiter = '\t'
+	case utf8.RuneCountInString(delimiter) == 1:
+		mapping.Delimiter, _ = utf8.DecodeRuneInString(delimiter)
+	case delimiter != "":
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "delimiter must be a single character or tab",
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	records, err := importers.ParseCSV(importBody(c), mapping)
+	if !checkImportPayload(c, err, len(records)) {
+		return
+	}
+	h.importRecords(c, records)
+}
+
+// importRecords imports the converted records and reports the outcome of
+// every source line
+func (h *ImportHandler) importRecords(c *gin.Context, records []importers.Record) {
+	preview, _ := strconv.ParseBool(c.Query("preview"))
+
+	var items []json.RawMessage
+	var indexes []int
+	for i, record := range records {
+		if record.Error == "" {
+			items = append(items, record.ImportItem())
+			indexes = append(indexes, i)
+		}
+	}
+
+	var results []taskwarrior.ImportResult
+	afterStateMissing := false
+	if len(items) > 0 {
+		var ok bool
+		if results, afterStateMissing, ok = h.runImport(c, items, preview); !ok {
+			return
+		}
+	}
+
+	// Lines that could not be converted have failed
+	report := make([]ConvertedRecord, len(records))
+	for i, record := range records {
+		report[i] = ConvertedRecord{Record: record, Result: taskwarrior.ImportFailed}
+	}
+	for j, result := range results {
+		i := indexes[j]
+		report[i].Result = result.Status
+		if result.Error != "" {
+			report[i].Error = result.Error
+		}
+	}
+
+	counts := importCounts(nil)
+	for _, record := range report {
+		counts[record.Result]++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preview":             preview,
+		"records":             report,
+		"counts":              counts,
+		"after_state_missing": afterStateMissing,
+	})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/gin-gonic/gin"
+)
+
+// taskContentHash hashes the contents of a task. The working-set ID and
+// urgency change without the task being modified, so they are left out.
+// The modification time is added explicitly because dates are serialized
+// without their time of day.
+func taskContentHash(task taskwarrior.Task) []byte {
+	task.ID = 0
+	task.Urgency = 0
+	data, _ := json.Marshal(task)
+
+	h := sha256.New()
+	h.Write(data)
+	if task.Modified != nil {
+		fmt.Fprintf(h, "|%d", task.Modified.Unix())
+	}
+	return h.Sum(nil)
+}
+
+// taskETag returns a strong ETag for a task
+func taskETag(task *taskwarrior.Task) string {
+	return `"` + hex.EncodeToString(taskContentHash(*task)[:16]) + `"`
+}
+
+// tasksETag returns a strong ETag for a list of tasks
+func tasksETag(tasks []taskwarrior.Task) string {
+	h := sha256.New()
+	for _, task := range tasks {
+		h.Write(taskContentHash(task))
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches checks whether an If-Match or If-None-Match header value
+// contains etag. Weak comparison is used, as RFC 9110 requires for
+// If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the ETag header and answers 304 Not Modified when the
+// request's If-None-Match matches it
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+
+	if match := c.GetHeader("If-None-Match"); match != "" && etagMatches(match, etag) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+
+	return false
+}
+
+// preconditionFailed evaluates If-Match against the current task and
+// answers 412 Precondition Failed, including the current task, when it
+// does not match
+func preconditionFailed(c *gin.Context, current *taskwarrior.Task) bool {
+	match := c.GetHeader("If-Match")
+	if match == "" {
+		return false
+	}
+
+	if current != nil {
+		etag := taskETag(current)
+		// If-Match requires strong comparison, so weak tags never match
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || candidate == etag {
+				return false
+			}
+		}
+		c.Header("ETag", etag)
+	}
+
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"error": "task has been modified since it was retrieved",
+		"code":  "PRECONDITION_FAILED",
+		"task":  current,
+	})
+	return true
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// sseBufferSize is the number of events buffered per connection before
+	// a slow client is disconnected and has to resume with Last-Event-ID
+	sseBufferSize = 256
+	// sseHeartbeat is how often a comment is sent to keep proxies from
+	// closing idle connections
+	sseHeartbeat = 15 * time.Second
+)
+
+// EventHandler handles the task change stream
+type EventHandler struct{}
+
+// NewEventHandler creates a new event handler
+func NewEventHandler() *EventHandler {
+	return &EventHandler{}
+}
+
+// eventFilter restricts a stream to matching events
+type eventFilter struct {
+	project     string
+	tags        []string
+	types       []events.Type
+	restriction taskwarrior.Restriction
+}
+
+func (f eventFilter) matches(event events.Event) bool {
+	if len(f.types) > 0 && !slices.Contains(f.types, event.Type) {
+		return false
+	}
+
+	// A change is relevant if the task matched before or after it, so that
+	// clients also learn about tasks leaving their view
+	return f.matchesTask(event.Before) || f.matchesTask(event.After)
+}
+
+// scoped returns event without the task states outside the token's
+// restriction, so a task that leaves the scope is reported without what
+// it became, and one that enters it without what it was
+func (f eventFilter) scoped(event events.Event) events.Event {
+	if event.Before != nil && !f.restriction.Allows(*event.Before) {
+		event.Before = nil
+	}
+	if event.After != nil && !f.restriction.Allows(*event.After) {
+		event.After = nil
+	}
+	return event
+}
+
+func (f eventFilter) matchesTask(task *taskwarrior.Task) bool {
+	if task == nil || !f.restriction.Allows(*task) {
+		return false
+	}
+
+	if f.project != "" && task.Project != f.project && !strings.HasPrefix(task.Project, f.project+".") {
+		return false
+	}
+
+	for _, tag := range f.tags {
+		if !slices.Contains(task.Tags, tag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Stream handles GET /api/v1/events
+// @Summary      Stream task changes
+// @Description  Server-Sent Events stream of task changes made through the API, the task CLI or sync. Resume with the Last-Event-ID header.
+// @Tags         events
+// @Produce      text/event-stream
+// @Param        project        query   string    false  "Only changes to tasks in this project (including subprojects)"
+// @Param        tags           query   []string  false  "Only changes to tasks with all of these tags"
+// @Param        types          query   []string  false  "Only these event types"
+// @Param        Last-Event-ID  header  string    false  "Resume after this event ID"
+// @Success      200  {string}  string  "event stream"
+// @Failure      400  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /events [get]
+func (h *EventHandler) Stream(c *gin.Context) {
+	bus := busFor(c)
+
+	filter := eventFilter{
+		project:     c.Query("project"),
+		tags:        c.QueryArray("tags"),
+		restriction: restrictionFor(c),
+	}
+	for _, t := range c.QueryArray("types") {
+		eventType := events.Type(t)
+		if !events.IsValidType(eventType) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "unknown event type",
+				"code":  "INVALID_EVENT_TYPE",
+			})
+			return
+		}
+		filter.types = append(filter.types, eventType)
+	}
+
+	// EventSource cannot set headers on the first request, so the resume
+	// position may also be passed as a query parameter
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid Last-Event-ID",
+				"code":  "INVALID_EVENT_ID",
+			})
+			return
+		}
+		lastID = id
+	}
+
+	// Subscribe before replaying so no event falls between the two
+	live := make(chan events.Event, sseBufferSize)
+	overflow := make(chan struct{})
+	overflowed := false
+	unsubscribe := bus.Subscribe(func(event events.Event) {
+		if overflowed {
+			return
+		}
+		select {
+		case live <- event:
+		default:
+			overflowed = true
+			close(overflow)
+		}
+	})
+	defer unsubscribe()
+
+	// Streams outlive the server write timeout
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	fmt.Fprint(c.Writer, "retry: 3000\n\n")
+
+	if lastEventID != "" {
+		missed, latest, complete := bus.Since(lastID)
+		if !complete {
+			// Too much happened since the client was last connected, or
+			// its ID is not one of ours; tell it to reload its state
+			// before continuing
+			fmt.Fprint(c.Writer, "event: resync\ndata: {}\n\n")
+		}
+		for _, event := range missed {
+			if filter.matches(event) {
+				writeSSE(c, filter.scoped(event))
+			}
+		}
+		// Continue after the latest event, not after an ID from the
+		// future that would hide every live event
+		lastID = latest
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-overflow:
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+			c.Writer.Flush()
+		case event := <-live:
+			if event.ID <= lastID {
+				continue
+			}
+			lastID = event.ID
+			if filter.matches(event) {
+				writeSSE(c, filter.scoped(event))
+				c.Writer.Flush()
+			}
+		}
+	}
+}
+
+// writeSSE writes a single event in text/event-stream format
+func writeSSE(c *gin.Context, event events.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+// A restricted stream sees tasks enter and leave its scope, but not the
+// task states outside it
+func TestEventFilterRestriction(t *testing.T) {
+	filter := eventFilter{restriction: taskwarrior.Restriction{Project: "work"}}
+	work := &taskwarrior.Task{UUID: existingUUID, Description: "Write docs", Project: "work.docs"}
+	home := &taskwarrior.Task{UUID: existingUUID, Description: "Write docs", Project: "home"}
+
+	tests := []struct {
+		name          string
+		before, after *taskwarrior.Task
+		matches       bool
+		want          events.Event
+	}{
+		{"inside", work, work, true, events.Event{Before: work, After: work}},
+		{"leaving", work, home, true, events.Event{Before: work}},
+		{"entering", home, work, true, events.Event{After: work}},
+		{"created", nil, work, true, events.Event{After: work}},
+		{"purged", work, nil, true, events.Event{Before: work}},
+		{"outside", home, home, false, events.Event{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := events.Event{Type: events.TaskModified, Before: tt.before, After: tt.after}
+			if got := filter.matches(event); got != tt.matches {
+				t.Fatalf("matches %v, want %v", got, tt.matches)
+			}
+			if !tt.matches {
+				return
+			}
+			scoped := filter.scoped(event)
+			if scoped.Before != tt.want.Before || scoped.After != tt.want.After {
+				t.Errorf("before %+v, after %+v", scoped.Before, scoped.After)
+			}
+		})
+	}
+}
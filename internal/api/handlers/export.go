@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/exporters"
+	"github.com/dotbinio/taskwarrior-api/internal/logging"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/gin-gonic/gin"
+)
+
+// exportBufferSize is how much of an export is buffered before it is sent.
+// Errors within the first buffer still get an error response.
+const exportBufferSize = 32 << 10
+
+// ExportHandler handles export requests
+type ExportHandler struct{}
+
+// NewExportHandler creates a new export handler
+func NewExportHandler() *ExportHandler {
+	return &ExportHandler{}
+}
+
+// Export handles GET /api/v1/export
+// @Summary      Export tasks
+// @Description  Streams tasks as JSON, NDJSON, CSV, Markdown, todo.txt, org-mode or iCalendar. The format is taken from the format parameter or negotiated from the Accept header. Filters work as for listing tasks; with a report, the status defaults to the report's own.
+// @Tags         tasks
+// @Produce      json
+// @Produce      plain
+// @Param        format   query    string    false  "json, ndjson, csv, markdown, todotxt, org or ics"
+// @Param        report   query    string    false  "Report to export"
+// @Param        status   query    string    false  "Filter by status (default pending without a report)"
+// @Param        project  query    string    false  "Filter by project"
+// @Param        tags     query    []string  false  "Filter by tags"
+// @Success      200  {string}  string
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      406  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /export [get]
+func (h *ExportHandler) Export(c *gin.Context) {
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	report := c.Query("report")
+	defaultStatus := "pending"
+	if report != "" {
+		defaultStatus = ""
+	}
+	filters, ok := queryFilters(c, defaultStatus)
+	if !ok {
+		return
+	}
+
+	restriction := restrictionFor(c)
+	filters = append(filters, restriction.Filters()...)
+
+	// Large exports outlive the server write timeout
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	out := &exportResponse{c: c, format: format}
+	buf := bufio.NewWriterSize(out, exportBufferSize)
+	writer := format.New(buf)
+
+	err := clientFor(c).ExportEach(filters, report, func(task taskwarrior.Task) error {
+		if !restriction.Allows(task) {
+			return nil
+		}
+		return writer.WriteTask(task)
+	})
+	if err == nil {
+		if err = writer.Close(); err == nil {
+			err = buf.Flush()
+		}
+	}
+	if err == nil {
+		return
+	}
+
+	if errors.Is(err, taskwarrior.ErrUnknownReport) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "report is not defined",
+			"code":  "INVALID_REPORT",
+		})
+		return
+	}
+	logging.FromContext(c).Error("Failed to export tasks", "format", format.Name, "error", err)
+	if out.started {
+		// The status is already sent; the client sees a truncated body
+		c.Abort()
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": "failed to export tasks",
+		"code":  "TASK_EXPORT_FAILED",
+	})
+}
+
+// exportFormat picks the format from the format parameter or the Accept
+// header, answering 400 or 406 if there is none
+func exportFormat(c *gin.Context) (exporters.Format, bool) {
+	if name := c.Query("format"); name != "" {
+		format, ok := exporters.FormatByName(name)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "format must be json, ndjson, csv, markdown, todotxt, org or ics",
+				"code":  "INVALID_FORMAT",
+			})
+		}
+		return format, ok
+	}
+
+	offered := make([]string, len(exporters.Formats))
+	for i, f := range exporters.Formats {
+		offered[i] = f.ContentType
+	}
+	format, ok := exporters.FormatByContentType(c.NegotiateFormat(offered...))
+	if !ok {
+		c.JSON(http.StatusNotAcceptable, gin.H{
+			"error": "none of the accepted media types can be exported",
+			"code":  "NOT_ACCEPTABLE",
+		})
+	}
+	return format, ok
+}
+
+// exportResponse sends the response headers with the first bytes of the
+// export, so that an export failing before then can still answer with an
+// error
+type exportResponse struct {
+	c       *gin.Context
+	format  exporters.Format
+	started bool
+}
+
+func (r *exportResponse) Write(p []byte) (int, error) {
+	if !r.started {
+		r.started = true
+		header := r.c.Writer.Header()
+		header.Set("Content-Type", r.format.ContentType+"; charset=utf-8")
+		header.Set("Content-Disposition", `attachment; filename="tasks.`+r.format.Extension+`"`)
+		header.Set("Vary", "Accept")
+		r.c.Status(http.StatusOK)
+	}
+	n, err := r.c.Writer.Write(p)
+	r.c.Writer.Flush()
+	return n, err
+}
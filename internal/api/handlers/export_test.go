@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/tasktest"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+func getExport(t *testing.T, fake *tasktest.Fake, query url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+
+	router := newRouter(fake, events.NewBus(0), taskwarrior.Restriction{})
+	router.GET("/export", NewExportHandler().Export)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export?"+query.Encode(), nil))
+	return w
+}
+
+func exportFixture(t *testing.T) *tasktest.Fake {
+	t.Helper()
+
+	fake := tasktest.Install(t, []map[string]any{
+		{"uuid": existingUUID, "description": "Write docs", "status": "pending", "project": "work", "entry": "20260101T090000Z"},
+	})
+	fake.SetShow("report.next.filter=status:pending\n")
+	return fake
+}
+
+func TestExportReport(t *testing.T) {
+	fake := exportFixture(t)
+
+	w := getExport(t, fake, url.Values{"format": {"ndjson"}, "report": {"next"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), existingUUID) {
+		t.Errorf("status %d: %s", w.Code, w.Body)
+	}
+}
+
+// Parameters cannot smuggle settings such as another tenant's data
+// location onto the command line
+func TestExportRejectsOverrides(t *testing.T) {
+	tests := []struct {
+		query url.Values
+		code  string
+	}{
+		{url.Values{"report": {"rc.data.location=/other/tenant"}}, "INVALID_REPORT"},
+		{url.Values{"report": {"undefined"}}, "INVALID_REPORT"},
+		{url.Values{"project": {"work rc.data.location=/other/tenant"}}, "INVALID_FILTER"},
+		{url.Values{"tags": {"docs", "x rc:/other/taskrc"}}, "INVALID_FILTER"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query.Encode(), func(t *testing.T) {
+			fake := exportFixture(t)
+
+			w := getExport(t, fake, tt.query)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.code) {
+				t.Errorf("status %d: %s", w.Code, w.Body)
+			}
+			if calls := fake.CallsOf("export"); len(calls) != 0 {
+				t.Errorf("exported: %q", calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/dotbinio/taskwarrior-api/internal/auth"
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/graph"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/gin-gonic/gin"
+	"github.com/graph-gophers/graphql-go"
+)
+
+// GraphQLHandler handles GraphQL requests
+type GraphQLHandler struct {
+	schema *graphql.Schema
+	tasks  *TaskHandler
+}
+
+// NewGraphQLHandler creates a new GraphQL handler
+func NewGraphQLHandler() *GraphQLHandler {
+	return &GraphQLHandler{schema: graph.NewSchema(), tasks: NewTaskHandler()}
+}
+
+// graphQLRequest is the body of a GraphQL request
+type graphQLRequest struct {
+	Query         string                 `json:"query" binding:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Query handles POST /api/v1/graphql
+// @Summary      GraphQL
+// @Description  Runs a GraphQL query or mutation over tasks, projects, tags, reports and contexts. Errors in resolving fields are reported in the errors array of a 200 response, with the error code in extensions.code.
+// @Tags         graphql
+// @Accept       json
+// @Produce      json
+// @Param        request  body  graphQLRequest  true  "GraphQL request"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /graphql [post]
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	var req graphQLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	identity := auth.IdentityFromContext(c)
+	ctx := graph.WithSession(c.Request.Context(), &graph.Session{
+		Client:      clientFor(c),
+		Restriction: identity.Restriction,
+		Scope:       identity.Scope,
+		Publish: func(eventType events.Type, uuid string, before, after *taskwarrior.Task) {
+			h.tasks.publish(c, eventType, uuid, before, after)
+		},
+	})
+
+	c.JSON(http.StatusOK, h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables))
+}
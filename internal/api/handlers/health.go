@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime/debug"
+
+	"github.com/dotbinio/taskwarrior-api/internal/logging"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/dotbinio/taskwarrior-api/internal/tenant"
+	"github.com/gin-gonic/gin"
+)
+
+// Check statuses
+const (
+	CheckOK     = "ok"
+	CheckFailed = "failed"
+)
+
+// Check is the outcome of one readiness check
+type Check struct {
+	Name   string `json:"name"`
+	Tenant string `json:"tenant,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthHandler handles liveness, readiness and diagnostics requests
+type HealthHandler struct {
+	registry *tenant.Registry
+}
+
+// NewHealthHandler creates a new health handler
+func NewHealthHandler(registry *tenant.Registry) *HealthHandler {
+	return &HealthHandler{registry: registry}
+}
+
+// Livez handles GET /livez. The server is live as long as it answers.
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz handles GET /readyz. The server is ready when the task binary is
+// installed in a supported version, and every tenant's data location is
+// readable and writable and its taskrc loads.
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	logger := logging.FromContext(c)
+	checks := []Check{}
+	ready := true
+	record := func(name, tenantName string, err error, public string) {
+		check := Check{Name: name, Tenant: tenantName, Status: CheckOK}
+		if err != nil {
+			// Details such as paths are only logged
+			logger.Warn("Readiness check failed", "check", name, "tenant", tenantName, "error", err)
+			check.Status = CheckFailed
+			check.Error = public
+			ready = false
+		}
+		checks = append(checks, check)
+	}
+
+	_, binaryErr := exec.LookPath("task")
+	record("task_binary", "", binaryErr, "task binary not found")
+
+	for _, t := range h.registry.Tenants() {
+		record("data_location", t.Name, checkDataLocation(t.Client.DataLocation()), "data location is not readable and writable")
+
+		// The remaining checks run task
+		if binaryErr != nil {
+			continue
+		}
+		version, versionErr := t.Client.Version()
+		record("taskrc", t.Name, versionErr, "taskrc could not be loaded")
+		if versionErr != nil {
+			continue
+		}
+		var supportErr error
+		if !version.Supported() {
+			supportErr = fmt.Errorf("taskwarrior %s is not supported", version)
+		}
+		record("task_version", t.Name, supportErr,
+			fmt.Sprintf("Taskwarrior %s is not supported (requires %s or later, before 4.0.0)", version, taskwarrior.MinSupportedVersion))
+	}
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "unavailable",
+			"checks": checks,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"checks": checks,
+	})
+}
+
+// checkDataLocation lists the data location and creates and removes a
+// file in it
+func checkDataLocation(dir string) error {
+	if _, err := os.ReadDir(dir); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, ".readyz-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// GetDiagnostics handles GET /api/v1/diagnostics
+// @Summary      Diagnostics
+// @Description  Output of task diagnostics for the caller's tenant, the Taskwarrior version and the server's build info
+// @Tags         diagnostics
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /diagnostics [get]
+func (h *HealthHandler) GetDiagnostics(c *gin.Context) {
+	client := clientFor(c)
+
+	version, err := client.Version()
+	if err != nil {
+		logging.FromContext(c).Error("Failed to get Taskwarrior version", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to get Taskwarrior version",
+			"code":  "DIAGNOSTICS_FAILED",
+		})
+		return
+	}
+
+	diagnostics, err := client.Diagnostics()
+	if err != nil {
+		logging.FromContext(c).Error("Failed to run task diagnostics", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to run task diagnostics",
+			"code":  "DIAGNOSTICS_FAILED",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tenant": tenant.FromContext(c).Name,
+		"taskwarrior": gin.H{
+			"version":     version.String(),
+			"supported":   version.Supported(),
+			"diagnostics": diagnostics,
+		},
+		"server": buildInfo(),
+	})
+}
+
+// buildInfo describes the running binary
+func buildInfo() gin.H {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return gin.H{}
+	}
+
+	build := gin.H{
+		"version":    info.Main.Version,
+		"go_version": info.GoVersion,
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build["revision"] = setting.Value
+		case "vcs.time":
+			build["build_time"] = setting.Value
+		case "vcs.modified":
+			build["modified"] = setting.Value == "true"
+		}
+	}
+	return build
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/logging"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/gin-gonic/gin"
+)
+
+// Limits on a single import request
+const (
+	maxImportBytes = 32 << 20
+	maxImportTasks = 10000
+)
+
+// ImportHandler handles bulk import requests
+type ImportHandler struct{}
+
+// NewImportHandler creates a new import handler
+func NewImportHandler() *ImportHandler {
+	return &ImportHandler{}
+}
+
+// ImportTasks handles POST /api/v1/import
+// @Summary      Import tasks
+// @Description  Imports a Taskwarrior export JSON array or NDJSON stream with task import. Tasks are matched by UUID, so repeating an import changes nothing. With validate=true nothing is written and the results show what would happen.
+// @Tags         import
+// @Accept       json
+// @Produce      json
+// @Param        validate  query  bool  false  "Only validate the payload"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      413  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /import [post]
+func (h *ImportHandler) ImportTasks(c *gin.Context) {
+	validateOnly, _ := strconv.ParseBool(c.Query("validate"))
+
+	items, err := taskwarrior.ParseImport(importBody(c))
+	if !checkImportPayload(c, err, len(items)) {
+		return
+	}
+
+	results, afterStateMissing, ok := h.runImport(c, items, validateOnly)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"validate_only":       validateOnly,
+		"results":             results,
+		"counts":              importCounts(results),
+		"after_state_missing": afterStateMissing,
+	})
+}
+
+// importBody returns the request body, limited to maxImportBytes
+func importBody(c *gin.Context) io.Reader {
+	return http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+}
+
+// checkImportPayload writes the error response if the payload could not
+// be parsed or holds too many tasks
+func checkImportPayload(c *gin.Context, err error, count int) bool {
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": "import payload is too large",
+				"code":  "IMPORT_TOO_LARGE",
+			})
+			return false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+			"code":  "INVALID_REQUEST",
+		})
+		return false
+	}
+	if count > maxImportTasks {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": "too many tasks, import at most " + strconv.Itoa(maxImportTasks) + " at a time",
+			"code":  "IMPORT_TOO_LARGE",
+		})
+		return false
+	}
+	return true
+}
+
+// runImport imports items, or only validates them, and publishes events
+// for the tasks that changed. afterStateMissing reports that the tasks
+// were written but could not be read back, in which case the events carry
+// no state after the import. On failure, it writes the error response.
+func (h *ImportHandler) runImport(c *gin.Context, items []json.RawMessage, validateOnly bool) (results []taskwarrior.ImportResult, afterStateMissing bool, ok bool) {
+	results, err := clientFor(c).Import(items, validateOnly)
+	if errors.Is(err, taskwarrior.ErrImportAfterState) {
+		logging.FromContext(c).Warn("Imported tasks could not be read back", "error", err)
+		afterStateMissing = true
+	} else if err != nil {
+		logging.FromContext(c).Error("Failed to import tasks", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to import tasks",
+			"code":  "IMPORT_FAILED",
+		})
+		return nil, false, false
+	}
+
+	if validateOnly {
+		return results, false, true
+	}
+	for _, result := range results {
+		var eventType events.Type
+		switch result.Status {
+		case taskwarrior.ImportCreated:
+			eventType = events.TaskCreated
+		case taskwarrior.ImportUpdated:
+			eventType = events.TaskModified
+		default:
+			continue
+		}
+		busFor(c).Publish(events.Event{
+			Type:     eventType,
+			TaskUUID: result.UUID,
+			Before:   result.Before,
+			After:    result.After,
+		})
+	}
+	return results, afterStateMissing, true
+}
+
+// importCounts counts the results by outcome
+func importCounts(results []taskwarrior.ImportResult) map[string]int {
+	counts := map[string]int{
+		taskwarrior.ImportCreated: 0,
+		taskwarrior.ImportUpdated: 0,
+		taskwarrior.ImportSkipped: 0,
+		taskwarrior.ImportFailed:  0,
+	}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+	return counts
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/tasktest"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+type importResponse struct {
+	Results           []taskwarrior.ImportResult `json:"results"`
+	AfterStateMissing bool                       `json:"after_state_missing"`
+}
+
+// postImport imports payload into a fake Taskwarrior and returns the
+// response and the published events
+func postImport(t *testing.T, fake *tasktest.Fake, payload string) (*httptest.ResponseRecorder, []events.Event) {
+	t.Helper()
+
+	bus := events.NewBus(0)
+	var published []events.Event
+	bus.Subscribe(func(event events.Event) { published = append(published, event) })
+
+	router := newRouter(fake, bus, taskwarrior.Restriction{})
+	router.POST("/import", NewImportHandler().ImportTasks)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/import", strings.NewReader(payload)))
+	return w, published
+}
+
+// When the tasks were written but cannot be read back, the import still
+// reports every result and publishes an event for each written task
+func TestImportAfterStateMissing(t *testing.T) {
+	fake := tasktest.Install(t, []map[string]any{
+		{"uuid": existingUUID, "description": "Write docs", "status": "pending", "entry": "20260101T090000Z"},
+		{"uuid": skippedUUID, "description": "Water plants", "status": "pending", "entry": "20260101T090000Z"},
+	})
+	// The export before the import succeeds, the one after fails
+	fake.FailAfter("export", 1)
+
+	w, published := postImport(t, fake, `[
+		{"uuid":"`+existingUUID+`","description":"Write the docs","status":"pending"},
+		{"uuid":"`+newUUID+`","description":"Release 1.0","status":"pending"},
+		{"uuid":"`+skippedUUID+`","description":"Water plants"},
+		{"description":"No uuid"}
+	]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	var resp importResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.AfterStateMissing {
+		t.Error("after_state_missing is not set")
+	}
+	var statuses []string
+	for _, result := range resp.Results {
+		statuses = append(statuses, result.Status)
+	}
+	want := []string{taskwarrior.ImportUpdated, taskwarrior.ImportCreated, taskwarrior.ImportSkipped, taskwarrior.ImportFailed}
+	if !slices.Equal(statuses, want) {
+		t.Errorf("statuses %q, want %q", statuses, want)
+	}
+
+	if len(published) != 2 {
+		t.Fatalf("published %d events, want one per written task", len(published))
+	}
+	for i, want := range []struct {
+		eventType events.Type
+		uuid      string
+	}{
+		{events.TaskModified, existingUUID},
+		{events.TaskCreated, newUUID},
+	} {
+		event := published[i]
+		if event.Type != want.eventType || event.TaskUUID != want.uuid || event.After != nil {
+			t.Errorf("event %d: %s %s, after %v; want %s %s", i, event.Type, event.TaskUUID, event.After, want.eventType, want.uuid)
+		}
+	}
+	if published[0].Before == nil {
+		t.Error("the modified event lost its before-state")
+	}
+}
+
+func TestImportFailed(t *testing.T) {
+	fake := tasktest.Install(t, nil)
+	fake.Fail("export", true)
+
+	w, published := postImport(t, fake, `[{"uuid":"`+newUUID+`","description":"Release 1.0"}]`)
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "IMPORT_FAILED") {
+		t.Errorf("status %d: %s", w.Code, w.Body)
+	}
+	if len(published) != 0 {
+		t.Errorf("published %d events", len(published))
+	}
+}
+
+// An export of one instance imports into another with every time intact,
+// and importing it again changes nothing
+func TestExportImportRoundTrip(t *testing.T) {
+	source := tasktest.Install(t, []map[string]any{
+		{
+			"uuid": existingUUID, "description": "Write docs", "status": "pending",
+			"project": "work", "tags": []string{"docs"}, "priority": "H",
+			"entry": "20260101T090000Z", "modified": "20260103T103000Z", "due": "20260201T170000Z",
+			"annotations": []map[string]any{{"entry": "20260102T080000Z", "description": "Outline agreed"}},
+		},
+		{
+			"uuid": newUUID, "description": "Release 1.0", "status": "completed",
+			"entry": "20260101T090000Z", "modified": "20260105T120000Z", "end": "20260105T120000Z",
+		},
+	})
+	for _, format := range []string{"json", "ndjson"} {
+		t.Run(format, func(t *testing.T) {
+			w := getExport(t, source, url.Values{"format": {format}, "status": {""}})
+			if w.Code != http.StatusOK {
+				t.Fatalf("export: status %d: %s", w.Code, w.Body)
+			}
+			payload := w.Body.String()
+			if format == "json" {
+				var body struct{ Tasks json.RawMessage }
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				payload = string(body.Tasks)
+			}
+
+			target := tasktest.Install(t, nil)
+			for _, want := range []string{taskwarrior.ImportCreated, taskwarrior.ImportSkipped} {
+				w, _ := postImport(t, target, payload)
+				var resp importResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+					t.Fatalf("import: status %d: %s", w.Code, w.Body)
+				}
+				for _, result := range resp.Results {
+					if result.Status != want {
+						t.Errorf("%s: %s %s, want %s", result.UUID, result.Status, result.Error, want)
+					}
+				}
+			}
+
+			for _, uuid := range []string{existingUUID, newUUID} {
+				want, got := source.Task(uuid), target.Task(uuid)
+				for _, key := range []string{"entry", "modified", "due", "end", "status", "project"} {
+					if got[key] != want[key] {
+						t.Errorf("%s: %s = %v, want %v", uuid, key, got[key], want[key])
+					}
+				}
+			}
+			annotations, _ := target.Task(existingUUID)["annotations"].([]any)
+			if len(annotations) != 1 || annotations[0].(map[string]any)["entry"] != "20260102T080000Z" {
+				t.Errorf("annotations %v", annotations)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dotbinio/taskwarrior-api/internal/auth"
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/tasktest"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/dotbinio/taskwarrior-api/internal/tenant"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	tasktest.Main()
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+const (
+	existingUUID = "a360fc44-315c-4366-b70c-ea7e7520b749"
+	newUUID      = "0f6b2c1e-9d4a-4c3b-8e2f-7a1b5c9d3e4f"
+	skippedUUID  = "5e7d9c3a-2b1f-4e8d-a6c4-3f2e1d0c9b8a"
+)
+
+// newRouter returns a router whose requests are made by an admin token
+// with restriction, on a tenant backed by fake
+func newRouter(fake *tasktest.Fake, bus *events.Bus, restriction taskwarrior.Restriction) *gin.Engine {
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		auth.SetIdentity(c, &auth.Identity{Name: "test", Scope: auth.ScopeAdmin, Restriction: restriction})
+		tenant.SetContext(c, &tenant.Tenant{
+			Client: taskwarrior.NewClient(fake.DataLocation(), ""),
+			Bus:    bus,
+		})
+	})
+	return router
+}
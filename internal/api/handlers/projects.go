@@ -8,15 +8,11 @@ import (
 )
 
 // ProjectHandler handles project-related requests
-type ProjectHandler struct {
-	client *taskwarrior.Client
-}
+type ProjectHandler struct{}
 
 // NewProjectHandler creates a new project handler
-func NewProjectHandler(client *taskwarrior.Client) *ProjectHandler {
-	return &ProjectHandler{
-		client: client,
-	}
+func NewProjectHandler() *ProjectHandler {
+	return &ProjectHandler{}
 }
 
 // ListProjects handles GET /api/v1/projects
@@ -29,7 +25,9 @@ func NewProjectHandler(client *taskwarrior.Client) *ProjectHandler {
 // @Security     BearerAuth
 // @Router       /projects [get]
 func (h *ProjectHandler) ListProjects(c *gin.Context) {
-	projects, err := h.client.GetProjects()
+	client := clientFor(c)
+
+	projects, err := client.GetProjects(restrictionFor(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to retrieve projects",
@@ -56,6 +54,8 @@ func (h *ProjectHandler) ListProjects(c *gin.Context) {
 // @Security     BearerAuth
 // @Router       /projects/{name}/tasks [get]
 func (h *ProjectHandler) GetProjectTasks(c *gin.Context) {
+	client := clientFor(c)
+
 	projectName := c.Param("name")
 
 	if projectName == "" {
@@ -69,7 +69,10 @@ func (h *ProjectHandler) GetProjectTasks(c *gin.Context) {
 	// Sanitize project name
 	projectName = taskwarrior.SanitizeInput(projectName)
 
-	tasks, err := h.client.Export("project:"+projectName, "status:pending")
+	restriction := restrictionFor(c)
+	filters := append([]string{"project:" + projectName, "status:pending"}, restriction.Filters()...)
+
+	tasks, err := client.Export(filters...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to retrieve project tasks",
@@ -77,6 +80,11 @@ func (h *ProjectHandler) GetProjectTasks(c *gin.Context) {
 		})
 		return
 	}
+	tasks = restriction.Apply(tasks)
+
+	if notModified(c, tasksETag(tasks)) {
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"project": projectName,
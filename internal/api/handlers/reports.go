@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"sort"
 
@@ -9,15 +10,11 @@ import (
 )
 
 // ReportHandler handles report-related requests
-type ReportHandler struct {
-	client *taskwarrior.Client
-}
+type ReportHandler struct{}
 
 // NewReportHandler creates a new report handler
-func NewReportHandler(client *taskwarrior.Client) *ReportHandler {
-	return &ReportHandler{
-		client: client,
-	}
+func NewReportHandler() *ReportHandler {
+	return &ReportHandler{}
 }
 
 // ListReports handles GET /api/v1/reports
@@ -30,7 +27,9 @@ func NewReportHandler(client *taskwarrior.Client) *ReportHandler {
 // @Security     BearerAuth
 // @Router       /reports [get]
 func (h *ReportHandler) ListReports(c *gin.Context) {
-	reports, err := h.client.GetReports()
+	client := clientFor(c)
+
+	reports, err := client.GetReports()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to retrieve reports",
@@ -57,14 +56,25 @@ func (h *ReportHandler) ListReports(c *gin.Context) {
 // @Produce      json
 // @Param        name  path  string  true  "Report name"
 // @Success      200  {object}  map[string]interface{}
-// @Failure      400  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
 // @Failure      500  {object}  map[string]interface{}
 // @Security     BearerAuth
 // @Router       /reports/{name}/tasks [get]
 func (h *ReportHandler) GetReport(c *gin.Context) {
+	client := clientFor(c)
+
 	reportName := c.Param("name")
 
-	tasks, err := h.client.ExportReport([]string{}, reportName)
+	restriction := restrictionFor(c)
+
+	tasks, err := client.ExportReport(restriction.Filters(), reportName)
+	if errors.Is(err, taskwarrior.ErrUnknownReport) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "report not found",
+			"code":  "REPORT_NOT_FOUND",
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to retrieve tasks",
@@ -72,6 +82,11 @@ func (h *ReportHandler) GetReport(c *gin.Context) {
 		})
 		return
 	}
+	tasks = restriction.Apply(tasks)
+
+	if notModified(c, tasksETag(tasks)) {
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"tasks":  tasks,
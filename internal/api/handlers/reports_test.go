@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+func TestGetReport(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{"next", http.StatusOK},
+		{"undefined", http.StatusNotFound},
+		{"rc.data.location=~", http.StatusNotFound},
+		{"rc:~", http.StatusNotFound},
+		{"next rc.verbose=off", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := exportFixture(t)
+			router := newRouter(fake, events.NewBus(0), taskwarrior.Restriction{})
+			router.GET("/reports/:name/tasks", NewReportHandler().GetReport)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/reports/"+url.PathEscape(tt.name)+"/tasks", nil))
+			if w.Code != tt.status {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			if tt.status == http.StatusNotFound {
+				if !strings.Contains(w.Body.String(), "REPORT_NOT_FOUND") {
+					t.Errorf("body %s", w.Body)
+				}
+				if calls := fake.CallsOf("export"); len(calls) != 0 {
+					t.Errorf("exported: %q", calls)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dotbinio/taskwarrior-api/internal/auth"
+	"github.com/dotbinio/taskwarrior-api/internal/logging"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/gin-gonic/gin"
+)
+
+// restrictionFor returns the project and tag restriction of the request's
+// token
+func restrictionFor(c *gin.Context) taskwarrior.Restriction {
+	return auth.IdentityFromContext(c).Restriction
+}
+
+// taskOutOfScope answers 403 Forbidden when task is outside the token's
+// restriction
+func taskOutOfScope(c *gin.Context, task *taskwarrior.Task) bool {
+	if task == nil || restrictionFor(c).Allows(*task) {
+		return false
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "task is outside the projects and tags this token may access",
+		"code":  "TASK_OUT_OF_SCOPE",
+	})
+	return true
+}
+
+// errRejected is returned by writeTask when the request was answered
+// without the write running
+var errRejected = errors.New("write rejected")
+
+// writeTask runs write against the task with uuid while holding the write
+// lock of the task data, and returns the task as it was before. The task
+// is read again under the lock: if there is no such task, it could not be
+// read, it is outside the token's restriction or it does not match
+// If-Match, the request is answered and errRejected returned. No other
+// write can change the task between this check and the write.
+func writeTask(c *gin.Context, client *taskwarrior.Client, uuid string, write func(locked *taskwarrior.Client) error) (*taskwarrior.Task, error) {
+	var before *taskwarrior.Task
+	err := client.CheckedWrite(uuid, func(task *taskwarrior.Task, err error) error {
+		if !taskWritable(c, uuid, task, err) || preconditionFailed(c, task) {
+			return errRejected
+		}
+		before = task
+		return nil
+	}, write)
+	return before, err
+}
+
+// taskWritable checks the result of reading a task that is about to be
+// changed. It answers 404 Not Found if there is no such task, 500 if it
+// could not be read and 403 Forbidden if it is outside the token's
+// restriction.
+func taskWritable(c *gin.Context, uuid string, task *taskwarrior.Task, err error) bool {
+	if errors.Is(err, taskwarrior.ErrTaskNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "task not found",
+			"code":  "TASK_NOT_FOUND",
+		})
+		return false
+	}
+	if err != nil {
+		logging.FromContext(c).Error("Failed to retrieve task", "uuid", uuid, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to retrieve task",
+			"code":  "TASK_EXPORT_FAILED",
+		})
+		return false
+	}
+
+	return !taskOutOfScope(c, task)
+}
+
+// projectOutOfScope answers 403 Forbidden when project is outside the
+// token's restriction
+func projectOutOfScope(c *gin.Context, project string) bool {
+	if restrictionFor(c).AllowsProject(project) {
+		return false
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "project is outside the project this token may access",
+		"code":  "PROJECT_OUT_OF_SCOPE",
+	})
+	return true
+}
+
+// restrictCreate places a new task inside the token's restriction. A
+// missing project defaults to the restricted project and the restricted
+// tags are added; a project outside the restriction is rejected.
+func restrictCreate(c *gin.Context, task *taskwarrior.TaskCreate) bool {
+	if restrictionFor(c).Confine(task) {
+		return true
+	}
+
+	c.JSON(http.StatusForbidden, gin.H{
+		"error": "project is outside the project this token may access",
+		"code":  "PROJECT_OUT_OF_SCOPE",
+	})
+	return false
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/dotbinio/taskwarrior-api/internal/tasksync"
+	"github.com/dotbinio/taskwarrior-api/internal/tenant"
+	"github.com/gin-gonic/gin"
+)
+
+// SyncHandler handles task sync requests
+type SyncHandler struct{}
+
+// NewSyncHandler creates a new sync handler
+func NewSyncHandler() *SyncHandler {
+	return &SyncHandler{}
+}
+
+// Sync handles POST /api/v1/sync
+// @Summary      Run task sync
+// @Description  Runs task sync, waiting for a sync that is already running, and returns its outcome. Other changes wait until the sync has finished.
+// @Tags         sync
+// @Produce      json
+// @Success      200  {object}  tasksync.Status
+// @Failure      502  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /sync [post]
+func (h *SyncHandler) Sync(c *gin.Context) {
+	status, err := syncerFor(c).Sync(c.Request.Context(), tasksync.TriggerAPI)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":  "task sync failed",
+			"code":   "SYNC_FAILED",
+			"status": status,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// GetStatus handles GET /api/v1/sync/status
+// @Summary      Sync status
+// @Description  Outcome of the last sync, and whether one is running
+// @Tags         sync
+// @Produce      json
+// @Success      200  {object}  tasksync.Status
+// @Security     BearerAuth
+// @Router       /sync/status [get]
+func (h *SyncHandler) GetStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, syncerFor(c).Status())
+}
+
+// syncerFor returns the syncer of the request's tenant
+func syncerFor(c *gin.Context) *tasksync.Syncer {
+	return tenant.FromContext(c).Syncer
+}
@@ -1,23 +1,22 @@
 package handlers
 
 import (
-	"log"
+	"errors"
 	"net/http"
 
+	"github.com/dotbinio/taskwarrior-api/internal/audit"
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/logging"
 	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
 	"github.com/gin-gonic/gin"
 )
 
 // TaskHandler handles task-related requests
-type TaskHandler struct {
-	client *taskwarrior.Client
-}
+type TaskHandler struct{}
 
 // NewTaskHandler creates a new task handler
-func NewTaskHandler(client *taskwarrior.Client) *TaskHandler {
-	return &TaskHandler{
-		client: client,
-	}
+func NewTaskHandler() *TaskHandler {
+	return &TaskHandler{}
 }
 
 // ListTasks handles GET /api/v1/tasks
@@ -29,36 +28,35 @@ func NewTaskHandler(client *taskwarrior.Client) *TaskHandler {
 // @Param        project  query    string    false  "Filter by project"
 // @Param        tags     query    []string  false  "Filter by tags"
 // @Success      200  {object}  map[string]interface{}
+// @Failure      400  {object}  map[string]interface{}
 // @Failure      500  {object}  map[string]interface{}
 // @Security     BearerAuth
 // @Router       /tasks [get]
 func (h *TaskHandler) ListTasks(c *gin.Context) {
-	// Get query parameters for filtering
-	status := c.DefaultQuery("status", "pending")
-	project := c.Query("project")
-	tags := c.QueryArray("tags")
+	client := clientFor(c)
 
-	// Build filter array for Taskwarrior
-	filters := []string{}
-	if status != "" {
-		filters = append(filters, "status:"+status)
-	}
-	if project != "" {
-		filters = append(filters, "project:"+project)
-	}
-	for _, tag := range tags {
-		filters = append(filters, "+"+tag)
+	filters, ok := queryFilters(c, "pending")
+	if !ok {
+		return
 	}
 
-	tasks, err := h.client.Export(filters...)
+	restriction := restrictionFor(c)
+	filters = append(filters, restriction.Filters()...)
+
+	tasks, err := client.Export(filters...)
 	if err != nil {
-		log.Printf("Failed to retrieve tasks: %v", err)
+		logging.FromContext(c).Error("Failed to retrieve tasks", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to retrieve tasks",
 			"code":  "TASK_EXPORT_FAILED",
 		})
 		return
 	}
+	tasks = restriction.Apply(tasks)
+
+	if notModified(c, tasksETag(tasks)) {
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"tasks": tasks,
@@ -66,18 +64,38 @@ func (h *TaskHandler) ListTasks(c *gin.Context) {
 	})
 }
 
+// queryFilters builds the Taskwarrior filter from the status, project and
+// tags query parameters. status defaults to defaultStatus. If a parameter
+// is not a single filter term, it writes the error response.
+func queryFilters(c *gin.Context, defaultStatus string) ([]string, bool) {
+	filters, err := taskwarrior.FilterTerms(c.DefaultQuery("status", defaultStatus), c.Query("project"), c.QueryArray("tags"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+			"code":  "INVALID_FILTER",
+		})
+		return nil, false
+	}
+	return filters, true
+}
+
 // GetTask handles GET /api/v1/tasks/:uuid
 // @Summary      Get a task
 // @Description  Get task by UUID
 // @Tags         tasks
 // @Produce      json
-// @Param        uuid  path  string  true  "Task UUID"
+// @Param        uuid           path    string  true   "Task UUID"
+// @Param        If-None-Match  header  string  false  "Answer 304 if the task still has this ETag"
 // @Success      200  {object}  taskwarrior.Task
+// @Success      304  "Not Modified"
 // @Failure      400  {object}  map[string]interface{}
+// @Failure      403  {object}  map[string]interface{}
 // @Failure      404  {object}  map[string]interface{}
 // @Security     BearerAuth
 // @Router       /tasks/{uuid} [get]
 func (h *TaskHandler) GetTask(c *gin.Context) {
+	client := clientFor(c)
+
 	uuid := c.Param("uuid")
 
 	if !taskwarrior.ValidateTaskUUID(uuid) {
@@ -88,7 +106,7 @@ func (h *TaskHandler) GetTask(c *gin.Context) {
 		return
 	}
 
-	task, err := h.client.GetByUUID(uuid)
+	task, err := client.GetByUUID(uuid)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "task not found",
@@ -97,6 +115,14 @@ func (h *TaskHandler) GetTask(c *gin.Context) {
 		return
 	}
 
+	if taskOutOfScope(c, task) {
+		return
+	}
+
+	if notModified(c, taskETag(task)) {
+		return
+	}
+
 	c.JSON(http.StatusOK, task)
 }
 
@@ -109,10 +135,13 @@ func (h *TaskHandler) GetTask(c *gin.Context) {
 // @Param        task  body  taskwarrior.TaskCreate  true  "Task data"
 // @Success      201  {object}  taskwarrior.Task
 // @Failure      400  {object}  map[string]interface{}
+// @Failure      403  {object}  map[string]interface{}
 // @Failure      500  {object}  map[string]interface{}
 // @Security     BearerAuth
 // @Router       /tasks [post]
 func (h *TaskHandler) CreateTask(c *gin.Context) {
+	client := clientFor(c)
+
 	var taskCreate taskwarrior.TaskCreate
 
 	if err := c.ShouldBindJSON(&taskCreate); err != nil {
@@ -129,7 +158,11 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		taskCreate.Project = taskwarrior.SanitizeInput(taskCreate.Project)
 	}
 
-	uuid, err := h.client.Add(taskCreate)
+	if !restrictCreate(c, &taskCreate) {
+		return
+	}
+
+	uuid, err := client.Add(taskCreate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to create task",
@@ -139,7 +172,8 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 	}
 
 	// Retrieve the created task
-	task, err := h.client.GetByUUID(uuid)
+	task, err := client.GetByUUID(uuid)
+	h.publish(c, events.TaskCreated, uuid, nil, task)
 	if err != nil {
 		// Task was created but we can't retrieve it
 		c.JSON(http.StatusCreated, gin.H{
@@ -149,6 +183,7 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", taskETag(task))
 	c.JSON(http.StatusCreated, task)
 }
 
@@ -158,14 +193,20 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 // @Tags         tasks
 // @Accept       json
 // @Produce      json
-// @Param        uuid  path  string  true  "Task UUID"
-// @Param        task  body  taskwarrior.TaskModify  true  "Task updates"
+// @Param        uuid      path    string  true   "Task UUID"
+// @Param        If-Match  header  string  false  "Only apply the change if the task still has this ETag"
+// @Param        task      body    taskwarrior.TaskModify  true  "Task updates"
 // @Success      200  {object}  taskwarrior.Task
 // @Failure      400  {object}  map[string]interface{}
+// @Failure      403  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Failure      412  {object}  map[string]interface{}
 // @Failure      500  {object}  map[string]interface{}
 // @Security     BearerAuth
 // @Router       /tasks/{uuid} [patch]
 func (h *TaskHandler) UpdateTask(c *gin.Context) {
+	client := clientFor(c)
+
 	uuid := c.Param("uuid")
 
 	if !taskwarrior.ValidateTaskUUID(uuid) {
@@ -193,9 +234,18 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 	if taskModify.Project != nil {
 		proj := taskwarrior.SanitizeInput(*taskModify.Project)
 		taskModify.Project = &proj
+		if projectOutOfScope(c, proj) {
+			return
+		}
 	}
 
-	if err := h.client.Modify(uuid, taskModify); err != nil {
+	before, err := writeTask(c, client, uuid, func(locked *taskwarrior.Client) error {
+		return locked.Modify(uuid, taskModify)
+	})
+	if errors.Is(err, errRejected) {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to update task",
 			"code":  "TASK_UPDATE_FAILED",
@@ -204,7 +254,8 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 	}
 
 	// Retrieve the updated task
-	task, err := h.client.GetByUUID(uuid)
+	task, err := client.GetByUUID(uuid)
+	h.publish(c, events.TaskModified, uuid, before, task)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"message": "task updated successfully",
@@ -212,6 +263,7 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", taskETag(task))
 	c.JSON(http.StatusOK, task)
 }
 
@@ -220,13 +272,19 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 // @Description  Delete task by UUID
 // @Tags         tasks
 // @Produce      json
-// @Param        uuid  path  string  true  "Task UUID"
+// @Param        uuid      path    string  true   "Task UUID"
+// @Param        If-Match  header  string  false  "Only apply the change if the task still has this ETag"
 // @Success      200  {object}  map[string]interface{}
 // @Failure      400  {object}  map[string]interface{}
+// @Failure      403  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Failure      412  {object}  map[string]interface{}
 // @Failure      500  {object}  map[string]interface{}
 // @Security     BearerAuth
 // @Router       /tasks/{uuid} [delete]
 func (h *TaskHandler) DeleteTask(c *gin.Context) {
+	client := clientFor(c)
+
 	uuid := c.Param("uuid")
 
 	if !taskwarrior.ValidateTaskUUID(uuid) {
@@ -237,7 +295,13 @@ func (h *TaskHandler) DeleteTask(c *gin.Context) {
 		return
 	}
 
-	if err := h.client.Delete(uuid); err != nil {
+	before, err := writeTask(c, client, uuid, func(locked *taskwarrior.Client) error {
+		return locked.Delete(uuid)
+	})
+	if errors.Is(err, errRejected) {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to delete task",
 			"code":  "TASK_DELETE_FAILED",
@@ -245,6 +309,9 @@ func (h *TaskHandler) DeleteTask(c *gin.Context) {
 		return
 	}
 
+	after, _ := client.GetByUUID(uuid)
+	h.publish(c, events.TaskDeleted, uuid, before, after)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "task deleted successfully",
 	})
@@ -255,13 +322,19 @@ func (h *TaskHandler) DeleteTask(c *gin.Context) {
 // @Description  Complete a task
 // @Tags         tasks
 // @Produce      json
-// @Param        uuid  path  string  true  "Task UUID"
+// @Param        uuid      path    string  true   "Task UUID"
+// @Param        If-Match  header  string  false  "Only apply the change if the task still has this ETag"
 // @Success      200  {object}  map[string]interface{}
 // @Failure      400  {object}  map[string]interface{}
+// @Failure      403  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Failure      412  {object}  map[string]interface{}
 // @Failure      500  {object}  map[string]interface{}
 // @Security     BearerAuth
 // @Router       /tasks/{uuid}/done [post]
 func (h *TaskHandler) DoneTask(c *gin.Context) {
+	client := clientFor(c)
+
 	uuid := c.Param("uuid")
 
 	if !taskwarrior.ValidateTaskUUID(uuid) {
@@ -272,7 +345,13 @@ func (h *TaskHandler) DoneTask(c *gin.Context) {
 		return
 	}
 
-	if err := h.client.Done(uuid); err != nil {
+	before, err := writeTask(c, client, uuid, func(locked *taskwarrior.Client) error {
+		return locked.Done(uuid)
+	})
+	if errors.Is(err, errRejected) {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to mark task as done",
 			"code":  "TASK_DONE_FAILED",
@@ -280,6 +359,12 @@ func (h *TaskHandler) DoneTask(c *gin.Context) {
 		return
 	}
 
+	after, _ := client.GetByUUID(uuid)
+	h.publish(c, events.TaskCompleted, uuid, before, after)
+	if after != nil {
+		c.Header("ETag", taskETag(after))
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "task marked as done",
 	})
@@ -290,13 +375,19 @@ func (h *TaskHandler) DoneTask(c *gin.Context) {
 // @Description  Start task timer
 // @Tags         tasks
 // @Produce      json
-// @Param        uuid  path  string  true  "Task UUID"
+// @Param        uuid      path    string  true   "Task UUID"
+// @Param        If-Match  header  string  false  "Only apply the change if the task still has this ETag"
 // @Success      200  {object}  map[string]interface{}
 // @Failure      400  {object}  map[string]interface{}
+// @Failure      403  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Failure      412  {object}  map[string]interface{}
 // @Failure      500  {object}  map[string]interface{}
 // @Security     BearerAuth
 // @Router       /tasks/{uuid}/start [post]
 func (h *TaskHandler) StartTask(c *gin.Context) {
+	client := clientFor(c)
+
 	uuid := c.Param("uuid")
 
 	if !taskwarrior.ValidateTaskUUID(uuid) {
@@ -307,7 +398,13 @@ func (h *TaskHandler) StartTask(c *gin.Context) {
 		return
 	}
 
-	if err := h.client.Start(uuid); err != nil {
+	before, err := writeTask(c, client, uuid, func(locked *taskwarrior.Client) error {
+		return locked.Start(uuid)
+	})
+	if errors.Is(err, errRejected) {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to start task",
 			"code":  "TASK_START_FAILED",
@@ -315,6 +412,12 @@ func (h *TaskHandler) StartTask(c *gin.Context) {
 		return
 	}
 
+	after, _ := client.GetByUUID(uuid)
+	h.publish(c, events.TaskStarted, uuid, before, after)
+	if after != nil {
+		c.Header("ETag", taskETag(after))
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "task started",
 	})
@@ -325,13 +428,19 @@ func (h *TaskHandler) StartTask(c *gin.Context) {
 // @Description  Stop task timer
 // @Tags         tasks
 // @Produce      json
-// @Param        uuid  path  string  true  "Task UUID"
+// @Param        uuid      path    string  true   "Task UUID"
+// @Param        If-Match  header  string  false  "Only apply the change if the task still has this ETag"
 // @Success      200  {object}  map[string]interface{}
 // @Failure      400  {object}  map[string]interface{}
+// @Failure      403  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Failure      412  {object}  map[string]interface{}
 // @Failure      500  {object}  map[string]interface{}
 // @Security     BearerAuth
 // @Router       /tasks/{uuid}/stop [post]
 func (h *TaskHandler) StopTask(c *gin.Context) {
+	client := clientFor(c)
+
 	uuid := c.Param("uuid")
 
 	if !taskwarrior.ValidateTaskUUID(uuid) {
@@ -342,7 +451,13 @@ func (h *TaskHandler) StopTask(c *gin.Context) {
 		return
 	}
 
-	if err := h.client.Stop(uuid); err != nil {
+	before, err := writeTask(c, client, uuid, func(locked *taskwarrior.Client) error {
+		return locked.Stop(uuid)
+	})
+	if errors.Is(err, errRejected) {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "failed to stop task",
 			"code":  "TASK_STOP_FAILED",
@@ -350,7 +465,28 @@ func (h *TaskHandler) StopTask(c *gin.Context) {
 		return
 	}
 
+	after, _ := client.GetByUUID(uuid)
+	h.publish(c, events.TaskStopped, uuid, before, after)
+	if after != nil {
+		c.Header("ETag", taskETag(after))
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "task stopped",
 	})
 }
+
+// publish emits a task change event with the task state before and after
+// the change, and adds both to the audit log entry of the request
+func (h *TaskHandler) publish(c *gin.Context, eventType events.Type, uuid string, before, after *taskwarrior.Task) {
+	if recorder := audit.RecorderFromContext(c); recorder != nil {
+		recorder.Change(uuid, before, after)
+	}
+
+	busFor(c).Publish(events.Event{
+		Type:     eventType,
+		TaskUUID: uuid,
+		Before:   before,
+		After:    after,
+	})
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"github.com/dotbinio/taskwarrior-api/internal/audit"
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/logging"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/dotbinio/taskwarrior-api/internal/tenant"
+	"github.com/dotbinio/taskwarrior-api/internal/webhooks"
+	"github.com/gin-gonic/gin"
+)
+
+// clientFor returns the Taskwarrior client of the request's tenant,
+// logging with the request's ID. When the request is audited, the
+// commands the client runs are recorded.
+func clientFor(c *gin.Context) *taskwarrior.Client {
+	client := tenant.FromContext(c).APIClient().WithLogger(logging.FromContext(c)).WithContext(c.Request.Context())
+	if recorder := audit.RecorderFromContext(c); recorder != nil {
+		return client.WithCommandRecorder(recorder.Command)
+	}
+	return client
+}
+
+// busFor returns the event bus of the request's tenant
+func busFor(c *gin.Context) *events.Bus {
+	return tenant.FromContext(c).Bus
+}
+
+// dispatcherFor returns the webhook dispatcher of the request's tenant
+func dispatcherFor(c *gin.Context) *webhooks.Dispatcher {
+	return tenant.FromContext(c).Dispatcher
+}
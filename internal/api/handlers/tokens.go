@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/auth"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/gin-gonic/gin"
+)
+
+// TokenHandler handles token management requests
+type TokenHandler struct {
+	store *auth.TokenStore
+}
+
+// NewTokenHandler creates a new token handler
+func NewTokenHandler(store *auth.TokenStore) *TokenHandler {
+	return &TokenHandler{store: store}
+}
+
+// TokenCreate represents the data needed to create a token
+type TokenCreate struct {
+	Name      string     `json:"name" binding:"required"`
+	Scope     auth.Scope `json:"scope,omitempty"`
+	Project   string     `json:"project,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// TokenSecret is a token together with its secret, which is only returned
+// when the token is created or rotated
+type TokenSecret struct {
+	auth.StoredToken
+	Token string `json:"token"`
+}
+
+// ListTokens handles GET /api/v1/tokens
+// @Summary      List tokens
+// @Description  List the tokens managed through the API for the current tenant
+// @Tags         tokens
+// @Produce      json
+// @Success      200  {array}  auth.StoredToken
+// @Security     BearerAuth
+// @Router       /tokens [get]
+func (h *TokenHandler) ListTokens(c *gin.Context) {
+	identity := auth.IdentityFromContext(c)
+
+	tokens := h.store.List(identity.Tenant)
+	for i := range tokens {
+		tokens[i].Hash = ""
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateToken handles POST /api/v1/tokens
+// @Summary      Create a token
+// @Description  Create a token for the current tenant. The token is only returned on creation.
+// @Tags         tokens
+// @Accept       json
+// @Produce      json
+// @Param        token  body  TokenCreate  true  "Token data"
+// @Success      201  {object}  TokenSecret
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      409  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /tokens [post]
+func (h *TokenHandler) CreateToken(c *gin.Context) {
+	identity := auth.IdentityFromContext(c)
+
+	var req TokenCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	if req.Scope == "" {
+		req.Scope = auth.ScopeRead
+	}
+	switch req.Scope {
+	case auth.ScopeRead, auth.ScopeWrite, auth.ScopeAdmin:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "scope must be read, write or admin",
+			"code":  "INVALID_SCOPE",
+		})
+		return
+	}
+
+	now := time.Now().UTC()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "expiry must be in the future",
+			"code":  "INVALID_EXPIRY",
+		})
+		return
+	}
+
+	for _, existing := range h.store.List(identity.Tenant) {
+		if existing.Name == req.Name {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "a token with this name already exists",
+				"code":  "TOKEN_NAME_EXISTS",
+			})
+			return
+		}
+	}
+
+	token := auth.StoredToken{
+		Name:   req.Name,
+		Tenant: identity.Tenant,
+		Scope:  req.Scope,
+		Restriction: taskwarrior.Restriction{
+			Project: req.Project,
+			Tags:    req.Tags,
+		},
+		CreatedAt: now,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	// Token IDs are short, so make sure a new one does not replace an
+	// existing token
+	var secret string
+	for {
+		id, generated, err := auth.GenerateToken()
+		if err != nil {
+			tokenStoreFailed(c)
+			return
+		}
+		if _, err := h.store.Get(id); errors.Is(err, auth.ErrTokenNotFound) {
+			token.ID = id
+			secret = generated
+			break
+		}
+	}
+
+	if !h.saveSecret(c, &token, secret) {
+		return
+	}
+
+	token.Hash = ""
+	c.JSON(http.StatusCreated, TokenSecret{StoredToken: token, Token: secret})
+}
+
+// GetToken handles GET /api/v1/tokens/:id
+// @Summary      Get a token
+// @Description  Get token metadata by ID
+// @Tags         tokens
+// @Produce      json
+// @Param        id  path  string  true  "Token ID"
+// @Success      200  {object}  auth.StoredToken
+// @Failure      404  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /tokens/{id} [get]
+func (h *TokenHandler) GetToken(c *gin.Context) {
+	token := h.tokenFor(c)
+	if token == nil {
+		return
+	}
+
+	token.Hash = ""
+	c.JSON(http.StatusOK, token)
+}
+
+// RotateToken handles POST /api/v1/tokens/:id/rotate
+// @Summary      Rotate a token
+// @Description  Replace the secret of a token, keeping its ID, name and scope. The old secret stops working immediately.
+// @Tags         tokens
+// @Produce      json
+// @Param        id  path  string  true  "Token ID"
+// @Success      200  {object}  TokenSecret
+// @Failure      404  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /tokens/{id}/rotate [post]
+func (h *TokenHandler) RotateToken(c *gin.Context) {
+	token := h.tokenFor(c)
+	if token == nil {
+		return
+	}
+
+	// Keep the ID so the new secret still selects this token
+	secret, err := auth.GenerateTokenWithID(token.ID)
+	if err != nil {
+		tokenStoreFailed(c)
+		return
+	}
+
+	now := time.Now().UTC()
+	token.RotatedAt = &now
+	if !h.saveSecret(c, token, secret) {
+		return
+	}
+
+	token.Hash = ""
+	c.JSON(http.StatusOK, TokenSecret{StoredToken: *token, Token: secret})
+}
+
+// RevokeToken handles DELETE /api/v1/tokens/:id
+// @Summary      Revoke a token
+// @Description  Delete a token. It stops working immediately.
+// @Tags         tokens
+// @Produce      json
+// @Param        id  path  string  true  "Token ID"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /tokens/{id} [delete]
+func (h *TokenHandler) RevokeToken(c *gin.Context) {
+	token := h.tokenFor(c)
+	if token == nil {
+		return
+	}
+
+	if err := h.store.Delete(token.ID); err != nil {
+		if errors.Is(err, auth.ErrTokenNotFound) {
+			tokenNotFound(c)
+			return
+		}
+		tokenStoreFailed(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "token revoked successfully",
+	})
+}
+
+// tokenFor returns the token named by the id parameter, answering 404 Not
+// Found if it does not exist or belongs to another tenant
+func (h *TokenHandler) tokenFor(c *gin.Context) *auth.StoredToken {
+	token, err := h.store.Get(c.Param("id"))
+	if err != nil || token.Tenant != auth.IdentityFromContext(c).Tenant {
+		tokenNotFound(c)
+		return nil
+	}
+	return token
+}
+
+// saveSecret hashes secret into token and saves it
+func (h *TokenHandler) saveSecret(c *gin.Context, token *auth.StoredToken, secret string) bool {
+	hash, err := auth.HashToken(secret, auth.AlgorithmArgon2id)
+	if err != nil {
+		tokenStoreFailed(c)
+		return false
+	}
+	token.Hash = hash
+
+	if err := h.store.Save(*token); err != nil {
+		tokenStoreFailed(c)
+		return false
+	}
+	return true
+}
+
+func tokenNotFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, gin.H{
+		"error": "token not found",
+		"code":  "TOKEN_NOT_FOUND",
+	})
+}
+
+func tokenStoreFailed(c *gin.Context) {
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": "failed to save token",
+		"code":  "TOKEN_STORE_FAILED",
+	})
+}
@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/webhooks"
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookHandler handles webhook management requests
+type WebhookHandler struct{}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler() *WebhookHandler {
+	return &WebhookHandler{}
+}
+
+// WebhookCreate represents the data needed to register a webhook
+type WebhookCreate struct {
+	URL    string        `json:"url" binding:"required"`
+	Events []events.Type `json:"events,omitempty"`
+	Secret string        `json:"secret,omitempty"`
+	Active *bool         `json:"active,omitempty"`
+}
+
+// WebhookModify represents the data that can be modified on a webhook
+type WebhookModify struct {
+	URL    *string       `json:"url,omitempty"`
+	Events []events.Type `json:"events,omitempty"`
+	Secret *string       `json:"secret,omitempty"`
+	Active *bool         `json:"active,omitempty"`
+}
+
+// ListWebhooks handles GET /api/v1/webhooks
+// @Summary      List webhooks
+// @Description  All registered webhooks (secrets are not returned)
+// @Tags         webhooks
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /webhooks [get]
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	store := dispatcherFor(c).Store()
+
+	list := store.ListWebhooks()
+	for i := range list {
+		list[i].Secret = ""
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks": list,
+		"count":    len(list),
+	})
+}
+
+// CreateWebhook handles POST /api/v1/webhooks
+// @Summary      Register a webhook
+// @Description  Register a URL to receive task events. The signing secret is only returned on creation.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        webhook  body  WebhookCreate  true  "Webhook data"
+// @Success      201  {object}  webhooks.Webhook
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	store := dispatcherFor(c).Store()
+
+	var req WebhookCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	if !validWebhookURL(req.URL) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "webhook URL must be an absolute http or https URL",
+			"code":  "INVALID_WEBHOOK_URL",
+		})
+		return
+	}
+
+	eventTypes := req.Events
+	if len(eventTypes) == 0 {
+		eventTypes = events.Types
+	}
+	if !validEventTypes(eventTypes) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "unknown event type",
+			"code":  "INVALID_EVENT_TYPE",
+		})
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret = webhooks.NewSecret()
+	}
+
+	now := time.Now().UTC()
+	webhook := webhooks.Webhook{
+		ID:        webhooks.NewID(),
+		URL:       req.URL,
+		Events:    eventTypes,
+		Secret:    secret,
+		Active:    req.Active == nil || *req.Active,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := store.SaveWebhook(webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to save webhook",
+			"code":  "WEBHOOK_SAVE_FAILED",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// GetWebhook handles GET /api/v1/webhooks/:id
+// @Summary      Get a webhook
+// @Description  Get webhook by ID
+// @Tags         webhooks
+// @Produce      json
+// @Param        id  path  string  true  "Webhook ID"
+// @Success      200  {object}  webhooks.Webhook
+// @Failure      404  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	store := dispatcherFor(c).Store()
+
+	webhook, err := store.GetWebhook(c.Param("id"))
+	if err != nil {
+		webhookNotFound(c)
+		return
+	}
+
+	webhook.Secret = ""
+	c.JSON(http.StatusOK, webhook)
+}
+
+// UpdateWebhook handles PATCH /api/v1/webhooks/:id
+// @Summary      Update a webhook
+// @Description  Change the URL, events, secret or active flag of a webhook
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        id       path  string         true  "Webhook ID"
+// @Param        webhook  body  WebhookModify  true  "Webhook updates"
+// @Success      200  {object}  webhooks.Webhook
+// @Failure      400  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /webhooks/{id} [patch]
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	store := dispatcherFor(c).Store()
+
+	webhook, err := store.GetWebhook(c.Param("id"))
+	if err != nil {
+		webhookNotFound(c)
+		return
+	}
+
+	var req WebhookModify
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid request body",
+			"code":  "INVALID_REQUEST",
+		})
+		return
+	}
+
+	if req.URL != nil {
+		if !validWebhookURL(*req.URL) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "webhook URL must be an absolute http or https URL",
+				"code":  "INVALID_WEBHOOK_URL",
+			})
+			return
+		}
+		webhook.URL = *req.URL
+	}
+
+	if req.Events != nil {
+		if len(req.Events) == 0 || !validEventTypes(req.Events) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "unknown event type",
+				"code":  "INVALID_EVENT_TYPE",
+			})
+			return
+		}
+		webhook.Events = req.Events
+	}
+
+	if req.Secret != nil && *req.Secret != "" {
+		webhook.Secret = *req.Secret
+	}
+
+	if req.Active != nil {
+		webhook.Active = *req.Active
+	}
+
+	webhook.UpdatedAt = time.Now().UTC()
+	if err := store.SaveWebhook(*webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to save webhook",
+			"code":  "WEBHOOK_SAVE_FAILED",
+		})
+		return
+	}
+
+	webhook.Secret = ""
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook handles DELETE /api/v1/webhooks/:id
+// @Summary      Delete a webhook
+// @Description  Unregister a webhook
+// @Tags         webhooks
+// @Produce      json
+// @Param        id  path  string  true  "Webhook ID"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	store := dispatcherFor(c).Store()
+
+	if err := store.DeleteWebhook(c.Param("id")); err != nil {
+		if err == webhooks.ErrWebhookNotFound {
+			webhookNotFound(c)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to delete webhook",
+			"code":  "WEBHOOK_DELETE_FAILED",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "webhook deleted successfully",
+	})
+}
+
+// ListDeliveries handles GET /api/v1/webhooks/:id/deliveries
+// @Summary      List webhook deliveries
+// @Description  Delivery log for a webhook, newest first
+// @Tags         webhooks
+// @Produce      json
+// @Param        id     path   string  true   "Webhook ID"
+// @Param        limit  query  int     false  "Maximum number of deliveries"  default(50)
+// @Success      200  {object}  map[string]interface{}
+// @Failure      404  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	store := dispatcherFor(c).Store()
+
+	webhookID := c.Param("id")
+	if _, err := store.GetWebhook(webhookID); err != nil {
+		webhookNotFound(c)
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "limit must be a positive integer",
+			"code":  "INVALID_LIMIT",
+		})
+		return
+	}
+
+	deliveries := store.ListDeliveries(webhookID, limit)
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	})
+}
+
+// Redeliver handles POST /api/v1/webhooks/:id/deliveries/:delivery_id/redeliver
+// @Summary      Redeliver a webhook event
+// @Description  Queue a new delivery with the payload of an earlier delivery
+// @Tags         webhooks
+// @Produce      json
+// @Param        id           path  string  true  "Webhook ID"
+// @Param        delivery_id  path  string  true  "Delivery ID"
+// @Success      202  {object}  webhooks.Delivery
+// @Failure      404  {object}  map[string]interface{}
+// @Failure      500  {object}  map[string]interface{}
+// @Security     BearerAuth
+// @Router       /webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	dispatcher := dispatcherFor(c)
+	store := dispatcher.Store()
+
+	original, err := store.GetDelivery(c.Param("delivery_id"))
+	if err != nil || original.WebhookID != c.Param("id") {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "delivery not found",
+			"code":  "DELIVERY_NOT_FOUND",
+		})
+		return
+	}
+
+	delivery, err := dispatcher.Redeliver(original.ID)
+	if err != nil {
+		if err == webhooks.ErrWebhookNotFound {
+			webhookNotFound(c)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to queue redelivery",
+			"code":  "REDELIVERY_FAILED",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+func webhookNotFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, gin.H{
+		"error": "webhook not found",
+		"code":  "WEBHOOK_NOT_FOUND",
+	})
+}
+
+// validWebhookURL checks that a webhook URL is an absolute http(s) URL
+func validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func validEventTypes(types []events.Type) bool {
+	for _, t := range types {
+		if !events.IsValidType(t) {
+			return false
+		}
+	}
+	return true
+}
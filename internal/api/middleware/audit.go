@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/audit"
+	"github.com/dotbinio/taskwarrior-api/internal/auth"
+	"github.com/dotbinio/taskwarrior-api/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditMiddleware creates a Gin middleware that records every request
+// that ran a Taskwarrior command changing tasks in the audit log
+func AuditMiddleware(auditLog *audit.Log) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+
+		recorder := &audit.Recorder{}
+		audit.SetRecorder(c, recorder)
+
+		c.Next()
+
+		identity := auth.IdentityFromContext(c)
+		entry := audit.Entry{
+			Time:      time.Now().UTC(),
+			RequestID: RequestID(c),
+			Tenant:    identity.Tenant,
+			Token:     identity.Name,
+			ClientIP:  c.ClientIP(),
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Status:    c.Writer.Status(),
+		}
+		if !recorder.Fill(&entry) {
+			return
+		}
+
+		if err := auditLog.Write(entry); err != nil {
+			logging.FromContext(c).Error("Failed to write audit log", "error", err)
+		}
+	}
+}
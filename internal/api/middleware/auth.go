@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -8,12 +10,18 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware creates a Gin middleware for token authentication
-func AuthMiddleware(validator *auth.TokenValidator) gin.HandlerFunc {
+// AuthMiddleware creates a Gin middleware for token authentication. The
+// guard may be nil.
+func AuthMiddleware(validator *auth.TokenValidator, guard *AuthGuard) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if guard.reject(c) {
+			return
+		}
+
 		// Get Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
+			guard.fail(c, false)
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "missing authorization header",
 				"code":  "MISSING_AUTH_HEADER",
@@ -25,6 +33,7 @@ func AuthMiddleware(validator *auth.TokenValidator) gin.HandlerFunc {
 		// Check if it's a Bearer token
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || parts[0] != "Bearer" {
+			guard.fail(c, false)
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "invalid authorization header format (expected 'Bearer <token>')",
 				"code":  "INVALID_AUTH_FORMAT",
@@ -36,7 +45,18 @@ func AuthMiddleware(validator *auth.TokenValidator) gin.HandlerFunc {
 		token := parts[1]
 
 		// Validate token
-		if err := validator.Validate(token); err != nil {
+		identity, err := validator.Authenticate(token)
+		if errors.Is(err, auth.ErrExpiredToken) {
+			guard.fail(c, false)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "authentication token has expired",
+				"code":  "TOKEN_EXPIRED",
+			})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			guard.fail(c, true)
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "invalid authentication token",
 				"code":  "INVALID_TOKEN",
@@ -45,6 +65,38 @@ func AuthMiddleware(validator *auth.TokenValidator) gin.HandlerFunc {
 			return
 		}
 
+		auth.SetIdentity(c, identity)
+		c.Next()
+	}
+}
+
+// BasicAuthMiddleware creates a Gin middleware that accepts the API token
+// either as a Bearer token or as the password of HTTP Basic authentication.
+// It is used for protocols such as CalDAV whose clients only support Basic.
+func BasicAuthMiddleware(validator *auth.TokenValidator, guard *AuthGuard, realm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if guard.reject(c) {
+			return
+		}
+
+		var token string
+		if _, password, ok := c.Request.BasicAuth(); ok {
+			token = password
+		} else if parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2); len(parts) == 2 && parts[0] == "Bearer" {
+			token = parts[1]
+		}
+
+		identity, err := validator.Authenticate(token)
+		if err != nil {
+			// Clients first try without credentials to get the challenge
+			guard.fail(c, token != "" && !errors.Is(err, auth.ErrExpiredToken))
+			c.Header("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", realm))
+			c.String(http.StatusUnauthorized, "unauthorized")
+			c.Abort()
+			return
+		}
+
+		auth.SetIdentity(c, identity)
 		c.Next()
 	}
 }
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"sync/atomic"
+
+	"github.com/dotbinio/taskwarrior-api/internal/config"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// CORS is a CORS middleware whose settings can be replaced while serving
+type CORS struct {
+	handler atomic.Pointer[gin.HandlerFunc]
+}
+
+// NewCORS creates a CORS middleware
+func NewCORS(cfg config.CORSConfig) (*CORS, error) {
+	m := &CORS{}
+	if err := m.Update(cfg); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Update replaces the CORS settings. Invalid settings are rejected and the
+// previous ones stay in effect.
+func (m *CORS) Update(cfg config.CORSConfig) error {
+	handler := gin.HandlerFunc(func(c *gin.Context) { c.Next() })
+
+	if cfg.Enabled {
+		corsConfig := cors.Config{
+			AllowOrigins:     cfg.AllowedOrigins,
+			AllowMethods:     []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"},
+			AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Last-Event-ID", "If-Match", "If-None-Match", "X-Request-ID"},
+			ExposeHeaders:    []string{"Content-Length", "ETag", "X-Request-ID", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
+			AllowCredentials: true,
+		}
+		// cors.New panics on invalid settings
+		if err := corsConfig.Validate(); err != nil {
+			return err
+		}
+		handler = cors.New(corsConfig)
+	}
+
+	m.handler.Store(&handler)
+	return nil
+}
+
+// Handler returns the middleware
+func (m *CORS) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		(*m.handler.Load())(c)
+	}
+}
@@ -1,41 +1,30 @@
 package middleware
 
 import (
-	"log"
+	"log/slog"
 	"time"
 
+	"github.com/dotbinio/taskwarrior-api/internal/logging"
 	"github.com/gin-gonic/gin"
 )
 
-// LoggingMiddleware creates a Gin middleware for logging requests
+// LoggingMiddleware creates a Gin middleware for logging requests. It must
+// run after RequestIDMiddleware.
 func LoggingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Start timer
 		start := time.Now()
-		path := c.Request.URL.Path
-		raw := c.Request.URL.RawQuery
 
 		// Process request
 		c.Next()
 
-		// Calculate latency
-		latency := time.Since(start)
-
-		// Get status code
-		statusCode := c.Writer.Status()
-
-		// Build query string
-		if raw != "" {
-			path = path + "?" + raw
-		}
-
-		// Log request
-		log.Printf("[%s] %d | %v | %s | %s",
-			c.Request.Method,
-			statusCode,
-			latency,
-			c.ClientIP(),
-			path,
+		// The query is left out, as it may contain task filters
+		logging.FromContext(c).LogAttrs(c.Request.Context(), slog.LevelInfo, "Request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", c.Writer.Status()),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
 		)
 	}
 }
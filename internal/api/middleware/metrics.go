@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	httpRequests = metrics.NewCounterVec("http_requests_total",
+		"HTTP requests, by method, route and status.", "method", "route", "status")
+	httpDuration = metrics.NewHistogramVec("http_request_duration_seconds",
+		"Duration of HTTP requests, by method, route and status.", metrics.DefaultBuckets, "method", "route", "status")
+)
+
+// MetricsMiddleware creates a Gin middleware counting requests and
+// measuring their latency. Requests are labelled with their route pattern
+// rather than the path, so task UUIDs do not create new series.
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequests.Inc(c.Request.Method, route, status)
+		httpDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route, status)
+	}
+}
+
+// MetricsAuthMiddleware creates a Gin middleware requiring the metrics
+// token as a Bearer token. API tokens are not accepted, so scrapers never
+// hold credentials for task data.
+func MetricsAuthMiddleware(token string) gin.HandlerFunc {
+	want := sha256.Sum256([]byte(token))
+
+	return func(c *gin.Context) {
+		given, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		got := sha256.Sum256([]byte(given))
+		if subtle.ConstantTimeCompare(got[:], want[:]) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid metrics token",
+				"code":  "INVALID_TOKEN",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
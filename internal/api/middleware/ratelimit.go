@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/auth"
+	"github.com/dotbinio/taskwarrior-api/internal/config"
+	"github.com/dotbinio/taskwarrior-api/internal/logging"
+	"github.com/dotbinio/taskwarrior-api/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// AuthGuard protects authentication against token guessing. Requests
+// failing authentication are rate limited per client IP, and clients
+// sending invalid tokens repeatedly are locked out. A nil guard allows
+// everything.
+type AuthGuard struct {
+	failures *ratelimit.Limiter
+	lockout  *ratelimit.Lockout
+}
+
+// NewAuthGuard creates an auth guard, or returns nil if rate limiting is
+// disabled
+func NewAuthGuard(cfg config.RateLimitConfig) *AuthGuard {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	guard := &AuthGuard{failures: ratelimit.NewLimiter(cfg.Failures)}
+	if cfg.LockoutThreshold > 0 {
+		guard.lockout = ratelimit.NewLockout(cfg.LockoutThreshold, cfg.LockoutDuration, cfg.LockoutMax)
+	}
+	return guard
+}
+
+// reject answers 429 Too Many Requests before authentication when the
+// client is locked out or has used up its allowance of failures. Valid
+// tokens are rejected too, so guesses cannot be confirmed while blocked.
+func (g *AuthGuard) reject(c *gin.Context) bool {
+	if g == nil {
+		return false
+	}
+	key := clientKey(c)
+
+	if g.lockout != nil {
+		if locked := g.lockout.Locked(key); locked > 0 {
+			tooManyRequests(c, locked, "too many invalid tokens, try again later", "LOCKED_OUT")
+			return true
+		}
+	}
+
+	if result := g.failures.Peek(key); !result.Allowed {
+		setRateLimitHeaders(c, result)
+		tooManyRequests(c, result.RetryAfter, "too many failed authentication attempts", "RATE_LIMITED")
+		return true
+	}
+
+	return false
+}
+
+// fail records a failed authentication. Invalid tokens count towards the
+// lockout; missing or malformed credentials only towards the rate limit.
+func (g *AuthGuard) fail(c *gin.Context, invalidToken bool) {
+	if g == nil {
+		return
+	}
+	key := clientKey(c)
+
+	g.failures.Allow(key)
+	if invalidToken && g.lockout != nil {
+		if locked := g.lockout.Fail(key); locked > 0 {
+			logging.FromContext(c).Warn("Locking out client after repeated invalid tokens", "client", key, "duration", locked)
+		}
+	}
+}
+
+// RateLimitMiddleware creates a Gin middleware limiting the requests of
+// each token to the limit of its scope
+func RateLimitMiddleware(cfg config.RateLimitConfig) gin.HandlerFunc {
+	limiters := map[auth.Scope]*ratelimit.Limiter{
+		auth.ScopeRead:  ratelimit.NewLimiter(cfg.Read),
+		auth.ScopeWrite: ratelimit.NewLimiter(cfg.Write),
+		auth.ScopeAdmin: ratelimit.NewLimiter(cfg.Admin),
+	}
+
+	return func(c *gin.Context) {
+		identity := auth.IdentityFromContext(c)
+		limiter, ok := limiters[identity.Scope]
+		if !ok {
+			limiter = limiters[auth.ScopeRead]
+		}
+
+		// Token names are only unique within a tenant
+		result := limiter.Allow(identity.Tenant + "/" + identity.Name)
+		setRateLimitHeaders(c, result)
+		if !result.Allowed {
+			tooManyRequests(c, result.RetryAfter, "rate limit exceeded", "RATE_LIMITED")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// setRateLimitHeaders sets the RateLimit header fields of the IETF
+// httpapi-ratelimit-headers draft
+func setRateLimitHeaders(c *gin.Context, result ratelimit.Result) {
+	c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", seconds(result.Reset))
+	c.Header("RateLimit-Policy", strconv.Itoa(result.Limit)+";w=60")
+}
+
+func tooManyRequests(c *gin.Context, retryAfter time.Duration, message, code string) {
+	c.Header("Retry-After", seconds(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error": message,
+		"code":  code,
+	})
+	c.Abort()
+}
+
+// seconds formats d as whole seconds, rounded up
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// clientKey identifies the client of a request by IP. IPv6 clients are
+// grouped by /64, as a single host usually controls a whole /64.
+func clientKey(c *gin.Context) string {
+	ip := net.ParseIP(c.ClientIP())
+	if ip == nil {
+		return c.ClientIP()
+	}
+	if ip.To4() == nil {
+		return ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+	}
+	return ip.String()
+}
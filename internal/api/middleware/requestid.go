@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+
+	"github.com/dotbinio/taskwarrior-api/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+const requestIDKey = "request_id"
+
+// RequestIDMiddleware creates a Gin middleware that assigns every request
+// an ID, taken from the X-Request-ID header when a proxy already set one,
+// and echoes it in the response. Log records of the request carry the ID.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		c.Set(requestIDKey, id)
+		logging.SetLogger(c, slog.Default().With("request_id", id))
+		c.Header("X-Request-ID", id)
+		c.Next()
+	}
+}
+
+// RequestID returns the ID of the request
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID accepts IDs of up to 128 printable ASCII characters, so
+// client-supplied IDs cannot inject anything into logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/dotbinio/taskwarrior-api/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope creates a Gin middleware rejecting tokens without at least
+// the required scope
+func RequireScope(required auth.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.IdentityFromContext(c).Scope.Includes(required) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "token does not have the " + string(required) + " scope",
+				"code":  "INSUFFICIENT_SCOPE",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireUnrestricted creates a Gin middleware rejecting tokens restricted
+// to a project or tags, for endpoints that cannot apply the restriction
+func RequireUnrestricted() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.IdentityFromContext(c).Restriction.IsZero() {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "endpoint is not available to tokens restricted to a project or tags",
+				"code":  "RESTRICTED_TOKEN",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
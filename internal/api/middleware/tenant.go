@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/dotbinio/taskwarrior-api/internal/auth"
+	"github.com/dotbinio/taskwarrior-api/internal/tenant"
+	"github.com/gin-gonic/gin"
+)
+
+// TenantMiddleware resolves the tenant of the authenticated identity. It
+// must run after AuthMiddleware or BasicAuthMiddleware.
+func TenantMiddleware(registry *tenant.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := registry.Get(auth.IdentityFromContext(c).Tenant)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid authentication token",
+				"code":  "INVALID_TOKEN",
+			})
+			c.Abort()
+			return
+		}
+
+		tenant.SetContext(c, t)
+		c.Next()
+	}
+}
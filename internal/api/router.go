@@ -1,12 +1,18 @@
 package api
 
 import (
+	"log/slog"
+	"net/http"
+
 	"github.com/dotbinio/taskwarrior-api/internal/api/handlers"
 	"github.com/dotbinio/taskwarrior-api/internal/api/middleware"
+	"github.com/dotbinio/taskwarrior-api/internal/audit"
 	"github.com/dotbinio/taskwarrior-api/internal/auth"
+	"github.com/dotbinio/taskwarrior-api/internal/caldav"
 	"github.com/dotbinio/taskwarrior-api/internal/config"
-	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
-	"github.com/gin-contrib/cors"
+	"github.com/dotbinio/taskwarrior-api/internal/logging"
+	"github.com/dotbinio/taskwarrior-api/internal/metrics"
+	"github.com/dotbinio/taskwarrior-api/internal/tenant"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -15,16 +21,19 @@ import (
 )
 
 // SetupRouter creates and configures the Gin router
-func SetupRouter(cfg *config.Config, twClient *taskwarrior.Client, validator *auth.TokenValidator) *gin.Engine {
-	// Set Gin mode
-	if cfg.Logging.Level == "debug" {
-		gin.SetMode(gin.DebugMode)
-	} else {
-		gin.SetMode(gin.ReleaseMode)
-	}
+func SetupRouter(cfg *config.Config, registry *tenant.Registry, validator *auth.TokenValidator, tokenStore *auth.TokenStore, auditLog *audit.Log, corsMiddleware *middleware.CORS) *gin.Engine {
+	// Gin's debug output is not structured; requests are logged by
+	// LoggingMiddleware instead
+	gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New()
 
+	// Only trust X-Forwarded-For from configured proxies, so clients cannot
+	// pick the IP they are rate limited by
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		slog.Error("Invalid trusted proxies", "error", err)
+	}
+
 	// Load HTML templates if UI is enabled
 	if cfg.Server.EnableUI {
 		router.LoadHTMLGlob("web/templates/*")
@@ -33,21 +42,23 @@ func SetupRouter(cfg *config.Config, twClient *taskwarrior.Client, validator *au
 	// Recovery middleware
 	router.Use(gin.Recovery())
 
+	// Request IDs, for correlating logs and audit entries
+	router.Use(middleware.RequestIDMiddleware())
+
+	// Tracing, before logging so request logs carry the trace ID
+	router.Use(middleware.TracingMiddleware())
+
 	// Logging middleware
 	router.Use(middleware.LoggingMiddleware())
 
-	// CORS middleware
-	if cfg.CORS.Enabled {
-		corsConfig := cors.Config{
-			AllowOrigins:     cfg.CORS.AllowedOrigins,
-			AllowMethods:     []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"},
-			AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-			ExposeHeaders:    []string{"Content-Length"},
-			AllowCredentials: true,
-		}
-		router.Use(cors.New(corsConfig))
+	// Request counts and latencies
+	if cfg.Metrics.Enabled {
+		router.Use(middleware.MetricsMiddleware())
 	}
 
+	// CORS middleware, reconfigured when the configuration is reloaded
+	router.Use(corsMiddleware.Handler())
+
 	// Embedded UI (no auth required, disabled by default)
 	uiHandler := handlers.NewUIHandler(cfg.Server.EnableUI)
 	router.GET("/", uiHandler.ServeUI)
@@ -60,31 +71,67 @@ func SetupRouter(cfg *config.Config, twClient *taskwarrior.Client, validator *au
 		})
 	})
 
+	// Liveness and readiness probes (no auth required)
+	healthHandler := handlers.NewHealthHandler(registry)
+	router.GET("/livez", healthHandler.Livez)
+	router.GET("/readyz", healthHandler.Readyz)
+
 	// Swagger documentation (no auth required)
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// Metrics, unless they are served on their own address
+	if cfg.Metrics.Enabled && cfg.Metrics.Address == "" {
+		router.GET("/metrics", middleware.MetricsAuthMiddleware(cfg.Metrics.Token), gin.WrapH(metrics.Default.Handler()))
+	}
+
+	// Rate limits per token, and per client IP for failed authentication
+	authGuard := middleware.NewAuthGuard(cfg.RateLimit)
+	rateLimit := func(c *gin.Context) { c.Next() }
+	if cfg.RateLimit.Enabled {
+		rateLimit = middleware.RateLimitMiddleware(cfg.RateLimit)
+	}
+
 	// API v1 routes
 	v1 := router.Group("/api/v1")
-	v1.Use(middleware.AuthMiddleware(validator))
+	v1.Use(middleware.AuthMiddleware(validator, authGuard))
+	v1.Use(rateLimit)
+	v1.Use(middleware.TenantMiddleware(registry))
+	if auditLog != nil {
+		v1.Use(middleware.AuditMiddleware(auditLog))
+	}
 
 	// Initialize handlers
-	taskHandler := handlers.NewTaskHandler(twClient)
-	reportHandler := handlers.NewReportHandler(twClient)
-	projectHandler := handlers.NewProjectHandler(twClient)
+	taskHandler := handlers.NewTaskHandler()
+	reportHandler := handlers.NewReportHandler()
+	projectHandler := handlers.NewProjectHandler()
+	eventHandler := handlers.NewEventHandler()
+	changesHandler := handlers.NewChangesHandler()
+	exportHandler := handlers.NewExportHandler()
+	graphQLHandler := handlers.NewGraphQLHandler()
+
+	// Every token may read; mutations need the write scope
+	requireWrite := middleware.RequireScope(auth.ScopeWrite)
 
 	// Task routes
 	tasks := v1.Group("/tasks")
 	{
 		tasks.GET("", taskHandler.ListTasks)
-		tasks.POST("", taskHandler.CreateTask)
+		tasks.POST("", requireWrite, taskHandler.CreateTask)
 		tasks.GET("/:uuid", taskHandler.GetTask)
-		tasks.PATCH("/:uuid", taskHandler.UpdateTask)
-		tasks.DELETE("/:uuid", taskHandler.DeleteTask)
-		tasks.POST("/:uuid/done", taskHandler.DoneTask)
-		tasks.POST("/:uuid/start", taskHandler.StartTask)
-		tasks.POST("/:uuid/stop", taskHandler.StopTask)
+		tasks.PATCH("/:uuid", requireWrite, taskHandler.UpdateTask)
+		tasks.DELETE("/:uuid", requireWrite, taskHandler.DeleteTask)
+		tasks.POST("/:uuid/done", requireWrite, taskHandler.DoneTask)
+		tasks.POST("/:uuid/start", requireWrite, taskHandler.StartTask)
+		tasks.POST("/:uuid/stop", requireWrite, taskHandler.StopTask)
 	}
 
+	// Export in other formats, streamed
+	v1.GET("/export", exportHandler.Export)
+
+	// GraphQL over the same client; mutations check the write scope
+	// themselves
+	v1.POST("/graphql", graphQLHandler.Query)
+
 	// Report routes
 	reports := v1.Group("/reports")
 	{
@@ -99,5 +146,127 @@ func SetupRouter(cfg *config.Config, twClient *taskwarrior.Client, validator *au
 		projects.GET("/:name/tasks", projectHandler.GetProjectTasks)
 	}
 
+	// Event stream
+	v1.GET("/events", eventHandler.Stream)
+
+	// Delta sync
+	v1.GET("/changes", changesHandler.GetChanges)
+
+	// Bulk import, not available to restricted tokens since tasks are
+	// matched by UUID alone
+	importHandler := handlers.NewImportHandler()
+	imports := v1.Group("/import")
+	imports.Use(requireWrite, middleware.RequireUnrestricted())
+	{
+		imports.POST("", importHandler.ImportTasks)
+		imports.POST("/todotxt", importHandler.ImportTodoTxt)
+		imports.POST("/csv", importHandler.ImportCSV)
+	}
+
+	// Sync routes, not available to restricted tokens since sync covers
+	// all tasks
+	if cfg.Sync.Enabled {
+		syncHandler := handlers.NewSyncHandler()
+		syncGroup := v1.Group("/sync")
+		syncGroup.Use(middleware.RequireUnrestricted())
+		{
+			syncGroup.POST("", requireWrite, syncHandler.Sync)
+			syncGroup.GET("/status", syncHandler.GetStatus)
+		}
+	}
+
+	// Webhook routes (admin only), not available to restricted tokens
+	// since webhooks receive the events of all tasks
+	if cfg.Webhooks.Enabled {
+		webhookHandler := handlers.NewWebhookHandler()
+		hooks := v1.Group("/webhooks")
+		hooks.Use(middleware.RequireScope(auth.ScopeAdmin), middleware.RequireUnrestricted())
+		{
+			hooks.GET("", webhookHandler.ListWebhooks)
+			hooks.POST("", webhookHandler.CreateWebhook)
+			hooks.GET("/:id", webhookHandler.GetWebhook)
+			hooks.PATCH("/:id", webhookHandler.UpdateWebhook)
+			hooks.DELETE("/:id", webhookHandler.DeleteWebhook)
+			hooks.GET("/:id/deliveries", webhookHandler.ListDeliveries)
+			hooks.POST("/:id/deliveries/:delivery_id/redeliver", webhookHandler.Redeliver)
+		}
+	}
+
+	// Diagnostics (admin only)
+	v1.GET("/diagnostics", middleware.RequireScope(auth.ScopeAdmin), healthHandler.GetDiagnostics)
+
+	// Audit log (admin only)
+	if auditLog != nil {
+		auditHandler := handlers.NewAuditHandler(auditLog)
+		v1.GET("/audit", middleware.RequireScope(auth.ScopeAdmin), middleware.RequireUnrestricted(), auditHandler.ListEntries)
+	}
+
+	// Token management routes (admin only). Restricted tokens could
+	// otherwise create tokens without their restriction.
+	if tokenStore != nil {
+		tokenHandler := handlers.NewTokenHandler(tokenStore)
+		tokens := v1.Group("/tokens")
+		tokens.Use(middleware.RequireScope(auth.ScopeAdmin))
+		tokens.Use(middleware.RequireUnrestricted())
+		{
+			tokens.GET("", tokenHandler.ListTokens)
+			tokens.POST("", tokenHandler.CreateToken)
+			tokens.GET("/:id", tokenHandler.GetToken)
+			tokens.POST("/:id/rotate", tokenHandler.RotateToken)
+			tokens.DELETE("/:id", tokenHandler.RevokeToken)
+		}
+	}
+
+	// CalDAV routes (Basic or Bearer auth, disabled by default)
+	if cfg.CalDAV.Enabled {
+		// Each request is served over the data of its tenant, recording
+		// the commands run when the request is audited
+		caldavHandler := func(c *gin.Context) {
+			client := tenant.FromContext(c).Client.WithLogger(logging.FromContext(c)).WithContext(c.Request.Context())
+			if recorder := audit.RecorderFromContext(c); recorder != nil {
+				client = client.WithCommandRecorder(recorder.Command)
+			}
+			caldav.NewHandler(client, "/caldav").ServeHTTP(c.Writer, c.Request)
+		}
+
+		router.GET("/.well-known/caldav", func(c *gin.Context) {
+			c.Redirect(http.StatusMovedPermanently, "/caldav/")
+		})
+
+		dav := router.Group("/caldav")
+		dav.Use(middleware.BasicAuthMiddleware(validator, authGuard, "taskwarrior-api"))
+		dav.Use(rateLimit)
+		dav.Use(middleware.TenantMiddleware(registry))
+		if auditLog != nil {
+			dav.Use(middleware.AuditMiddleware(auditLog))
+		}
+		// Collections map to whole projects, so restricted tokens cannot
+		// be served
+		dav.Use(middleware.RequireUnrestricted())
+		for _, method := range caldav.Methods {
+			switch method {
+			case http.MethodPut, http.MethodDelete:
+				dav.Handle(method, "/*path", requireWrite, caldavHandler)
+			default:
+				dav.Handle(method, "/*path", caldavHandler)
+			}
+		}
+	}
+
+	return router
+}
+
+// SetupMetricsRouter configures the router for the metrics address. The
+// metrics token is only required when one is configured.
+func SetupMetricsRouter(cfg *config.Config) *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery())
+
+	chain := []gin.HandlerFunc{gin.WrapH(metrics.Default.Handler())}
+	if cfg.Metrics.Token != "" {
+		chain = append([]gin.HandlerFunc{middleware.MetricsAuthMiddleware(cfg.Metrics.Token)}, chain...)
+	}
+	router.GET("/metrics", chain...)
+
 	return router
 }
@@ -0,0 +1,237 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry records one request that changed tasks
+type Entry struct {
+	Time      time.Time  `json:"time"`
+	RequestID string     `json:"request_id"`
+	Tenant    string     `json:"tenant"`
+	Token     string     `json:"token"`
+	ClientIP  string     `json:"client_ip"`
+	Method    string     `json:"method"`
+	Path      string     `json:"path"`
+	Status    int        `json:"status"`
+	TaskUUID  string     `json:"task_uuid,omitempty"`
+	Commands  [][]string `json:"commands"`
+	// Before and After hold the task as the API returns it
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// Filter selects audit entries. Zero fields match everything.
+type Filter struct {
+	Tenant   string
+	TaskUUID string
+	Token    string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+func (f Filter) matches(e *Entry) bool {
+	switch {
+	case f.Tenant != "" && e.Tenant != f.Tenant:
+		return false
+	case f.TaskUUID != "" && e.TaskUUID != f.TaskUUID:
+		return false
+	case f.Token != "" && e.Token != f.Token:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// Log is an append-only audit log stored as JSON Lines. The current file
+// is audit.jsonl; once it exceeds maxSize it is renamed with a timestamp
+// and a new file is started. Only the newest maxFiles rotated files are
+// kept, or all of them if maxFiles is 0.
+type Log struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewLog opens or creates an audit log in dir
+func NewLog(dir string, maxSize int64, maxFiles int) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	l := &Log{dir: dir, maxSize: maxSize, maxFiles: maxFiles}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Close closes the log
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// Write appends an entry
+func (l *Log) Write(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.size > 0 && l.size+int64(len(data)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+	return err
+}
+
+// Query returns the entries matching filter, newest first
+func (l *Log) Query(filter Filter) ([]Entry, error) {
+	// Files are only appended to, so they can be read while entries are
+	// written. A file removed by rotation in the meantime is skipped.
+	l.mu.Lock()
+	files, err := l.rotated()
+	l.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, l.path())
+
+	// Read from the newest file backwards and stop once enough entries
+	// were found
+	result := make([]Entry, 0)
+	for i := len(files) - 1; i >= 0; i-- {
+		entries, err := readEntries(files[i], filter)
+		if err != nil {
+			return nil, err
+		}
+		for j := len(entries) - 1; j >= 0; j-- {
+			result = append(result, entries[j])
+			if filter.Limit > 0 && len(result) == filter.Limit {
+				return result, nil
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (l *Log) path() string {
+	return filepath.Join(l.dir, "audit.jsonl")
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+// rotate moves the current file aside and starts a new one
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("audit-%s.jsonl", time.Now().UTC().Format("20060102T150405.000000000"))
+	if err := os.Rename(l.path(), filepath.Join(l.dir, name)); err != nil {
+		return err
+	}
+	if err := l.open(); err != nil {
+		return err
+	}
+
+	if l.maxFiles > 0 {
+		files, err := l.rotated()
+		if err != nil {
+			return err
+		}
+		for len(files) > l.maxFiles {
+			if err := os.Remove(files[0]); err != nil {
+				return err
+			}
+			files = files[1:]
+		}
+	}
+
+	return nil
+}
+
+// rotated returns the rotated files, oldest first
+func (l *Log) rotated() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(l.dir, "audit-*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readEntries reads the entries of a file that match filter, ignoring the
+// limit
+func readEntries(path string, filter Filter) ([]Entry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		// Cheap pre-check before decoding the whole entry
+		if filter.TaskUUID != "" && !strings.Contains(string(line), filter.TaskUUID) {
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			continue
+		}
+		if filter.matches(&e) {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, scanner.Err()
+}
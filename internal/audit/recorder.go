@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/gin-gonic/gin"
+)
+
+const recorderKey = "audit"
+
+// Recorder collects what a single request changed
+type Recorder struct {
+	mu       sync.Mutex
+	commands [][]string
+	taskUUID string
+	before   json.RawMessage
+	after    json.RawMessage
+}
+
+// Command records the argv of a command that was run
+func (r *Recorder) Command(argv []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands = append(r.commands, slices.Clone(argv))
+}
+
+// Change records the task affected by the request and its state before
+// and after the change
+func (r *Recorder) Change(uuid string, before, after *taskwarrior.Task) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.taskUUID = uuid
+	r.before = marshalTask(before)
+	r.after = marshalTask(after)
+}
+
+// Fill copies what was recorded into e and reports whether any command
+// was run
+func (r *Recorder) Fill(e *Entry) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e.Commands = r.commands
+	e.TaskUUID = r.taskUUID
+	e.Before = r.before
+	e.After = r.after
+	return len(r.commands) > 0
+}
+
+func marshalTask(task *taskwarrior.Task) json.RawMessage {
+	if task == nil {
+		return nil
+	}
+	data, err := json.Marshal(task)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// SetRecorder stores the request's recorder in the Gin context
+func SetRecorder(c *gin.Context, r *Recorder) {
+	c.Set(recorderKey, r)
+}
+
+// RecorderFromContext returns the request's recorder, or nil if the
+// request is not audited
+func RecorderFromContext(c *gin.Context) *Recorder {
+	if r, ok := c.Get(recorderKey); ok {
+		return r.(*Recorder)
+	}
+	return nil
+}
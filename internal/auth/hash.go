@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported token hash algorithms
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// Argon2id parameters, following the second recommended option of RFC 9106
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// tokenPrefix starts every generated token. The prefix is followed by a
+// public ID that selects the hash to verify against, so a request costs a
+// single hash computation however many tokens are configured.
+const tokenPrefix = "tw_"
+
+var ErrUnsupportedHash = errors.New("unsupported token hash")
+
+// GenerateToken creates a new random token and its public ID
+func GenerateToken() (id, token string, err error) {
+	idBytes := make([]byte, 4)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", err
+	}
+
+	id = hex.EncodeToString(idBytes)
+	token, err = GenerateTokenWithID(id)
+	return id, token, err
+}
+
+// GenerateTokenWithID creates a new random token carrying an existing
+// public ID, as needed to rotate a token
+func GenerateTokenWithID(id string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return tokenPrefix + id + "_" + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// TokenID returns the public ID of a generated token, or an empty string
+// for tokens in any other format
+func TokenID(token string) string {
+	rest, ok := strings.CutPrefix(token, tokenPrefix)
+	if !ok {
+		return ""
+	}
+	id, _, ok := strings.Cut(rest, "_")
+	if !ok {
+		return ""
+	}
+	return id
+}
+
+// IsHash reports whether s looks like a supported token hash
+func IsHash(s string) bool {
+	return strings.HasPrefix(s, "$argon2id$") || strings.HasPrefix(s, "$2a$") ||
+		strings.HasPrefix(s, "$2b$") || strings.HasPrefix(s, "$2y$")
+}
+
+// HashToken hashes a token with a random salt using algorithm
+func HashToken(token, algorithm string) (string, error) {
+	switch algorithm {
+	case AlgorithmArgon2id:
+		salt := make([]byte, argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(token), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, argon2Memory, argon2Time, argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key)), nil
+	case AlgorithmBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(token), bcrypt.DefaultCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	default:
+		return "", fmt.Errorf("unknown hash algorithm: %s", algorithm)
+	}
+}
+
+// VerifyHash checks token against a hash produced by HashToken. The
+// comparison takes constant time.
+func VerifyHash(hash, token string) (bool, error) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return verifyArgon2id(hash, token)
+	}
+	if IsHash(hash) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(token))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return false, ErrUnsupportedHash
+}
+
+// verifyArgon2id checks token against a hash in PHC string format
+func verifyArgon2id(hash, token string) (bool, error) {
+	// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, ErrUnsupportedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, ErrUnsupportedHash
+	}
+
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, ErrUnsupportedHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrUnsupportedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, ErrUnsupportedHash
+	}
+
+	computed := argon2.IDKey([]byte(token), salt, time, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(computed, key) == 1, nil
+}
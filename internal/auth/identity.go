@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/gin-gonic/gin"
+)
+
+// Scope is the level of access granted to a token
+type Scope string
+
+// Token scopes, from least to most powerful
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	ScopeAdmin Scope = "admin"
+)
+
+var scopeLevels = map[Scope]int{
+	ScopeRead:  1,
+	ScopeWrite: 2,
+	ScopeAdmin: 3,
+}
+
+// Includes reports whether s grants at least the access of required
+func (s Scope) Includes(required Scope) bool {
+	return scopeLevels[s] >= scopeLevels[required]
+}
+
+// Identity is the authenticated principal behind a token
+type Identity struct {
+	Name        string                  `json:"name"`
+	Tenant      string                  `json:"tenant"`
+	Scope       Scope                   `json:"scope"`
+	Restriction taskwarrior.Restriction `json:"restriction"`
+}
+
+// identityKey is the gin context key holding the request's identity
+const identityKey = "identity"
+
+// SetIdentity stores the authenticated identity in the gin context
+func SetIdentity(c *gin.Context, identity *Identity) {
+	c.Set(identityKey, identity)
+}
+
+// IdentityFromContext returns the authenticated identity of the request.
+// It panics when the request has not been authenticated, as that means a
+// route is missing the auth middleware.
+func IdentityFromContext(c *gin.Context) *Identity {
+	return c.MustGet(identityKey).(*Identity)
+}
@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksRetryInterval limits how often the key set is fetched again after a
+// failed load or when a token names an unknown key, so forged tokens
+// cannot make the server hammer the identity provider
+const jwksRetryInterval = time.Minute
+
+// minRSAKeyBits is the smallest RSA key accepted for signatures
+const minRSAKeyBits = 2048
+
+var errUnknownKey = errors.New("no key matches the token")
+
+// publicKey is a signature verification key from a key set
+type publicKey struct {
+	id  string
+	alg string
+	key crypto.PublicKey
+}
+
+// KeySet holds the keys tokens are verified with. Keys are loaded from a
+// JWKS or PEM file, a JWKS URL, or the jwks_uri announced by the issuer's
+// discovery document, and reloaded periodically.
+type KeySet struct {
+	file    string
+	url     string
+	issuer  string
+	refresh time.Duration
+	client  *http.Client
+
+	mu          sync.Mutex
+	keys        []publicKey
+	loadedAt    time.Time
+	attemptedAt time.Time
+}
+
+// NewKeySet creates a key set. When neither file nor url is given, the JWKS
+// URL is discovered from the issuer.
+func NewKeySet(file, url, issuer string, refresh time.Duration) *KeySet {
+	return &KeySet{
+		file:    file,
+		url:     url,
+		issuer:  issuer,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Load loads the keys immediately
+func (ks *KeySet) Load() error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.load()
+}
+
+// lookup returns the keys a token signed with alg and kid may be verified
+// with. An unknown kid triggers a reload, as the provider may have rotated
+// its keys.
+func (ks *KeySet) lookup(kid, alg string) ([]publicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(ks.loadedAt) > ks.refresh && now.Sub(ks.attemptedAt) > jwksRetryInterval {
+		if err := ks.load(); err != nil {
+			slog.Error("Failed to load JWKS", "error", err)
+		}
+	}
+
+	keys := ks.match(kid, alg)
+	if len(keys) == 0 && kid != "" && now.Sub(ks.attemptedAt) > jwksRetryInterval {
+		if err := ks.load(); err != nil {
+			slog.Error("Failed to load JWKS", "error", err)
+		}
+		keys = ks.match(kid, alg)
+	}
+	if len(keys) == 0 {
+		return nil, errUnknownKey
+	}
+
+	return keys, nil
+}
+
+func (ks *KeySet) match(kid, alg string) []publicKey {
+	var keys []publicKey
+	for _, key := range ks.keys {
+		if kid != "" && key.id != kid {
+			continue
+		}
+		if key.alg != "" && key.alg != alg {
+			continue
+		}
+		if !keyMatchesAlg(key.key, alg) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// load replaces the keys. The previous keys are kept if loading fails.
+func (ks *KeySet) load() error {
+	ks.attemptedAt = time.Now()
+
+	var data []byte
+	var err error
+	if ks.file != "" {
+		data, err = os.ReadFile(ks.file)
+	} else {
+		data, err = ks.fetch()
+	}
+	if err != nil {
+		return err
+	}
+
+	var keys []publicKey
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "-----BEGIN") {
+		keys, err = parsePEMKeys(data)
+	} else {
+		keys, err = parseJWKS(data)
+	}
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("key set contains no usable keys")
+	}
+
+	ks.keys = keys
+	ks.loadedAt = ks.attemptedAt
+	return nil
+}
+
+// fetch downloads the JWKS, discovering its URL first if needed
+func (ks *KeySet) fetch() ([]byte, error) {
+	if ks.url == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		data, err := ks.get(strings.TrimSuffix(ks.issuer, "/") + "/.well-known/openid-configuration")
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &discovery); err != nil || discovery.JWKSURI == "" {
+			return nil, fmt.Errorf("discovery document of %s has no jwks_uri", ks.issuer)
+		}
+		ks.url = discovery.JWKSURI
+	}
+
+	return ks.get(ks.url)
+}
+
+func (ks *KeySet) get(url string) ([]byte, error) {
+	resp, err := ks.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// jwk is a JSON Web Key (RFC 7517)
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS parses a JWKS document, skipping keys that are not signature
+// keys or use unsupported types
+func parseJWKS(data []byte) ([]publicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	var keys []publicKey
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			slog.Warn("Skipping JWKS key", "kid", k.Kid, "error", err)
+			continue
+		}
+		keys = append(keys, publicKey{id: k.Kid, alg: k.Alg, key: key})
+	}
+
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		key := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key is shorter than %d bits", minRSAKeyBits)
+		}
+		return key, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, fmt.Errorf("invalid EC coordinates")
+		}
+		point := append(append([]byte{4}, x...), y...)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// parsePEMKeys parses PEM encoded public keys and certificates. PEM keys
+// have no ID and are tried for every token.
+func parsePEMKeys(data []byte) ([]publicKey, error) {
+	var keys []publicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		var key crypto.PublicKey
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			cert, err = x509.ParseCertificate(block.Bytes)
+			if err == nil {
+				key = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PEM %s: %w", block.Type, err)
+		}
+		if rsaKey, ok := key.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key is shorter than %d bits", minRSAKeyBits)
+		}
+
+		keys = append(keys, publicKey{key: key})
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,325 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/config"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+// JWTVerifier authenticates JWT bearer tokens issued by an OIDC provider.
+// Tokens must be signed with an asymmetric algorithm by a key of the
+// issuer's key set, name the configured issuer and audience, and carry an
+// expiry.
+type JWTVerifier struct {
+	cfg  config.OIDCConfig
+	keys *KeySet
+}
+
+// NewJWTVerifier creates a verifier and loads the issuer's keys. A key set
+// given as a file must load; a remote key set that cannot be fetched yet is
+// retried on first use.
+func NewJWTVerifier(cfg config.OIDCConfig) (*JWTVerifier, error) {
+	keys := NewKeySet(config.ExpandPath(cfg.JWKSFile), cfg.JWKSURL, cfg.Issuer, cfg.JWKSRefresh)
+	if err := keys.Load(); err != nil {
+		if cfg.JWKSFile != "" {
+			return nil, err
+		}
+		slog.Warn("Failed to load JWKS, retrying on first use", "error", err)
+	}
+
+	return &JWTVerifier{cfg: cfg, keys: keys}, nil
+}
+
+// looksLikeJWT reports whether token has the shape of a signed JWT, whose
+// header always starts with the encoding of `{"`
+func looksLikeJWT(token string) bool {
+	return strings.HasPrefix(token, "eyJ") && strings.Count(token, ".") == 2
+}
+
+// Verify checks a JWT and returns the identity described by its claims
+func (v *JWTVerifier) Verify(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	keys, err := v.keys.lookup(header.Kid, header.Alg)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range keys {
+		if verifySignature(header.Alg, key.key, signed, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrInvalidToken
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return v.identity(claims)
+}
+
+// checkClaims validates the registered claims
+func (v *JWTVerifier) checkClaims(claims map[string]any) error {
+	if iss, _ := claims["iss"].(string); iss != v.cfg.Issuer {
+		return ErrInvalidToken
+	}
+
+	if !hasAudience(claims["aud"], v.cfg.Audience) {
+		return ErrInvalidToken
+	}
+
+	now := time.Now()
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return ErrInvalidToken
+	}
+	if now.After(exp.Add(v.cfg.Leeway)) {
+		return ErrExpiredToken
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Before(nbf.Add(-v.cfg.Leeway)) {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+// identity maps the claims to an identity
+func (v *JWTVerifier) identity(claims map[string]any) (*Identity, error) {
+	name, _ := lookupClaim(claims, v.cfg.NameClaim).(string)
+	if name == "" {
+		name, _ = claims["sub"].(string)
+	}
+
+	// The most powerful scope granted wins
+	var scope Scope
+	for _, value := range claimStrings(lookupClaim(claims, v.cfg.ScopeClaim)) {
+		granted, ok := strings.CutPrefix(value, v.cfg.ScopePrefix)
+		if !ok {
+			continue
+		}
+		switch Scope(granted) {
+		case ScopeRead, ScopeWrite, ScopeAdmin:
+			if scope == "" || Scope(granted).Includes(scope) {
+				scope = Scope(granted)
+			}
+		}
+	}
+	if scope == "" {
+		scope = Scope(v.cfg.DefaultScope)
+	}
+	if scope == "" {
+		return nil, fmt.Errorf("%w: token grants no scope", ErrInvalidToken)
+	}
+
+	tenant := config.DefaultTenant
+	if v.cfg.TenantClaim != "" {
+		value, _ := lookupClaim(claims, v.cfg.TenantClaim).(string)
+		if value == "" {
+			return nil, fmt.Errorf("%w: token has no tenant", ErrInvalidToken)
+		}
+		tenant = value
+	}
+
+	var restriction taskwarrior.Restriction
+	if v.cfg.ProjectClaim != "" {
+		restriction.Project, _ = lookupClaim(claims, v.cfg.ProjectClaim).(string)
+	}
+	if v.cfg.TagsClaim != "" {
+		restriction.Tags = claimStrings(lookupClaim(claims, v.cfg.TagsClaim))
+	}
+
+	return &Identity{
+		Name:        name,
+		Tenant:      tenant,
+		Scope:       scope,
+		Restriction: restriction,
+	}, nil
+}
+
+// verifySignature checks a JWS signature (RFC 7518). Symmetric algorithms
+// and "none" are not supported.
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) bool {
+	hash, ok := algHash(alg)
+	if !ok {
+		return false
+	}
+	var digest []byte
+	if hash != 0 {
+		h := hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "PS") {
+			opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+			return rsa.VerifyPSS(k, hash, digest, signature, opts) == nil
+		}
+		return rsa.VerifyPKCS1v15(k, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		// ECDSA signatures are the fixed-size concatenation of r and s
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, signed, signature)
+	default:
+		return false
+	}
+}
+
+// algHash returns the hash of a supported signature algorithm. EdDSA signs
+// the message itself and has no separate hash.
+func algHash(alg string) (crypto.Hash, bool) {
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		return crypto.SHA256, true
+	case "RS384", "PS384", "ES384":
+		return crypto.SHA384, true
+	case "RS512", "PS512", "ES512":
+		return crypto.SHA512, true
+	case "EdDSA":
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// keyMatchesAlg reports whether key can verify signatures made with alg
+func keyMatchesAlg(key crypto.PublicKey, alg string) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		switch k.Curve.Params().Name {
+		case "P-256":
+			return alg == "ES256"
+		case "P-384":
+			return alg == "ES384"
+		case "P-521":
+			return alg == "ES512"
+		}
+		return false
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	default:
+		return false
+	}
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a JWT
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// lookupClaim returns a claim, following dots into nested objects
+func lookupClaim(claims map[string]any, name string) any {
+	if value, ok := claims[name]; ok {
+		return value
+	}
+
+	var value any = claims
+	for _, key := range strings.Split(name, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// claimStrings returns a claim holding a space-separated string or a list
+// of strings as a list
+func claimStrings(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		var result []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+// numericDate parses a NumericDate claim such as exp
+func numericDate(value any) (time.Time, bool) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// hasAudience reports whether the aud claim, a string or a list of
+// strings, names audience
+func hasAudience(value any, audience string) bool {
+	switch v := value.(type) {
+	case string:
+		return v == audience
+	case []any:
+		return slices.Contains(v, any(audience))
+	default:
+		return false
+	}
+}
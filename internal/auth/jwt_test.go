@@ -0,0 +1,414 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/config"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+const testAudience = "taskwarrior-api"
+
+// testIssuer is an identity provider serving its discovery document and
+// JWKS over HTTP, with one key of each kind tokens are signed with
+type testIssuer struct {
+	server *httptest.Server
+	rsa    *rsa.PrivateKey
+	ec     *ecdsa.PrivateKey
+	// weak is an RSA key below the minimum size, published in the JWKS
+	weak *rsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &testIssuer{rsa: rsaKey, ec: ecKey, weak: weakKey}
+
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		rsaJWK("rsa", &rsaKey.PublicKey),
+		rsaJWK("weak", &weakKey.PublicKey),
+		{
+			"kty": "EC", "kid": "ec", "use": "sig", "crv": "P-256",
+			"x": b64(ecKey.PublicKey.X.FillBytes(make([]byte, 32))),
+			"y": b64(ecKey.PublicKey.Y.FillBytes(make([]byte, 32))),
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   issuer.server.URL,
+			"jwks_uri": issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jwks)
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	return issuer
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA", "kid": kid, "use": "sig",
+		"n": b64(key.N.Bytes()),
+		"e": b64(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// config returns the OIDC settings of a verifier trusting the issuer. The
+// JWKS is found through discovery.
+func (ti *testIssuer) config() config.OIDCConfig {
+	return config.OIDCConfig{
+		Issuer:      ti.server.URL,
+		Audience:    testAudience,
+		JWKSRefresh: time.Hour,
+		Leeway:      time.Minute,
+		NameClaim:   "sub",
+		ScopeClaim:  "scope",
+		ScopePrefix: "taskwarrior:",
+	}
+}
+
+// claims returns valid claims for the issuer, with extra merged in
+func (ti *testIssuer) claims(extra map[string]any) map[string]any {
+	now := time.Now()
+	claims := map[string]any{
+		"iss":   ti.server.URL,
+		"aud":   testAudience,
+		"sub":   "alice",
+		"scope": "openid taskwarrior:write",
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	for name, value := range extra {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+	return claims
+}
+
+// sign creates a JWT with the given header algorithm and kid. key is an
+// *rsa.PrivateKey, an *ecdsa.PrivateKey, a []byte HMAC secret or nil for
+// an unsigned token.
+func sign(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	t.Helper()
+
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := b64(h) + "." + b64(c)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch k := key.(type) {
+	case nil:
+	case *rsa.PrivateKey:
+		if alg == "PS256" {
+			signature, err = rsa.SignPSS(rand.Reader, k, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		}
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		if err == nil {
+			signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	default:
+		t.Fatalf("unsupported key %T", key)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + b64(signature)
+}
+
+func newVerifier(t *testing.T, cfg config.OIDCConfig) *JWTVerifier {
+	t.Helper()
+
+	verifier, err := NewJWTVerifier(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return verifier
+}
+
+func TestJWTAccepted(t *testing.T) {
+	issuer := newTestIssuer(t)
+	verifier := newVerifier(t, issuer.config())
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"RS256", sign(t, "RS256", "rsa", issuer.rsa, issuer.claims(nil))},
+		{"PS256", sign(t, "PS256", "rsa", issuer.rsa, issuer.claims(nil))},
+		{"ES256", sign(t, "ES256", "ec", issuer.ec, issuer.claims(nil))},
+		{"without kid", sign(t, "ES256", "", issuer.ec, issuer.claims(nil))},
+		{"audience list", sign(t, "RS256", "rsa", issuer.rsa, issuer.claims(map[string]any{
+			"aud": []string{"other", testAudience},
+		}))},
+		{"expired within leeway", sign(t, "RS256", "rsa", issuer.rsa, issuer.claims(map[string]any{
+			"exp": time.Now().Add(-30 * time.Second).Unix(),
+		}))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !looksLikeJWT(tt.token) {
+				t.Fatal("token is not recognized as a JWT")
+			}
+			identity, err := verifier.Verify(tt.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if identity.Name != "alice" || identity.Scope != ScopeWrite || identity.Tenant != config.DefaultTenant {
+				t.Errorf("identity %+v", identity)
+			}
+		})
+	}
+}
+
+func TestJWTRejected(t *testing.T) {
+	issuer := newTestIssuer(t)
+	verifier := newVerifier(t, issuer.config())
+
+	// An HS256 token whose secret is the RSA public key, the classic key
+	// confusion attack on verifiers that trust the token's alg
+	publicDER, err := x509.MarshalPKIXPublicKey(&issuer.rsa.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	// The claims of one token with the signature of another
+	valid := strings.Split(sign(t, "RS256", "rsa", issuer.rsa, issuer.claims(nil)), ".")
+	forged := strings.Split(sign(t, "RS256", "rsa", issuer.rsa, issuer.claims(map[string]any{"sub": "mallory"})), ".")
+	tampered := valid[0] + "." + forged[1] + "." + valid[2]
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"alg none", sign(t, "none", "", nil, issuer.claims(nil)), ErrInvalidToken},
+		{"alg none with kid", sign(t, "none", "rsa", nil, issuer.claims(nil)), ErrInvalidToken},
+		{"HS256 with public key", sign(t, "HS256", "rsa", publicPEM, issuer.claims(nil)), ErrInvalidToken},
+		{"HS256 with modulus", sign(t, "HS256", "rsa", issuer.rsa.N.Bytes(), issuer.claims(nil)), ErrInvalidToken},
+		{"wrong issuer", sign(t, "RS256", "rsa", issuer.rsa, issuer.claims(map[string]any{
+			"iss": "https://evil.example.com",
+		})), ErrInvalidToken},
+		{"missing issuer", sign(t, "RS256", "rsa", issuer.rsa, issuer.claims(map[string]any{"iss": nil})), ErrInvalidToken},
+		{"wrong audience", sign(t, "RS256", "rsa", issuer.rsa, issuer.claims(map[string]any{"aud": "other"})), ErrInvalidToken},
+		{"wrong audience list", sign(t, "RS256", "rsa", issuer.rsa, issuer.claims(map[string]any{
+			"aud": []string{"other", "another"},
+		})), ErrInvalidToken},
+		{"expired", sign(t, "RS256", "rsa", issuer.rsa, issuer.claims(map[string]any{
+			"exp": time.Now().Add(-2 * time.Hour).Unix(),
+		})), ErrExpiredToken},
+		{"no expiry", sign(t, "RS256", "rsa", issuer.rsa, issuer.claims(map[string]any{"exp": nil})), ErrInvalidToken},
+		{"not yet valid", sign(t, "ES256", "ec", issuer.ec, issuer.claims(map[string]any{
+			"nbf": time.Now().Add(time.Hour).Unix(),
+		})), ErrInvalidToken},
+		{"RSA key under 2048 bits", sign(t, "RS256", "weak", issuer.weak, issuer.claims(nil)), ErrInvalidToken},
+		{"RSA key under 2048 bits without kid", sign(t, "RS256", "", issuer.weak, issuer.claims(nil)), ErrInvalidToken},
+		{"unknown key", sign(t, "RS256", "rotated", issuer.rsa, issuer.claims(nil)), ErrInvalidToken},
+		{"alg of another key type", sign(t, "RS256", "ec", issuer.rsa, issuer.claims(nil)), ErrInvalidToken},
+		{"tampered claims", tampered, ErrInvalidToken},
+		{"no scope", sign(t, "RS256", "rsa", issuer.rsa, issuer.claims(map[string]any{"scope": "openid profile"})), ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := verifier.Verify(tt.token)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %+v, %v, want %v", identity, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWKSSkipsWeakRSAKeys(t *testing.T) {
+	issuer := newTestIssuer(t)
+	keys := NewKeySet("", issuer.server.URL+"/jwks", "", time.Hour)
+	if err := keys.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range keys.keys {
+		if key.id == "weak" {
+			t.Error("the 1024-bit RSA key was loaded")
+		}
+	}
+	if len(keys.keys) != 2 {
+		t.Errorf("loaded %d keys, want the 2048-bit RSA and the EC key", len(keys.keys))
+	}
+}
+
+func TestPEMRejectsWeakRSAKeys(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	der, err := x509.MarshalPKIXPublicKey(&issuer.weak.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keys.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := issuer.config()
+	cfg.JWKSFile = path
+	if _, err := NewJWTVerifier(cfg); err == nil {
+		t.Fatal("a PEM file holding a 1024-bit RSA key was accepted")
+	}
+}
+
+func TestJWTClaimMapping(t *testing.T) {
+	issuer := newTestIssuer(t)
+	cfg := issuer.config()
+	cfg.NameClaim = "preferred_username"
+	cfg.ScopeClaim = "realm_access.roles"
+	cfg.TenantClaim = "org.tenant"
+	cfg.ProjectClaim = "taskwarrior_project"
+	cfg.TagsClaim = "taskwarrior_tags"
+	verifier := newVerifier(t, cfg)
+
+	tests := []struct {
+		name   string
+		claims map[string]any
+		want   *Identity
+	}{
+		{
+			name: "nested tenant and roles",
+			claims: map[string]any{
+				"preferred_username": "alice@example.com",
+				"realm_access":       map[string]any{"roles": []string{"taskwarrior:read", "taskwarrior:admin", "offline_access"}},
+				"org":                map[string]any{"tenant": "acme"},
+			},
+			want: &Identity{Name: "alice@example.com", Tenant: "acme", Scope: ScopeAdmin},
+		},
+		{
+			name: "restriction",
+			claims: map[string]any{
+				"realm_access":        map[string]any{"roles": []string{"taskwarrior:write"}},
+				"org":                 map[string]any{"tenant": "globex"},
+				"taskwarrior_project": "support",
+				"taskwarrior_tags":    "customer urgent",
+			},
+			want: &Identity{
+				Name:        "alice",
+				Tenant:      "globex",
+				Scope:       ScopeWrite,
+				Restriction: taskwarrior.Restriction{Project: "support", Tags: []string{"customer", "urgent"}},
+			},
+		},
+		{
+			name: "no tenant",
+			claims: map[string]any{
+				"realm_access": map[string]any{"roles": []string{"taskwarrior:write"}},
+			},
+		},
+		{
+			name: "tenant of the wrong type",
+			claims: map[string]any{
+				"realm_access": map[string]any{"roles": []string{"taskwarrior:write"}},
+				"org":          map[string]any{"tenant": []string{"acme", "globex"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := sign(t, "ES256", "ec", issuer.ec, issuer.claims(tt.claims))
+			identity, err := verifier.Verify(token)
+			if tt.want == nil {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Verify() = %+v, %v, want %v", identity, err, ErrInvalidToken)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(identity, tt.want) {
+				t.Errorf("Verify() = %+v, want %+v", identity, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWTDefaultScope(t *testing.T) {
+	issuer := newTestIssuer(t)
+	cfg := issuer.config()
+	cfg.DefaultScope = string(ScopeRead)
+	verifier := newVerifier(t, cfg)
+
+	identity, err := verifier.Verify(sign(t, "RS256", "rsa", issuer.rsa, issuer.claims(map[string]any{"scope": "openid"})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Scope != ScopeRead {
+		t.Errorf("scope %q, want the default %q", identity.Scope, ScopeRead)
+	}
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+var ErrTokenNotFound = errors.New("token not found")
+
+// lastUsedFlushInterval is how often last-used times are written to disk.
+// They change on nearly every request, so they are not persisted
+// immediately.
+const lastUsedFlushInterval = time.Minute
+
+// StoredToken is a token managed through the API. Only the hash of the
+// token is stored.
+type StoredToken struct {
+	ID          string                  `json:"id"`
+	Name        string                  `json:"name"`
+	Tenant      string                  `json:"tenant"`
+	Scope       Scope                   `json:"scope"`
+	Restriction taskwarrior.Restriction `json:"restriction"`
+	Hash        string                  `json:"hash,omitempty"`
+	CreatedAt   time.Time               `json:"created_at"`
+	RotatedAt   *time.Time              `json:"rotated_at,omitempty"`
+	LastUsedAt  *time.Time              `json:"last_used_at,omitempty"`
+	ExpiresAt   *time.Time              `json:"expires_at,omitempty"`
+}
+
+// Identity returns the identity authenticated by the token
+func (t *StoredToken) Identity() *Identity {
+	return &Identity{
+		Name:        t.Name,
+		Tenant:      t.Tenant,
+		Scope:       t.Scope,
+		Restriction: t.Restriction,
+	}
+}
+
+// TokenStore persists managed tokens in tokens.json inside a directory
+type TokenStore struct {
+	mu     sync.RWMutex
+	dir    string
+	tokens map[string]*StoredToken
+	dirty  bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// NewTokenStore opens or creates a token store in dir
+func NewTokenStore(dir string) (*TokenStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create token directory: %w", err)
+	}
+
+	s := &TokenStore{
+		dir:    dir,
+		tokens: make(map[string]*StoredToken),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	go s.flushLoop()
+	return s, nil
+}
+
+// Close writes pending last-used times and stops the store
+func (s *TokenStore) Close() error {
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	return s.write()
+}
+
+// List returns the tokens of a tenant sorted by creation time
+func (s *TokenStore) List(tenant string) []StoredToken {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]StoredToken, 0)
+	for _, t := range s.tokens {
+		if t.Tenant == tenant {
+			result = append(result, *t)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result
+}
+
+// Get returns a token by ID
+func (s *TokenStore) Get(id string) (*StoredToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.tokens[id]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	token := *t
+	return &token, nil
+}
+
+// Save creates or replaces a token
+func (s *TokenStore) Save(t StoredToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[t.ID] = &t
+	return s.write()
+}
+
+// Delete removes a token
+func (s *TokenStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tokens[id]; !ok {
+		return ErrTokenNotFound
+	}
+	delete(s.tokens, id)
+	return s.write()
+}
+
+// Touch records that a token has been used
+func (s *TokenStore) Touch(id string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.tokens[id]; ok {
+		t.LastUsedAt = &at
+		s.dirty = true
+	}
+}
+
+func (s *TokenStore) flushLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(lastUsedFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.dirty {
+				if err := s.write(); err != nil {
+					slog.Error("Failed to write token store", "error", err)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *TokenStore) path() string {
+	return filepath.Join(s.dir, "tokens.json")
+}
+
+func (s *TokenStore) load() error {
+	data, err := os.ReadFile(s.path())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read tokens: %w", err)
+	}
+
+	var list []StoredToken
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("failed to parse tokens: %w", err)
+	}
+	for i := range list {
+		s.tokens[list[i].ID] = &list[i]
+	}
+
+	return nil
+}
+
+// write atomically rewrites tokens.json
+func (s *TokenStore) write() error {
+	list := make([]StoredToken, 0, len(s.tokens))
+	for _, t := range s.tokens {
+		list = append(list, *t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write tokens: %w", err)
+	}
+	if err := os.Rename(tmp, s.path()); err != nil {
+		return err
+	}
+
+	s.dirty = false
+	return nil
+}
@@ -1,47 +1,266 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
 	"errors"
+	"log/slog"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/config"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
 )
 
+// verifyHash verifies configured token hashes; tests replace it to count
+// verifications
+var verifyHash = VerifyHash
+
 var (
 	ErrInvalidToken = errors.New("invalid authentication token")
 	ErrMissingToken = errors.New("missing authentication token")
+	ErrExpiredToken = errors.New("authentication token has expired")
 )
 
+// tokenEntry is a configured token. Plain-text tokens are kept only as a
+// SHA-256 digest; hashed tokens are verified with argon2id or bcrypt.
+type tokenEntry struct {
+	identity  *Identity
+	digest    []byte
+	id        string
+	hash      string
+	expiresAt *time.Time
+}
+
+// tokenSet holds the configured tokens. It is replaced as a whole when
+// the configuration is reloaded.
+type tokenSet struct {
+	plain []*tokenEntry
+	// unindexed are the hashed tokens without an ID, which have to be
+	// tried one by one
+	unindexed []*tokenEntry
+	byID      map[string]*tokenEntry
+
+	// verified caches successful hash verifications by token digest, so
+	// the expensive hash is computed once per token rather than per request
+	mu       sync.RWMutex
+	verified map[[sha256.Size]byte]*tokenEntry
+}
+
 // TokenValidator validates authentication tokens
 type TokenValidator struct {
-	validTokens map[string]bool
+	tokens atomic.Pointer[tokenSet]
+
+	// verifySlots bounds concurrent hash verifications, as each argon2id
+	// computation allocates 64 MiB
+	verifySlots chan struct{}
+
+	// store holds tokens managed through the API and is consulted on every
+	// request, so changes apply without a restart. storeVerified caches
+	// the hash each token digest was verified against; rotating a token
+	// changes its hash and so invalidates the entry.
+	store         *TokenStore
+	mu            sync.RWMutex
+	storeVerified map[[sha256.Size]byte]string
+
+	// jwt verifies JWT bearer tokens issued by an OIDC provider
+	jwt *JWTVerifier
+}
+
+// NewTokenValidator creates a new token validator for the tokens of the
+// default tenant and of all configured tenants. The token store is
+// optional and may be nil.
+func NewTokenValidator(cfg *config.Config, store *TokenStore) *TokenValidator {
+	tv := &TokenValidator{
+		verifySlots:   make(chan struct{}, runtime.NumCPU()),
+		store:         store,
+		storeVerified: make(map[[sha256.Size]byte]string),
+	}
+	tv.Reload(cfg)
+
+	return tv
+}
+
+// Reload replaces the configured tokens. Requests already authenticated
+// keep their identity.
+func (tv *TokenValidator) Reload(cfg *config.Config) {
+	set := &tokenSet{
+		byID:     make(map[string]*tokenEntry),
+		verified: make(map[[sha256.Size]byte]*tokenEntry),
+	}
+
+	set.add(config.DefaultTenant, cfg.Auth.Tokens)
+	for _, tenant := range cfg.Tenants {
+		set.add(tenant.Name, tenant.Tokens)
+	}
+
+	tv.tokens.Store(set)
 }
 
-// NewTokenValidator creates a new token validator
-func NewTokenValidator(tokens []string) *TokenValidator {
-	validTokens := make(map[string]bool)
+func (set *tokenSet) add(tenant string, tokens []config.TokenConfig) {
 	for _, token := range tokens {
-		if token != "" {
-			validTokens[token] = true
+		entry := &tokenEntry{
+			identity: &Identity{
+				Name:   token.Name,
+				Tenant: tenant,
+				Scope:  Scope(token.Scope),
+				Restriction: taskwarrior.Restriction{
+					Project: token.Project,
+					Tags:    token.Tags,
+				},
+			},
+			id:        token.ID,
+			hash:      token.Hash,
+			expiresAt: token.ExpiresAt,
 		}
-	}
 
-	return &TokenValidator{
-		validTokens: validTokens,
+		switch {
+		case token.Hash != "" && token.ID != "":
+			set.byID[token.ID] = entry
+		case token.Hash != "":
+			set.unindexed = append(set.unindexed, entry)
+		case token.Token != "":
+			digest := sha256.Sum256([]byte(token.Token))
+			entry.digest = digest[:]
+			set.plain = append(set.plain, entry)
+		}
 	}
 }
 
+// EnableJWT makes the validator accept JWT bearer tokens checked by
+// verifier in addition to the configured tokens
+func (tv *TokenValidator) EnableJWT(verifier *JWTVerifier) {
+	tv.jwt = verifier
+}
+
 // Validate checks if a token is valid
 func (tv *TokenValidator) Validate(token string) error {
+	_, err := tv.Authenticate(token)
+	return err
+}
+
+// Authenticate returns the identity a token belongs to
+func (tv *TokenValidator) Authenticate(token string) (*Identity, error) {
 	if token == "" {
-		return ErrMissingToken
+		return nil, ErrMissingToken
 	}
 
-	if !tv.validTokens[token] {
-		return ErrInvalidToken
+	if tv.jwt != nil && looksLikeJWT(token) {
+		return tv.jwt.Verify(token)
 	}
 
-	return nil
+	entry := tv.lookup(token)
+	if entry == nil {
+		return tv.authenticateStored(token)
+	}
+
+	if entry.expiresAt != nil && time.Now().After(*entry.expiresAt) {
+		return nil, ErrExpiredToken
+	}
+
+	return entry.identity, nil
 }
 
 // IsValid returns true if the token is valid
 func (tv *TokenValidator) IsValid(token string) bool {
-	return tv.validTokens[token]
+	return tv.Validate(token) == nil
+}
+
+// lookup finds the entry matching token. Plain-text tokens are compared
+// by digest in constant time, and every entry is compared so the time
+// taken does not depend on which entry matched.
+func (tv *TokenValidator) lookup(token string) *tokenEntry {
+	set := tv.tokens.Load()
+	digest := sha256.Sum256([]byte(token))
+
+	var match *tokenEntry
+	for _, entry := range set.plain {
+		if subtle.ConstantTimeCompare(digest[:], entry.digest) == 1 {
+			match = entry
+		}
+	}
+	if match != nil {
+		return match
+	}
+
+	set.mu.RLock()
+	match = set.verified[digest]
+	set.mu.RUnlock()
+	if match != nil {
+		return match
+	}
+
+	// Generated tokens carry the ID of their hash, so only that hash is
+	// verified. Hashed tokens with an ID never match any other token, and
+	// the configuration allows only a few without one, so a request costs
+	// a bounded number of hash computations.
+	candidates := set.unindexed
+	if entry, ok := set.byID[TokenID(token)]; ok {
+		candidates = []*tokenEntry{entry}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	tv.verifySlots <- struct{}{}
+	defer func() { <-tv.verifySlots }()
+
+	for _, entry := range candidates {
+		ok, err := verifyHash(entry.hash, token)
+		if err != nil {
+			slog.Error("Failed to verify token", "token_name", entry.identity.Name, "error", err)
+			continue
+		}
+		if ok {
+			set.mu.Lock()
+			set.verified[digest] = entry
+			set.mu.Unlock()
+			return entry
+		}
+	}
+
+	return nil
+}
+
+// authenticateStored checks token against the token store
+func (tv *TokenValidator) authenticateStored(token string) (*Identity, error) {
+	if tv.store == nil {
+		return nil, ErrInvalidToken
+	}
+
+	stored, err := tv.store.Get(TokenID(token))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	digest := sha256.Sum256([]byte(token))
+	tv.mu.RLock()
+	verifiedHash := tv.storeVerified[digest]
+	tv.mu.RUnlock()
+
+	if verifiedHash != stored.Hash {
+		tv.verifySlots <- struct{}{}
+		ok, err := VerifyHash(stored.Hash, token)
+		<-tv.verifySlots
+		if err != nil {
+			slog.Error("Failed to verify token", "token_name", stored.Name, "error", err)
+		}
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+
+		tv.mu.Lock()
+		tv.storeVerified[digest] = stored.Hash
+		tv.mu.Unlock()
+	}
+
+	now := time.Now()
+	if stored.ExpiresAt != nil && now.After(*stored.ExpiresAt) {
+		return nil, ErrExpiredToken
+	}
+
+	tv.store.Touch(stored.ID, now)
+	return stored.Identity(), nil
 }
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/dotbinio/taskwarrior-api/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// countVerifications records the hashes verified until the test ends
+func countVerifications(t *testing.T) func() []string {
+	t.Helper()
+
+	var mu sync.Mutex
+	var verified []string
+	verifyHash = func(hash, token string) (bool, error) {
+		mu.Lock()
+		verified = append(verified, hash)
+		mu.Unlock()
+		return VerifyHash(hash, token)
+	}
+	t.Cleanup(func() { verifyHash = VerifyHash })
+
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(verified)
+	}
+}
+
+// cheapHash hashes token with the lowest bcrypt cost, to keep tests fast
+func cheapHash(t *testing.T, token string) string {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(token), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+type hashedTokens struct {
+	validator *TokenValidator
+	// generated are tokens with an ID, by name
+	generated map[string]string
+	// legacy are hashed tokens without an ID, by name
+	legacy map[string]string
+	// withoutID are the hashes of the legacy tokens
+	withoutID []string
+}
+
+func newHashedTokens(t *testing.T, generated, legacy int) *hashedTokens {
+	t.Helper()
+
+	h := &hashedTokens{generated: map[string]string{}, legacy: map[string]string{}}
+	cfg := &config.Config{}
+	for i := range generated {
+		id, token, err := GenerateToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := fmt.Sprintf("generated-%d", i)
+		h.generated[name] = token
+		cfg.Auth.Tokens = append(cfg.Auth.Tokens, config.TokenConfig{
+			Name: name, ID: id, Hash: cheapHash(t, token), Scope: config.ScopeRead,
+		})
+	}
+	for i := range legacy {
+		name := fmt.Sprintf("legacy-%d", i)
+		token := "legacy-secret-" + name
+		hash := cheapHash(t, token)
+		h.legacy[name] = token
+		h.withoutID = append(h.withoutID, hash)
+		cfg.Auth.Tokens = append(cfg.Auth.Tokens, config.TokenConfig{
+			Name: name, Hash: hash, Scope: config.ScopeRead,
+		})
+	}
+	h.validator = NewTokenValidator(cfg, nil)
+	return h
+}
+
+func TestHashedTokens(t *testing.T) {
+	h := newHashedTokens(t, 3, 2)
+
+	for name, token := range h.generated {
+		verified := countVerifications(t)
+		identity, err := h.validator.Authenticate(token)
+		if err != nil || identity.Name != name {
+			t.Fatalf("%s: got %v, %v", name, identity, err)
+		}
+		if n := len(verified()); n != 1 {
+			t.Errorf("%s: verified %d hashes, want only its own", name, n)
+		}
+	}
+
+	for name, token := range h.legacy {
+		identity, err := h.validator.Authenticate(token)
+		if err != nil || identity.Name != name {
+			t.Fatalf("%s: got %v, %v", name, identity, err)
+		}
+	}
+
+	// Verified tokens are cached
+	verified := countVerifications(t)
+	for _, token := range h.generated {
+		h.validator.Authenticate(token)
+	}
+	if n := len(verified()); n != 0 {
+		t.Errorf("verified %d hashes for cached tokens", n)
+	}
+}
+
+// An unknown token is only verified against the hashes without an ID,
+// never against every configured hash
+func TestUnknownTokenVerificationsBounded(t *testing.T) {
+	h := newHashedTokens(t, 20, 2)
+
+	_, unknownToken, err := GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range []string{"not-a-configured-token", unknownToken, "tw_nope_secret"} {
+		verified := countVerifications(t)
+		if _, err := h.validator.Authenticate(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: got %v, want ErrInvalidToken", token, err)
+		}
+		got := verified()
+		slices.Sort(got)
+		want := slices.Sorted(slices.Values(h.withoutID))
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: verified %d hashes, want the %d without an ID", token, len(got), len(want))
+		}
+	}
+
+	// A generated token with a known ID and the wrong secret costs one
+	// verification
+	verified := countVerifications(t)
+	token := h.generated["generated-0"]
+	forged := token[:len(token)-4] + "AAAA"
+	if _, err := h.validator.Authenticate(forged); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("forged token got %v", err)
+	}
+	if n := len(verified()); n != 1 {
+		t.Errorf("forged token verified %d hashes, want 1", n)
+	}
+}
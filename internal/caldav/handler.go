@@ -0,0 +1,868 @@
+package caldav
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+// defaultCollection is the calendar holding tasks without a project
+const defaultCollection = "_default"
+
+// errRejected is returned from a write check that already answered the
+// request
+var errRejected = errors.New("write rejected")
+
+// Methods lists the HTTP methods served by the CalDAV handler
+var Methods = []string{"OPTIONS", "GET", "HEAD", "PUT", "DELETE", "PROPFIND", "REPORT"}
+
+type resourceKind int
+
+const (
+	kindRoot resourceKind = iota
+	kindCollection
+	kindTask
+)
+
+// davResource describes the target of a CalDAV request
+type davResource struct {
+	kind       resourceKind
+	href       string
+	collection string
+	project    string
+	name       string // of a task resource, without .ics
+	uuid       string
+	task       *taskwarrior.Task
+	ctag       string
+}
+
+// Handler serves Taskwarrior tasks as CalDAV VTODO resources. Each project
+// is exposed as a calendar collection below the handler prefix.
+type Handler struct {
+	client *taskwarrior.Client
+	prefix string
+}
+
+// NewHandler creates a new CalDAV handler mounted at prefix
+func NewHandler(client *taskwarrior.Client, prefix string) *Handler {
+	return &Handler{
+		client: client,
+		prefix: strings.TrimSuffix(prefix, "/"),
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	res, ok := h.parsePath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case "OPTIONS":
+		h.handleOptions(w)
+	case "PROPFIND":
+		h.handlePropfind(w, r, res)
+	case "REPORT":
+		h.handleReport(w, r, res)
+	case "GET", "HEAD":
+		h.handleGet(w, r, res)
+	case "PUT":
+		h.handlePut(w, r, res)
+	case "DELETE":
+		h.handleDelete(w, r, res)
+	default:
+		w.Header().Set("Allow", strings.Join(Methods, ", "))
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) handleOptions(w http.ResponseWriter) {
+	w.Header().Set("DAV", "1, 3, calendar-access")
+	w.Header().Set("Allow", strings.Join(Methods, ", "))
+	w.WriteHeader(http.StatusOK)
+}
+
+// handlePropfind handles PROPFIND on the root, a calendar or a task
+func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request, res *davResource) {
+	var req propfindRequest
+	if err := decodeXML(r.Body, &req); err != nil && err != io.EOF {
+		http.Error(w, "invalid PROPFIND body", http.StatusBadRequest)
+		return
+	}
+	allProp := req.XMLName.Local == "" || req.AllProp != nil || req.PropName != nil
+	names := elementNames(req.Prop.Names)
+
+	depth := r.Header.Get("Depth")
+	if depth == "" {
+		depth = "infinity"
+	}
+
+	resources := []*davResource{res}
+	switch res.kind {
+	case kindRoot:
+		if depth != "0" {
+			collections, err := h.listCollections()
+			if err != nil {
+				h.serverError(w, "list collections", err)
+				return
+			}
+			resources = append(resources, collections...)
+		}
+	case kindCollection:
+		tasks, err := h.listTasks(res.project)
+		if err != nil {
+			h.serverError(w, "list tasks", err)
+			return
+		}
+		res.ctag = collectionTag(tasks)
+		if depth != "0" {
+			for i := range tasks {
+				resources = append(resources, h.taskResource(res.collection, &tasks[i]))
+			}
+		}
+	case kindTask:
+		task, err := h.findTask(res)
+		if err != nil {
+			h.taskError(w, r, err)
+			return
+		}
+		res.task = task
+	}
+
+	ms := multistatus{}
+	for _, item := range resources {
+		ms.Responses = append(ms.Responses, h.propResponse(item, names, allProp))
+	}
+	writeMultistatus(w, ms)
+}
+
+// handleReport handles calendar-query and calendar-multiget reports
+func (h *Handler) handleReport(w http.ResponseWriter, r *http.Request, res *davResource) {
+	if res.kind != kindCollection {
+		http.Error(w, "reports are only supported on calendar collections", http.StatusForbidden)
+		return
+	}
+
+	var req reportRequest
+	if err := decodeXML(r.Body, &req); err != nil {
+		http.Error(w, "invalid REPORT body", http.StatusBadRequest)
+		return
+	}
+	names := elementNames(req.Prop.Names)
+
+	tasks, err := h.listTasks(res.project)
+	if err != nil {
+		h.serverError(w, "list tasks", err)
+		return
+	}
+
+	ms := multistatus{}
+	switch req.XMLName {
+	case xml.Name{Space: nsCalDAV, Local: "calendar-query"}:
+		if req.Filter.wantsVTODO() {
+			for i := range tasks {
+				item := h.taskResource(res.collection, &tasks[i])
+				ms.Responses = append(ms.Responses, h.propResponse(item, names, false))
+			}
+		}
+	case xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}:
+		byUUID := make(map[string]*taskwarrior.Task, len(tasks))
+		for i := range tasks {
+			byUUID[tasks[i].UUID] = &tasks[i]
+		}
+		for _, href := range req.Hrefs {
+			target, ok := h.parsePath(href)
+			task := byUUID[target.uuidOrEmpty()]
+			if !ok || target.kind != kindTask || target.collection != res.collection || task == nil {
+				ms.Responses = append(ms.Responses, response{
+					Href:   href,
+					Status: statusLine(http.StatusNotFound),
+				})
+				continue
+			}
+			item := h.taskResource(res.collection, task)
+			ms.Responses = append(ms.Responses, h.propResponse(item, names, false))
+		}
+	default:
+		http.Error(w, "unsupported report", http.StatusForbidden)
+		return
+	}
+
+	writeMultistatus(w, ms)
+}
+
+// handleGet returns a single task as an iCalendar object
+func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request, res *davResource) {
+	if res.kind != kindTask {
+		w.Header().Set("Allow", "OPTIONS, PROPFIND, REPORT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	task, err := h.findTask(res)
+	if err != nil {
+		h.taskError(w, r, err)
+		return
+	}
+
+	body := encodeVTODO(*task)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8; component=VTODO")
+	w.Header().Set("ETag", taskETag(task))
+	if modified := taskModified(task); !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method != "HEAD" {
+		w.Write(body)
+	}
+}
+
+// handlePut creates or updates a task from a VTODO
+func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, res *davResource) {
+	if res.kind != kindTask {
+		http.Error(w, "PUT is only supported on calendar object resources", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	todo, err := parseVTODO(body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid calendar data: %v", err), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// New tasks are created under the UUID in the href, so the resource
+	// stays where the client put it
+	uuid := res.uuid
+	if !isCanonicalUUID(uuid) {
+		uuid = resourceUUID(res.name, todo.UID)
+	}
+
+	// The task is read again and the preconditions evaluated under the
+	// write lock, so no other change can slip in between. A task that
+	// cannot be read is not mistaken for a missing one.
+	var existing *taskwarrior.Task
+	err = h.client.CheckedWrite(uuid, func(task *taskwarrior.Task, err error) error {
+		if errors.Is(err, taskwarrior.ErrTaskNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if task.Status != taskwarrior.StatusDeleted {
+			existing = task
+		}
+		return nil
+	}, func(locked *taskwarrior.Client) error {
+		lockedHandler := *h
+		lockedHandler.client = locked
+		if !checkPreconditions(w, r, existing) {
+			return nil
+		}
+		if existing == nil {
+			lockedHandler.createTask(w, res, uuid, todo)
+			return nil
+		}
+		lockedHandler.updateTask(w, res, existing, todo)
+		return nil
+	})
+	if err != nil {
+		h.serverError(w, "retrieve task", err)
+	}
+}
+
+// resourceUUID returns the UUID of a task created at an href whose name is
+// not a UUID: the VTODO's UID if it is one, otherwise a UUID derived from
+// the UID or the name, so that repeating the PUT finds the same task
+func resourceUUID(name, uid string) string {
+	if lower := strings.ToLower(uid); isCanonicalUUID(lower) {
+		return lower
+	}
+	if uid == "" {
+		uid = name
+	}
+
+	h := sha1.New()
+	h.Write(uidNamespace[:])
+	h.Write([]byte(uid))
+	sum := h.Sum(nil)
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+
+	s := hex.EncodeToString(sum[:16])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+// isCanonicalUUID reports whether s is a UUID in the lowercase form
+// Taskwarrior uses
+func isCanonicalUUID(s string) bool {
+	return taskwarrior.ValidateTaskUUID(s) && !strings.ContainsFunc(s, func(r rune) bool {
+		return r != '-' && (r < '0' || r > '9') && (r < 'a' || r > 'f')
+	})
+}
+
+// uidNamespace makes the UUIDs derived from VTODO UIDs distinct from
+// other name-based UUIDs
+var uidNamespace = [16]byte{0x2f, 0x1c, 0x5e, 0x8a, 0x43, 0xd7, 0x4b, 0x0e, 0x9a, 0x61, 0xc3, 0x75, 0x08, 0xe2, 0xbd, 0x94}
+
+func (h *Handler) createTask(w http.ResponseWriter, res *davResource, uuid string, todo *vtodo) {
+	summary := taskwarrior.SanitizeInput(todo.Summary)
+	if strings.TrimSpace(summary) == "" {
+		http.Error(w, "VTODO SUMMARY is required", http.StatusBadRequest)
+		return
+	}
+
+	create := taskwarrior.TaskCreate{
+		Description: summary,
+		Project:     taskwarrior.SanitizeInput(res.project),
+		Priority:    taskPriority(todo.Priority),
+		Due:         todo.Due,
+		Scheduled:   todo.Start,
+		Depends:     todo.RelatedTo,
+	}
+	for _, category := range todo.Categories {
+		if tag := sanitizeTag(category); tag != "" {
+			create.Tags = append(create.Tags, tag)
+		}
+	}
+
+	if err := h.client.AddWithUUID(uuid, create); err != nil {
+		h.serverError(w, "create task", err)
+		return
+	}
+
+	if err := h.applyStatus(uuid, nil, todo.Status); err != nil {
+		h.serverError(w, "update task status", err)
+		return
+	}
+
+	// Only an href that is not a UUID moves to the task's UUID
+	if uuid != res.uuid {
+		w.Header().Set("Location", h.taskHref(res.collection, uuid))
+	} else if created, err := h.client.GetByUUID(uuid); err == nil && created.Status != taskwarrior.StatusDeleted {
+		w.Header().Set("ETag", taskETag(created))
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *Handler) updateTask(w http.ResponseWriter, res *davResource, task *taskwarrior.Task, todo *vtodo) {
+	var modify taskwarrior.TaskModify
+	changed := false
+
+	if summary := taskwarrior.SanitizeInput(todo.Summary); summary != "" && summary != task.Description {
+		modify.Description = &summary
+		changed = true
+	}
+
+	if project := taskwarrior.SanitizeInput(res.project); project != task.Project {
+		modify.Project = &project
+		changed = true
+	}
+
+	if priority := taskPriority(todo.Priority); priority != task.Priority {
+		modify.Priority = &priority
+		changed = true
+	}
+
+	if todo.Due != nil && (task.Due == nil || !todo.Due.Equal(task.Due.Time)) {
+		modify.Due = todo.Due
+		changed = true
+	}
+
+	if todo.Start != nil && (task.Scheduled == nil || !todo.Start.Equal(task.Scheduled.Time)) {
+		modify.Scheduled = todo.Start
+		changed = true
+	}
+
+	for _, category := range todo.Categories {
+		if tag := sanitizeTag(category); tag != "" && !slices.Contains(task.Tags, tag) {
+			modify.Tags = append(modify.Tags, tag)
+			changed = true
+		}
+	}
+
+	for _, dep := range todo.RelatedTo {
+		if !slices.Contains(task.Depends, dep) {
+			modify.Depends = append(modify.Depends, dep)
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := h.client.Modify(task.UUID, modify); err != nil {
+			h.serverError(w, "modify task", err)
+			return
+		}
+	}
+
+	if err := h.applyStatus(task.UUID, task, todo.Status); err != nil {
+		h.serverError(w, "update task status", err)
+		return
+	}
+
+	if updated, err := h.client.GetByUUID(task.UUID); err == nil && updated.Status != taskwarrior.StatusDeleted {
+		w.Header().Set("ETag", taskETag(updated))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyStatus maps a VTODO STATUS change onto done/start/stop/delete
+func (h *Handler) applyStatus(uuid string, task *taskwarrior.Task, status string) error {
+	completed := task != nil && task.Status == taskwarrior.StatusCompleted
+	started := task != nil && task.Start != nil
+
+	switch status {
+	case "COMPLETED":
+		if !completed {
+			return h.client.Done(uuid)
+		}
+	case "IN-PROCESS":
+		if !started && !completed {
+			return h.client.Start(uuid)
+		}
+	case "NEEDS-ACTION", "":
+		if started {
+			return h.client.Stop(uuid)
+		}
+	case "CANCELLED":
+		return h.client.Delete(uuid)
+	}
+
+	return nil
+}
+
+// handleDelete deletes a task
+func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, res *davResource) {
+	if res.kind != kindTask {
+		http.Error(w, "calendar collections cannot be deleted", http.StatusForbidden)
+		return
+	}
+
+	if res.uuid == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	err := h.client.CheckedWrite(res.uuid, func(task *taskwarrior.Task, err error) error {
+		task, err = inCollection(res, task, err)
+		if err != nil {
+			return err
+		}
+		if !checkPreconditions(w, r, task) {
+			return errRejected
+		}
+		return nil
+	}, func(locked *taskwarrior.Client) error {
+		return locked.Delete(res.uuid)
+	})
+	switch {
+	case errors.Is(err, errRejected):
+	case errors.Is(err, taskwarrior.ErrTaskNotFound):
+		http.NotFound(w, r)
+	case err != nil:
+		h.serverError(w, "delete task", err)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// checkPreconditions evaluates If-Match and If-None-Match against the
+// current task and writes 412 when they fail
+func checkPreconditions(w http.ResponseWriter, r *http.Request, task *taskwarrior.Task) bool {
+	if match := r.Header.Get("If-Match"); match != "" {
+		if task == nil || (match != "*" && !etagListContains(match, taskETag(task))) {
+			http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+			return false
+		}
+	}
+
+	if noneMatch := r.Header.Get("If-None-Match"); noneMatch != "" && task != nil {
+		if noneMatch == "*" || etagListContains(noneMatch, taskETag(task)) {
+			http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+			return false
+		}
+	}
+
+	return true
+}
+
+// propResponse resolves the requested properties for a resource
+func (h *Handler) propResponse(res *davResource, names []xml.Name, allProp bool) response {
+	if allProp {
+		names = defaultProps(res.kind)
+	}
+
+	var found []property
+	var missing []xml.Name
+	for _, name := range names {
+		if p, ok := h.resolveProp(res, name); ok {
+			found = append(found, p)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+
+	return response{
+		Href:      res.href,
+		Propstats: propstats(found, missing),
+	}
+}
+
+func (h *Handler) resolveProp(res *davResource, name xml.Name) (property, bool) {
+	root := h.prefix + "/"
+
+	switch name {
+	case xml.Name{Space: nsDAV, Local: "resourcetype"}:
+		switch res.kind {
+		case kindRoot:
+			return rawProperty(name, `<collection xmlns="DAV:"/>`), true
+		case kindCollection:
+			return rawProperty(name, `<collection xmlns="DAV:"/><calendar xmlns="urn:ietf:params:xml:ns:caldav"/>`), true
+		default:
+			return rawProperty(name, ""), true
+		}
+	case xml.Name{Space: nsDAV, Local: "displayname"}:
+		switch res.kind {
+		case kindRoot:
+			return textProperty(name, "Taskwarrior"), true
+		case kindCollection:
+			return textProperty(name, collectionDisplayName(res.project)), true
+		default:
+			return textProperty(name, res.task.Description), true
+		}
+	case xml.Name{Space: nsDAV, Local: "current-user-principal"},
+		xml.Name{Space: nsDAV, Local: "principal-URL"},
+		xml.Name{Space: nsDAV, Local: "owner"}:
+		return hrefProperty(name, root), true
+	case xml.Name{Space: nsCalDAV, Local: "calendar-home-set"}:
+		if res.kind == kindRoot {
+			return hrefProperty(name, root), true
+		}
+	case xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"}:
+		if res.kind == kindCollection {
+			return rawProperty(name, `<comp xmlns="urn:ietf:params:xml:ns:caldav" name="VTODO"/>`), true
+		}
+	case xml.Name{Space: nsDAV, Local: "supported-report-set"}:
+		if res.kind == kindCollection {
+			return rawProperty(name,
+				`<supported-report xmlns="DAV:"><report><calendar-query xmlns="urn:ietf:params:xml:ns:caldav"/></report></supported-report>`+
+					`<supported-report xmlns="DAV:"><report><calendar-multiget xmlns="urn:ietf:params:xml:ns:caldav"/></report></supported-report>`), true
+		}
+	case xml.Name{Space: nsCalendarServer, Local: "getctag"}:
+		if res.kind == kindCollection && res.ctag != "" {
+			return textProperty(name, res.ctag), true
+		}
+	case xml.Name{Space: nsDAV, Local: "getetag"}:
+		if res.kind == kindTask {
+			return textProperty(name, taskETag(res.task)), true
+		}
+	case xml.Name{Space: nsDAV, Local: "getcontenttype"}:
+		if res.kind == kindTask {
+			return textProperty(name, "text/calendar; charset=utf-8; component=VTODO"), true
+		}
+	case xml.Name{Space: nsDAV, Local: "getlastmodified"}:
+		if res.kind == kindTask {
+			if modified := taskModified(res.task); !modified.IsZero() {
+				return textProperty(name, modified.UTC().Format(http.TimeFormat)), true
+			}
+		}
+	case xml.Name{Space: nsCalDAV, Local: "calendar-data"}:
+		if res.kind == kindTask {
+			return textProperty(name, string(encodeVTODO(*res.task))), true
+		}
+	}
+
+	return property{}, false
+}
+
+// defaultProps lists the properties returned for allprop requests
+func defaultProps(kind resourceKind) []xml.Name {
+	names := []xml.Name{
+		{Space: nsDAV, Local: "resourcetype"},
+		{Space: nsDAV, Local: "displayname"},
+	}
+
+	switch kind {
+	case kindRoot:
+		names = append(names,
+			xml.Name{Space: nsDAV, Local: "current-user-principal"},
+			xml.Name{Space: nsCalDAV, Local: "calendar-home-set"},
+		)
+	case kindCollection:
+		names = append(names,
+			xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"},
+			xml.Name{Space: nsCalendarServer, Local: "getctag"},
+		)
+	case kindTask:
+		names = append(names,
+			xml.Name{Space: nsDAV, Local: "getetag"},
+			xml.Name{Space: nsDAV, Local: "getcontenttype"},
+			xml.Name{Space: nsDAV, Local: "getlastmodified"},
+		)
+	}
+
+	return names
+}
+
+// parsePath maps a request path onto the root, a collection or a task
+func (h *Handler) parsePath(p string) (*davResource, bool) {
+	if u, err := url.Parse(p); err == nil {
+		p = u.Path
+	}
+
+	rel, ok := strings.CutPrefix(p, h.prefix)
+	if !ok {
+		return nil, false
+	}
+	rel = strings.Trim(rel, "/")
+
+	if rel == "" {
+		return &davResource{kind: kindRoot, href: h.prefix + "/"}, true
+	}
+
+	parts := strings.Split(rel, "/")
+	if len(parts) > 2 {
+		return nil, false
+	}
+
+	collection := parts[0]
+	project := collectionProject(collection)
+
+	if len(parts) == 1 {
+		return &davResource{
+			kind:       kindCollection,
+			href:       h.collectionHref(project),
+			collection: collectionName(project),
+			project:    project,
+		}, true
+	}
+
+	name, ok := strings.CutSuffix(parts[1], ".ics")
+	if !ok || name == "" {
+		return nil, false
+	}
+
+	res := &davResource{
+		kind:       kindTask,
+		href:       h.taskHref(collectionName(project), name),
+		collection: collectionName(project),
+		project:    project,
+		name:       name,
+	}
+	if taskwarrior.ValidateTaskUUID(name) {
+		res.uuid = name
+	}
+
+	return res, true
+}
+
+func (r *davResource) uuidOrEmpty() string {
+	if r == nil {
+		return ""
+	}
+	return r.uuid
+}
+
+// findTask loads the task addressed by a resource, ensuring it belongs to
+// the collection in the request path
+func (h *Handler) findTask(res *davResource) (*taskwarrior.Task, error) {
+	if res.uuid == "" {
+		return nil, taskwarrior.ErrTaskNotFound
+	}
+
+	task, err := h.client.GetByUUID(res.uuid)
+	return inCollection(res, task, err)
+}
+
+// inCollection checks the result of loading the task addressed by res. A
+// deleted task or one in another collection is not found.
+func inCollection(res *davResource, task *taskwarrior.Task, err error) (*taskwarrior.Task, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	if task.Status == taskwarrior.StatusDeleted || collectionName(task.Project) != res.collection {
+		return nil, fmt.Errorf("%w: %s", taskwarrior.ErrTaskNotFound, res.uuid)
+	}
+
+	return task, nil
+}
+
+// listCollections returns one calendar per project plus the default
+// calendar. A project stays listed as long as it has tasks that are not
+// deleted, so completed tasks keep their calendar.
+func (h *Handler) listCollections() ([]*davResource, error) {
+	tasks, err := h.client.Export(collectionFilters...)
+	if err != nil {
+		return nil, err
+	}
+
+	byProject := map[string][]taskwarrior.Task{"": nil}
+	for _, task := range tasks {
+		byProject[task.Project] = append(byProject[task.Project], task)
+	}
+	names := slices.Sorted(maps.Keys(byProject))
+
+	collections := make([]*davResource, 0, len(names))
+	for _, project := range names {
+		collections = append(collections, &davResource{
+			kind:       kindCollection,
+			href:       h.collectionHref(project),
+			collection: collectionName(project),
+			project:    project,
+			ctag:       collectionTag(byProject[project]),
+		})
+	}
+
+	return collections, nil
+}
+
+// collectionFilters select the tasks shown in calendars
+var collectionFilters = []string{"status.not:deleted", "status.not:recurring"}
+
+// listTasks exports the tasks that belong to a project's calendar
+func (h *Handler) listTasks(project string) ([]taskwarrior.Task, error) {
+	filters := slices.Clone(collectionFilters)
+	if project == "" {
+		filters = append(filters, "project:")
+	} else {
+		filters = append(filters, "project.is:"+taskwarrior.SanitizeInput(project))
+	}
+
+	return h.client.Export(filters...)
+}
+
+func (h *Handler) taskResource(collection string, task *taskwarrior.Task) *davResource {
+	return &davResource{
+		kind:       kindTask,
+		href:       h.taskHref(collection, task.UUID),
+		collection: collection,
+		project:    task.Project,
+		uuid:       task.UUID,
+		task:       task,
+	}
+}
+
+func (h *Handler) collectionHref(project string) string {
+	return h.prefix + "/" + collectionName(project) + "/"
+}
+
+func (h *Handler) taskHref(collection, uuid string) string {
+	return h.prefix + "/" + collection + "/" + uuid + ".ics"
+}
+
+func (h *Handler) serverError(w http.ResponseWriter, action string, err error) {
+	slog.Error("CalDAV request failed", "action", action, "error", err)
+	http.Error(w, "internal server error", http.StatusInternalServerError)
+}
+
+// taskError answers 404 when a task was not found and 500 when it could
+// not be read
+func (h *Handler) taskError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, taskwarrior.ErrTaskNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	h.serverError(w, "retrieve task", err)
+}
+
+// collectionName returns the URL path segment for a project
+func collectionName(project string) string {
+	if project == "" {
+		return defaultCollection
+	}
+	return url.PathEscape(project)
+}
+
+// collectionProject returns the project for a URL path segment
+func collectionProject(name string) string {
+	if name == defaultCollection {
+		return ""
+	}
+	if project, err := url.PathUnescape(name); err == nil {
+		return project
+	}
+	return name
+}
+
+func collectionDisplayName(project string) string {
+	if project == "" {
+		return "Tasks"
+	}
+	return project
+}
+
+// taskModified returns the last modification time of a task
+func taskModified(task *taskwarrior.Task) time.Time {
+	if task.Modified != nil {
+		return task.Modified.Time
+	}
+	if task.Entry != nil {
+		return task.Entry.Time
+	}
+	return time.Time{}
+}
+
+// taskETag derives a strong ETag from the task modification time
+func taskETag(task *taskwarrior.Task) string {
+	return fmt.Sprintf(`"%d"`, taskModified(task).Unix())
+}
+
+// collectionTag derives a collection tag that changes whenever any task
+// in the collection is added, removed or modified
+func collectionTag(tasks []taskwarrior.Task) string {
+	entries := make([]string, len(tasks))
+	for i := range tasks {
+		entries[i] = tasks[i].UUID + taskETag(&tasks[i])
+	}
+	slices.Sort(entries)
+
+	sum := sha256.Sum256([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// etagListContains checks whether a comma-separated If-Match or
+// If-None-Match header contains the given ETag
+func etagListContains(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeTag converts a VTODO category into a Taskwarrior tag
+func sanitizeTag(category string) string {
+	tag := taskwarrior.SanitizeInput(category)
+	return strings.Join(strings.Fields(tag), "_")
+}
+
+func elementNames(elements []xmlElement) []xml.Name {
+	names := make([]xml.Name, len(elements))
+	for i, el := range elements {
+		names[i] = el.XMLName
+	}
+	return names
+}
@@ -0,0 +1,460 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dotbinio/taskwarrior-api/internal/tasktest"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+func TestMain(m *testing.M) {
+	tasktest.Main()
+	os.Exit(m.Run())
+}
+
+const (
+	workUUID    = "a360fc44-315c-4366-b70c-ea7e7520b749"
+	homeUUID    = "0f6b2c1e-9d4a-4c3b-8e2f-7a1b5c9d3e4f"
+	defaultUUID = "5e7d9c3a-2b1f-4e8d-a6c4-3f2e1d0c9b8a"
+	deletedUUID = "c2d4e6f8-1a3b-4c5d-9e7f-0a2b4c6d8e0f"
+	unknownUUID = "9b8a7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d"
+	doneUUID    = "3f2e1d0c-9b8a-4c6d-8e7f-6a5b4c3d2e1f"
+
+	// workETag is the ETag of the work task, its modified time in seconds
+	workETag = `"1767258000"`
+)
+
+func fixtureTasks() []map[string]any {
+	return []map[string]any{
+		{
+			"uuid": workUUID, "description": "Write docs", "status": "pending",
+			"project": "work", "tags": []string{"docs"}, "priority": "H",
+			"entry": "20260101T090000Z", "modified": "20260101T090000Z",
+			"due": "20260201T170000Z",
+		},
+		{
+			"uuid": homeUUID, "description": "Water plants", "status": "pending",
+			"project": "home", "entry": "20260101T090000Z", "modified": "20260102T090000Z",
+		},
+		{
+			"uuid": defaultUUID, "description": "No project", "status": "pending",
+			"entry": "20260101T090000Z", "modified": "20260103T090000Z",
+		},
+		{
+			"uuid": deletedUUID, "description": "Gone", "status": "deleted",
+			"project": "work", "entry": "20260101T090000Z", "modified": "20260104T090000Z",
+			"end": "20260104T090000Z",
+		},
+		{
+			"uuid": doneUUID, "description": "Old trip", "status": "completed",
+			"project": "archive", "entry": "20260101T090000Z", "modified": "20260105T090000Z",
+			"end": "20260105T090000Z",
+		},
+	}
+}
+
+// davClient is a minimal CalDAV client speaking the requests calendar
+// clients send
+type davClient struct {
+	t      *testing.T
+	server *httptest.Server
+	fake   *tasktest.Fake
+}
+
+func newDAVClient(t *testing.T) *davClient {
+	t.Helper()
+
+	fake := tasktest.Install(t, fixtureTasks())
+	client := taskwarrior.NewClient(fake.DataLocation(), "")
+	server := httptest.NewServer(NewHandler(client, "/caldav"))
+	t.Cleanup(server.Close)
+
+	return &davClient{t: t, server: server, fake: fake}
+}
+
+func (c *davClient) do(method, path string, header http.Header, body string) (*http.Response, string) {
+	c.t.Helper()
+
+	req, err := http.NewRequest(method, c.server.URL+path, strings.NewReader(body))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := c.server.Client().Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+// davResponse is one response of a multistatus body
+type davResponse struct {
+	Href      string `xml:"href"`
+	Status    string `xml:"status"`
+	Propstats []struct {
+		Status string `xml:"status"`
+		Prop   struct {
+			ETag         string `xml:"getetag"`
+			DisplayName  string `xml:"displayname"`
+			CTag         string `xml:"getctag"`
+			CalendarData string `xml:"calendar-data"`
+			ResourceType struct {
+				Calendar *struct{} `xml:"calendar"`
+			} `xml:"resourcetype"`
+		} `xml:"prop"`
+	} `xml:"propstat"`
+}
+
+func (r davResponse) okProp() (etag, data string) {
+	for _, ps := range r.Propstats {
+		if strings.Contains(ps.Status, " 200 ") {
+			return ps.Prop.ETag, ps.Prop.CalendarData
+		}
+	}
+	return "", ""
+}
+
+func (c *davClient) multistatus(method, path, depth, body string) map[string]davResponse {
+	c.t.Helper()
+
+	header := http.Header{"Content-Type": {"application/xml; charset=utf-8"}}
+	if depth != "" {
+		header.Set("Depth", depth)
+	}
+	resp, data := c.do(method, path, header, body)
+	if resp.StatusCode != http.StatusMultiStatus {
+		c.t.Fatalf("%s %s: status %d, want 207: %s", method, path, resp.StatusCode, data)
+	}
+
+	var ms struct {
+		Responses []davResponse `xml:"response"`
+	}
+	if err := xml.Unmarshal([]byte(data), &ms); err != nil {
+		c.t.Fatalf("%s %s: %v in %s", method, path, err, data)
+	}
+	byHref := make(map[string]davResponse, len(ms.Responses))
+	for _, r := range ms.Responses {
+		byHref[r.Href] = r
+	}
+	return byHref
+}
+
+func (c *davClient) propfind(path, depth string) map[string]davResponse {
+	return c.multistatus("PROPFIND", path, depth, `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/">
+  <d:prop><d:resourcetype/><d:displayname/><d:getetag/><cs:getctag/></d:prop>
+</d:propfind>`)
+}
+
+func (c *davClient) calendarQuery(path string) map[string]davResponse {
+	return c.multistatus("REPORT", path, "1", `<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/></d:prop>
+  <c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VTODO"/></c:comp-filter></c:filter>
+</c:calendar-query>`)
+}
+
+func (c *davClient) multiget(path string, hrefs ...string) map[string]davResponse {
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/></d:prop>`)
+	for _, href := range hrefs {
+		fmt.Fprintf(&body, "\n  <d:href>%s</d:href>", href)
+	}
+	body.WriteString("\n</c:calendar-multiget>")
+	return c.multistatus("REPORT", path, "1", body.String())
+}
+
+func (c *davClient) put(path, ifMatch, summary string) *http.Response {
+	c.t.Helper()
+
+	return c.putUID(path, ifMatch, strings.TrimSuffix(path[strings.LastIndex(path, "/")+1:], ".ics"), summary)
+}
+
+func (c *davClient) putUID(path, ifMatch, uid, summary string) *http.Response {
+	c.t.Helper()
+
+	header := http.Header{"Content-Type": {"text/calendar; charset=utf-8"}}
+	if ifMatch != "" {
+		header.Set("If-Match", ifMatch)
+	}
+	body := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//tasktest//EN",
+		"BEGIN:VTODO",
+		"UID:" + uid,
+		"SUMMARY:" + summary,
+		"STATUS:NEEDS-ACTION",
+		"END:VTODO",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	resp, _ := c.do("PUT", path, header, body)
+	return resp
+}
+
+func (c *davClient) delete(path, ifMatch string) *http.Response {
+	c.t.Helper()
+
+	header := http.Header{}
+	if ifMatch != "" {
+		header.Set("If-Match", ifMatch)
+	}
+	resp, _ := c.do("DELETE", path, header, "")
+	return resp
+}
+
+func taskPath(collection, uuid string) string {
+	return "/caldav/" + collection + "/" + uuid + ".ics"
+}
+
+func TestPropfind(t *testing.T) {
+	dav := newDAVClient(t)
+
+	root := dav.propfind("/caldav/", "1")
+	for _, href := range []string{"/caldav/", "/caldav/_default/", "/caldav/archive/", "/caldav/home/", "/caldav/work/"} {
+		if _, ok := root[href]; !ok {
+			t.Errorf("root PROPFIND is missing %s: %v", href, slices.Collect(maps.Keys(root)))
+		}
+	}
+	if calls := dav.fake.CallsOf("export"); len(calls) != 1 {
+		t.Errorf("listing the calendars ran %d exports, want 1: %q", len(calls), calls)
+	}
+	work := root["/caldav/work/"].Propstats[0].Prop
+	if work.ResourceType.Calendar == nil || work.DisplayName != "work" || work.CTag == "" {
+		t.Errorf("work calendar properties = %+v", work)
+	}
+
+	collection := dav.propfind("/caldav/work/", "1")
+	if len(collection) != 2 {
+		t.Errorf("work PROPFIND returned %v, want the calendar and one task", slices.Collect(maps.Keys(collection)))
+	}
+	if etag, _ := collection[taskPath("work", workUUID)].okProp(); etag != workETag {
+		t.Errorf("task ETag = %s, want %s", etag, workETag)
+	}
+
+	task := dav.propfind(taskPath("work", workUUID), "0")
+	if etag, _ := task[taskPath("work", workUUID)].okProp(); etag != workETag {
+		t.Errorf("task PROPFIND ETag = %s, want %s", etag, workETag)
+	}
+
+	for _, path := range []string{taskPath("work", deletedUUID), taskPath("home", workUUID), taskPath("work", unknownUUID)} {
+		if resp, _ := dav.do("PROPFIND", path, http.Header{"Depth": {"0"}}, ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("PROPFIND %s: status %d, want 404", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestCalendarQuery(t *testing.T) {
+	dav := newDAVClient(t)
+
+	got := dav.calendarQuery("/caldav/work/")
+	if len(got) != 1 {
+		t.Fatalf("calendar-query returned %v, want only the pending work task", slices.Collect(maps.Keys(got)))
+	}
+	etag, data := got[taskPath("work", workUUID)].okProp()
+	if etag != workETag {
+		t.Errorf("ETag = %s, want %s", etag, workETag)
+	}
+	for _, want := range []string{"BEGIN:VTODO", "UID:" + workUUID, "SUMMARY:Write docs", "CATEGORIES:docs"} {
+		if !strings.Contains(data, want) {
+			t.Errorf("calendar-data is missing %q:\n%s", want, data)
+		}
+	}
+
+	if got := dav.calendarQuery("/caldav/_default/"); len(got) != 1 || got[taskPath("_default", defaultUUID)].Href == "" {
+		t.Errorf("default calendar-query returned %v", slices.Collect(maps.Keys(got)))
+	}
+}
+
+func TestCalendarMultiget(t *testing.T) {
+	dav := newDAVClient(t)
+
+	found := taskPath("work", workUUID)
+	missing := []string{
+		taskPath("work", unknownUUID),
+		taskPath("work", deletedUUID),
+		taskPath("home", homeUUID),
+	}
+	got := dav.multiget("/caldav/work/", append([]string{found}, missing...)...)
+
+	if etag, data := got[found].okProp(); etag != workETag || !strings.Contains(data, "UID:"+workUUID) {
+		t.Errorf("multiget of %s = %s, %q", found, etag, data)
+	}
+	for _, href := range missing {
+		if r, ok := got[href]; !ok || !strings.Contains(r.Status, " 404 ") {
+			t.Errorf("multiget of %s = %+v, want 404", href, r)
+		}
+	}
+}
+
+// A new resource is created under the UUID in its href, so the client
+// finds it where it put it
+func TestPutCreates(t *testing.T) {
+	dav := newDAVClient(t)
+	path := taskPath("work", unknownUUID)
+
+	resp := dav.put(path, "", "Plan the offsite")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status %d, want 201", resp.StatusCode)
+	}
+	if location := resp.Header.Get("Location"); location != "" {
+		t.Errorf("Location %s, want none", location)
+	}
+	task := dav.fake.Task(unknownUUID)
+	if task == nil || task["description"] != "Plan the offsite" || task["project"] != "work" {
+		t.Fatalf("created %v", task)
+	}
+	if calls := dav.fake.CallsOf("add"); len(calls) != 0 {
+		t.Errorf("created with add, which assigns its own UUID: %q", calls)
+	}
+
+	etag := resp.Header.Get("ETag")
+	collection := dav.propfind("/caldav/work/", "1")
+	if got, _ := collection[path].okProp(); etag == "" || got != etag {
+		t.Errorf("listed ETag %q, PUT answered %q", got, etag)
+	}
+
+	// Repeating the PUT updates the task instead of adding another
+	if resp := dav.put(path, etag, "Plan the offsite in May"); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("second PUT: status %d, want 204", resp.StatusCode)
+	}
+	if n := len(dav.fake.Tasks()); n != len(fixtureTasks())+1 {
+		t.Errorf("%d tasks, want one more than the fixture", n)
+	}
+}
+
+// An href that is not a UUID moves to the UID, or to a UUID derived from
+// it, and repeating the PUT finds the same task
+func TestPutCreatesAtOtherHref(t *testing.T) {
+	tests := []struct {
+		name string
+		uid  string
+		want string
+	}{
+		{"uuid uid", strings.ToUpper(unknownUUID), unknownUUID},
+		{"other uid", "event-1234@example.com", resourceUUID("", "event-1234@example.com")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dav := newDAVClient(t)
+			path := taskPath("work", "new-event-from-client")
+
+			resp := dav.putUID(path, "", tt.uid, "Plan the offsite")
+			if resp.StatusCode != http.StatusCreated {
+				t.Fatalf("status %d, want 201", resp.StatusCode)
+			}
+			if location := resp.Header.Get("Location"); location != taskPath("work", tt.want) {
+				t.Errorf("Location %s, want %s", location, taskPath("work", tt.want))
+			}
+			if task := dav.fake.Task(tt.want); task == nil || task["description"] != "Plan the offsite" {
+				t.Fatalf("created %v", task)
+			}
+
+			if resp := dav.putUID(path, "", tt.uid, "Plan the offsite in May"); resp.StatusCode != http.StatusNoContent {
+				t.Errorf("second PUT: status %d, want 204", resp.StatusCode)
+			}
+			if n := len(dav.fake.Tasks()); n != len(fixtureTasks())+1 {
+				t.Errorf("%d tasks, want one more than the fixture", n)
+			}
+		})
+	}
+}
+
+func TestPutIfMatch(t *testing.T) {
+	dav := newDAVClient(t)
+	path := taskPath("work", workUUID)
+
+	dav.fake.ResetCalls()
+	if resp := dav.put(path, `"1"`, "Stale edit"); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("stale If-Match: status %d, want 412", resp.StatusCode)
+	}
+	if calls := dav.fake.CallsOf("modify"); len(calls) != 0 {
+		t.Fatalf("stale If-Match modified the task: %v", calls)
+	}
+
+	resp := dav.put(path, workETag, "Write the docs")
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("current If-Match: status %d, want 204", resp.StatusCode)
+	}
+	if got := dav.fake.Task(workUUID)["description"]; got != "Write the docs" {
+		t.Errorf("description = %v, want the new summary", got)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" || etag == workETag {
+		t.Errorf("ETag after the update = %q, want a new one", etag)
+	}
+
+	// The old ETag no longer matches once the task changed
+	if resp := dav.put(path, workETag, "Lost update"); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("outdated If-Match: status %d, want 412", resp.StatusCode)
+	}
+}
+
+// A task that cannot be read must not be taken for a missing one and
+// created again
+func TestPutFailsWhenTaskCannotBeRead(t *testing.T) {
+	dav := newDAVClient(t)
+
+	dav.fake.Fail("export", true)
+	dav.fake.ResetCalls()
+
+	if resp := dav.put(taskPath("work", workUUID), "", "Write docs"); resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("status %d, want 500", resp.StatusCode)
+	}
+	if resp := dav.put(taskPath("work", workUUID), `"*"`, "Write docs"); resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("If-Match *: status %d, want 500", resp.StatusCode)
+	}
+	if calls := append(dav.fake.CallsOf("add"), dav.fake.CallsOf("modify")...); len(calls) != 0 {
+		t.Errorf("a write ran although the task could not be read: %v", calls)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	dav := newDAVClient(t)
+	path := taskPath("work", workUUID)
+
+	if resp := dav.delete(path, `"1"`); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("stale If-Match: status %d, want 412", resp.StatusCode)
+	}
+	if got := dav.fake.Task(workUUID)["status"]; got != "pending" {
+		t.Fatalf("stale If-Match changed the status to %v", got)
+	}
+
+	if resp := dav.delete(path, workETag); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("current If-Match: status %d, want 204", resp.StatusCode)
+	}
+	if got := dav.fake.Task(workUUID)["status"]; got != "deleted" {
+		t.Errorf("status = %v, want deleted", got)
+	}
+
+	for _, path := range []string{path, taskPath("work", unknownUUID), taskPath("work", homeUUID)} {
+		if resp := dav.delete(path, ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("DELETE %s: status %d, want 404", path, resp.StatusCode)
+		}
+	}
+
+	dav.fake.Fail("export", true)
+	if resp := dav.delete(taskPath("home", homeUUID), ""); resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("unreadable task: status %d, want 500", resp.StatusCode)
+	}
+}
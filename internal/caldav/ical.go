@@ -0,0 +1,432 @@
+package caldav
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+const (
+	icalDateTimeUTC   = "20060102T150405Z"
+	icalDateTimeLocal = "20060102T150405"
+	icalDate          = "20060102"
+)
+
+// vtodo holds the VTODO fields that map onto Taskwarrior attributes
+type vtodo struct {
+	UID         string
+	Summary     string
+	Status      string
+	Priority    int
+	HasPriority bool
+	Due         *time.Time
+	Start       *time.Time
+	Categories  []string
+	RelatedTo   []string
+}
+
+// icalProperty is a single unfolded content line
+type icalProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// encodeVTODO renders a task as a VCALENDAR object containing a single VTODO
+func encodeVTODO(task taskwarrior.Task) []byte {
+	var buf bytes.Buffer
+
+	writeCalendarStart(&buf)
+	writeVTODO(&buf, task)
+	writeLine(&buf, "END:VCALENDAR")
+
+	return buf.Bytes()
+}
+
+// CalendarWriter writes tasks as the VTODOs of a single VCALENDAR object,
+// the contents of an iCalendar (.ics) file. It implements the exporters
+// Writer interface.
+type CalendarWriter struct {
+	w       io.Writer
+	started bool
+}
+
+// NewCalendarWriter creates an iCalendar writer
+func NewCalendarWriter(w io.Writer) *CalendarWriter {
+	return &CalendarWriter{w: w}
+}
+
+func (cw *CalendarWriter) start(buf *bytes.Buffer) {
+	if !cw.started {
+		cw.started = true
+		writeCalendarStart(buf)
+	}
+}
+
+// WriteTask writes the next task as a VTODO
+func (cw *CalendarWriter) WriteTask(task taskwarrior.Task) error {
+	var buf bytes.Buffer
+	cw.start(&buf)
+	writeVTODO(&buf, task)
+	_, err := cw.w.Write(buf.Bytes())
+	return err
+}
+
+// Close ends the VCALENDAR object. It does not close the underlying
+// writer.
+func (cw *CalendarWriter) Close() error {
+	var buf bytes.Buffer
+	cw.start(&buf)
+	writeLine(&buf, "END:VCALENDAR")
+	_, err := cw.w.Write(buf.Bytes())
+	return err
+}
+
+func writeCalendarStart(buf *bytes.Buffer) {
+	writeLine(buf, "BEGIN:VCALENDAR")
+	writeLine(buf, "VERSION:2.0")
+	writeLine(buf, "PRODID:-//dotbinio//taskwarrior-api//EN")
+}
+
+// writeVTODO writes a task as a VTODO component
+func writeVTODO(buf *bytes.Buffer, task taskwarrior.Task) {
+	writeLine(buf, "BEGIN:VTODO")
+	writeLine(buf, "UID:"+task.UUID)
+
+	stamp := time.Now().UTC()
+	if task.Modified != nil {
+		stamp = task.Modified.UTC()
+	}
+	writeLine(buf, "DTSTAMP:"+stamp.Format(icalDateTimeUTC))
+
+	if task.Entry != nil {
+		writeLine(buf, "CREATED:"+task.Entry.UTC().Format(icalDateTimeUTC))
+	}
+	if task.Modified != nil {
+		writeLine(buf, "LAST-MODIFIED:"+task.Modified.UTC().Format(icalDateTimeUTC))
+	}
+
+	writeLine(buf, "SUMMARY:"+escapeText(task.Description))
+	writeLine(buf, "STATUS:"+vtodoStatus(task))
+
+	if p := icalPriority(task.Priority); p > 0 {
+		writeLine(buf, "PRIORITY:"+strconv.Itoa(p))
+	}
+	if task.Scheduled != nil {
+		writeLine(buf, "DTSTART:"+task.Scheduled.UTC().Format(icalDateTimeUTC))
+	}
+	if task.Due != nil {
+		writeLine(buf, "DUE:"+task.Due.UTC().Format(icalDateTimeUTC))
+	}
+	if task.Status == taskwarrior.StatusCompleted && task.End != nil {
+		writeLine(buf, "COMPLETED:"+task.End.UTC().Format(icalDateTimeUTC))
+	}
+
+	if len(task.Tags) > 0 {
+		escaped := make([]string, len(task.Tags))
+		for i, tag := range task.Tags {
+			escaped[i] = escapeText(tag)
+		}
+		writeLine(buf, "CATEGORIES:"+strings.Join(escaped, ","))
+	}
+
+	for _, dep := range task.Depends {
+		writeLine(buf, "RELATED-TO;RELTYPE=DEPENDS-ON:"+dep)
+	}
+
+	if len(task.Annotations) > 0 {
+		notes := make([]string, len(task.Annotations))
+		for i, annotation := range task.Annotations {
+			notes[i] = annotation.Description
+		}
+		writeLine(buf, "DESCRIPTION:"+escapeText(strings.Join(notes, "\n")))
+	}
+
+	writeLine(buf, "END:VTODO")
+}
+
+// parseVTODO extracts the first VTODO component from an iCalendar object
+func parseVTODO(data []byte) (*vtodo, error) {
+	props, err := unfoldLines(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var todo *vtodo
+	depth := 0
+	for _, prop := range props {
+		switch prop.Name {
+		case "BEGIN":
+			if strings.EqualFold(prop.Value, "VTODO") && todo == nil && depth == 0 {
+				todo = &vtodo{}
+				depth = 1
+				continue
+			}
+			if depth > 0 {
+				depth++
+			}
+			continue
+		case "END":
+			if depth > 0 {
+				depth--
+			}
+			continue
+		}
+
+		// Only read properties that belong directly to the VTODO,
+		// skipping nested components such as VALARM
+		if todo == nil || depth != 1 {
+			continue
+		}
+
+		switch prop.Name {
+		case "UID":
+			todo.UID = prop.Value
+		case "SUMMARY":
+			todo.Summary = unescapeText(prop.Value)
+		case "STATUS":
+			todo.Status = strings.ToUpper(prop.Value)
+		case "PRIORITY":
+			if p, err := strconv.Atoi(prop.Value); err == nil {
+				todo.Priority = p
+				todo.HasPriority = true
+			}
+		case "DUE":
+			t, err := parseICalTime(prop)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DUE: %w", err)
+			}
+			todo.Due = &t
+		case "DTSTART":
+			t, err := parseICalTime(prop)
+			if err != nil {
+				return nil, fmt.Errorf("invalid DTSTART: %w", err)
+			}
+			todo.Start = &t
+		case "CATEGORIES":
+			for _, category := range splitList(prop.Value) {
+				if category = strings.TrimSpace(unescapeText(category)); category != "" {
+					todo.Categories = append(todo.Categories, category)
+				}
+			}
+		case "RELATED-TO":
+			reltype := strings.ToUpper(prop.Params["RELTYPE"])
+			if reltype == "DEPENDS-ON" && taskwarrior.ValidateTaskUUID(prop.Value) {
+				todo.RelatedTo = append(todo.RelatedTo, prop.Value)
+			}
+		}
+	}
+
+	if todo == nil {
+		return nil, fmt.Errorf("no VTODO component found")
+	}
+
+	return todo, nil
+}
+
+// unfoldLines joins folded content lines and splits them into properties
+func unfoldLines(data []byte) ([]icalProperty, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read iCalendar data: %w", err)
+	}
+
+	props := make([]icalProperty, 0, len(lines))
+	for _, line := range lines {
+		prop, err := parseContentLine(line)
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, prop)
+	}
+
+	return props, nil
+}
+
+// parseContentLine parses "NAME;PARAM=VALUE:value" into its parts
+func parseContentLine(line string) (icalProperty, error) {
+	// The value starts at the first colon that is not inside a quoted parameter
+	inQuotes := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return icalProperty{}, fmt.Errorf("malformed content line: %q", line)
+	}
+
+	prop := icalProperty{
+		Params: make(map[string]string),
+		Value:  line[colon+1:],
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	prop.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		prop.Params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+	}
+
+	return prop, nil
+}
+
+// parseICalTime parses DATE and DATE-TIME values, honouring TZID when present
+func parseICalTime(prop icalProperty) (time.Time, error) {
+	value := prop.Value
+
+	if strings.EqualFold(prop.Params["VALUE"], "DATE") || len(value) == len(icalDate) {
+		return time.Parse(icalDate, value)
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(icalDateTimeUTC, value)
+	}
+
+	loc := time.UTC
+	if tzid := prop.Params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+
+	return time.ParseInLocation(icalDateTimeLocal, value, loc)
+}
+
+// vtodoStatus maps a Taskwarrior task onto a VTODO STATUS value
+func vtodoStatus(task taskwarrior.Task) string {
+	switch {
+	case task.Status == taskwarrior.StatusCompleted:
+		return "COMPLETED"
+	case task.Status == taskwarrior.StatusDeleted:
+		return "CANCELLED"
+	case task.Start != nil:
+		return "IN-PROCESS"
+	default:
+		return "NEEDS-ACTION"
+	}
+}
+
+// icalPriority maps H/M/L onto the RFC 5545 1-9 scale
+func icalPriority(priority string) int {
+	switch priority {
+	case taskwarrior.PriorityHigh:
+		return 1
+	case taskwarrior.PriorityMedium:
+		return 5
+	case taskwarrior.PriorityLow:
+		return 9
+	default:
+		return 0
+	}
+}
+
+// taskPriority maps an RFC 5545 priority back onto H/M/L
+func taskPriority(priority int) string {
+	switch {
+	case priority >= 1 && priority <= 4:
+		return taskwarrior.PriorityHigh
+	case priority == 5:
+		return taskwarrior.PriorityMedium
+	case priority >= 6 && priority <= 9:
+		return taskwarrior.PriorityLow
+	default:
+		return ""
+	}
+}
+
+// writeLine writes a content line, folding it at 75 octets
+func writeLine(buf *bytes.Buffer, line string) {
+	// Continuation lines start with a space, which counts towards the limit
+	maxLen := 75
+	for len(line) > maxLen {
+		// Avoid splitting a multi-byte UTF-8 sequence
+		cut := maxLen
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		maxLen = 74
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+// escapeText escapes a TEXT value
+func escapeText(s string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	)
+	return replacer.Replace(s)
+}
+
+// unescapeText reverses escapeText
+func unescapeText(s string) string {
+	replacer := strings.NewReplacer(
+		`\\`, `\`,
+		`\;`, ";",
+		`\,`, ",",
+		`\n`, "\n",
+		`\N`, "\n",
+	)
+	return replacer.Replace(s)
+}
+
+// splitList splits a comma-separated value, ignoring escaped commas
+func splitList(s string) []string {
+	var items []string
+	var current strings.Builder
+
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune('\\')
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',':
+			items = append(items, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	items = append(items, current.String())
+
+	return items
+}
@@ -0,0 +1,153 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// XML namespaces used by WebDAV and CalDAV
+const (
+	nsDAV            = "DAV:"
+	nsCalDAV         = "urn:ietf:params:xml:ns:caldav"
+	nsCalendarServer = "http://calendarserver.org/ns/"
+)
+
+// multistatus is the body of a 207 Multi-Status response
+type multistatus struct {
+	XMLName   xml.Name   `xml:"DAV: multistatus"`
+	Responses []response `xml:"response"`
+}
+
+type response struct {
+	Href      string     `xml:"DAV: href"`
+	Propstats []propstat `xml:"DAV: propstat,omitempty"`
+	Status    string     `xml:"DAV: status,omitempty"`
+}
+
+type propstat struct {
+	Prop   prop   `xml:"DAV: prop"`
+	Status string `xml:"DAV: status"`
+}
+
+type prop struct {
+	Properties []property
+}
+
+// property is a single property element with pre-encoded content
+type property struct {
+	XMLName xml.Name
+	Inner   string `xml:",innerxml"`
+}
+
+// propfindRequest is the body of a PROPFIND request
+type propfindRequest struct {
+	XMLName  xml.Name  `xml:"DAV: propfind"`
+	AllProp  *struct{} `xml:"DAV: allprop"`
+	PropName *struct{} `xml:"DAV: propname"`
+	Prop     propNames `xml:"DAV: prop"`
+}
+
+// reportRequest is the body of a calendar-query or calendar-multiget REPORT
+type reportRequest struct {
+	XMLName xml.Name
+	Prop    propNames   `xml:"DAV: prop"`
+	Hrefs   []string    `xml:"DAV: href"`
+	Filter  *compFilter `xml:"urn:ietf:params:xml:ns:caldav filter>comp-filter"`
+}
+
+type propNames struct {
+	Names []xmlElement `xml:",any"`
+}
+
+type xmlElement struct {
+	XMLName xml.Name
+}
+
+type compFilter struct {
+	Name     string       `xml:"name,attr"`
+	Children []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// wantsVTODO reports whether a calendar-query filter can match VTODO components
+func (f *compFilter) wantsVTODO() bool {
+	if f == nil || len(f.Children) == 0 {
+		return true
+	}
+	for _, child := range f.Children {
+		if child.Name == "VTODO" {
+			return true
+		}
+	}
+	return false
+}
+
+// textProperty builds a property holding escaped character data
+func textProperty(name xml.Name, value string) property {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(value))
+	return property{XMLName: name, Inner: buf.String()}
+}
+
+// hrefProperty builds a property wrapping a single DAV:href
+func hrefProperty(name xml.Name, href string) property {
+	var buf bytes.Buffer
+	buf.WriteString(`<href xmlns="DAV:">`)
+	xml.EscapeText(&buf, []byte(href))
+	buf.WriteString(`</href>`)
+	return property{XMLName: name, Inner: buf.String()}
+}
+
+// rawProperty builds a property from a trusted XML fragment
+func rawProperty(name xml.Name, inner string) property {
+	return property{XMLName: name, Inner: inner}
+}
+
+// propstats splits found and missing properties into 200 and 404 propstats
+func propstats(found []property, missing []xml.Name) []propstat {
+	var result []propstat
+	if len(found) > 0 {
+		result = append(result, propstat{
+			Prop:   prop{Properties: found},
+			Status: statusLine(http.StatusOK),
+		})
+	}
+	if len(missing) > 0 {
+		props := make([]property, len(missing))
+		for i, name := range missing {
+			props[i] = property{XMLName: name}
+		}
+		result = append(result, propstat{
+			Prop:   prop{Properties: props},
+			Status: statusLine(http.StatusNotFound),
+		})
+	}
+	return result
+}
+
+func statusLine(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code))
+}
+
+// decodeXML decodes an optional XML request body
+func decodeXML(r io.Reader, v interface{}) error {
+	body, err := io.ReadAll(io.LimitReader(r, 1<<20))
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return io.EOF
+	}
+	return xml.Unmarshal(body, v)
+}
+
+// writeMultistatus writes a 207 Multi-Status response
+func writeMultistatus(w http.ResponseWriter, ms multistatus) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Encode(ms)
+}
@@ -0,0 +1,282 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Token scopes, from least to most powerful
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+// DefaultTenant is the name of the tenant served by TW_API_TOKENS using
+// TW_DATA_LOCATION and TW_TASKRC_LOCATION
+const DefaultTenant = "default"
+
+// MaxHashedTokensWithoutID is the most hashed tokens without an ID. Each
+// is verified against every request with an unknown token, so their
+// number bounds the hash computations a request can cause.
+const MaxHashedTokensWithoutID = 4
+
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// TokenConfig describes an API token, given either in plain text or as an
+// argon2id or bcrypt hash. The ID of a generated token lets the server
+// verify a single hash per request. Tokens without a scope are admin
+// tokens. A token restricted to a project or tags only sees tasks in that
+// project (including subprojects) carrying all of the tags.
+type TokenConfig struct {
+	Name      string     `yaml:"name"`
+	Token     string     `yaml:"token"`
+	ID        string     `yaml:"id"`
+	Hash      string     `yaml:"hash"`
+	Scope     string     `yaml:"scope"`
+	Project   string     `yaml:"project"`
+	Tags      []string   `yaml:"tags"`
+	ExpiresAt *time.Time `yaml:"expires_at"`
+}
+
+// UnmarshalYAML allows a token or its hash to be given as a plain string
+func (t *TokenConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		if isHash(value.Value) {
+			t.Hash = value.Value
+		} else {
+			t.Token = value.Value
+		}
+		return nil
+	}
+
+	type plain TokenConfig
+	return value.Decode((*plain)(t))
+}
+
+// isHash reports whether s looks like an argon2id or bcrypt hash
+func isHash(s string) bool {
+	return strings.HasPrefix(s, "$argon2id$") || strings.HasPrefix(s, "$2")
+}
+
+// TenantConfig maps a set of tokens to their own Taskwarrior data. The
+// taskrc defaults to a file named taskrc inside the data location.
+type TenantConfig struct {
+	Name           string        `yaml:"name"`
+	Tokens         []TokenConfig `yaml:"tokens"`
+	DataLocation   string        `yaml:"data_location"`
+	TaskrcLocation string        `yaml:"taskrc_location"`
+}
+
+// HasDefaultTenant reports whether the data location from TW_DATA_LOCATION
+// is served, which is the case when tokens are configured outside the
+// tenants file, can be created through the token store or are issued by an
+// OIDC provider
+func (c *Config) HasDefaultTenant() bool {
+	return len(c.Auth.Tokens) > 0 || c.Auth.Store.Enabled || c.Auth.OIDC.Enabled()
+}
+
+// applyTokenDefaults fills in token names and scopes that were left out
+func applyTokenDefaults(config *Config) {
+	applyDefaults := func(tokens []TokenConfig) {
+		for i := range tokens {
+			if tokens[i].Scope == "" {
+				tokens[i].Scope = ScopeAdmin
+			}
+			if tokens[i].Name != "" {
+				continue
+			}
+			switch {
+			case tokens[i].ID != "":
+				tokens[i].Name = "token-" + tokens[i].ID
+			case tokens[i].Token != "" || tokens[i].Hash != "":
+				// Derive a stable name that does not reveal the token
+				sum := sha256.Sum256([]byte(tokens[i].Token + tokens[i].Hash))
+				tokens[i].Name = "token-" + hex.EncodeToString(sum[:4])
+			}
+		}
+	}
+
+	applyDefaults(config.Auth.Tokens)
+	for i := range config.Tenants {
+		applyDefaults(config.Tenants[i].Tokens)
+	}
+}
+
+// validateAuth validates tokens and ensures tenants are strictly isolated:
+// every tenant has its own data location and no token is shared between
+// tenants
+func validateAuth(config *Config) error {
+	names := make(map[string]bool)
+	locations := make(map[string]string)
+	tokens := make(map[string]string)
+	tokenNames := make(map[string]bool)
+	withoutID := 0
+
+	validateTokens := func(path, tenant string, list []TokenConfig) error {
+		for i, token := range list {
+			key := fmt.Sprintf("%s[%d]", path, i)
+			secret := token.Token
+			switch {
+			case token.Token == "" && token.Hash == "":
+				return fmt.Errorf("%s: empty token", key)
+			case token.Token != "" && token.Hash != "":
+				return fmt.Errorf("%s: token and hash are mutually exclusive", key)
+			case token.Hash != "":
+				if !isHash(token.Hash) {
+					return fmt.Errorf("%s.hash: must be an argon2id or bcrypt hash", key)
+				}
+				secret = token.Hash
+				if token.ID == "" {
+					withoutID++
+				}
+				if withoutID > MaxHashedTokensWithoutID {
+					return fmt.Errorf("%s.id: at most %d hashed tokens may be configured without an id; use tokens created with `token generate`",
+						key, MaxHashedTokensWithoutID)
+				}
+			}
+			if other, ok := tokens[secret]; ok {
+				return fmt.Errorf("%s: token %s is already used by tenant %s", key, token.Name, other)
+			}
+			tokens[secret] = tenant
+
+			if tokenNames[token.Name] {
+				return fmt.Errorf("%s.name: duplicate token name %s", key, token.Name)
+			}
+			tokenNames[token.Name] = true
+
+			switch token.Scope {
+			case ScopeRead, ScopeWrite, ScopeAdmin:
+			default:
+				return fmt.Errorf("%s.scope: invalid scope %q (must be read, write or admin)", key, token.Scope)
+			}
+		}
+		return nil
+	}
+
+	if config.HasDefaultTenant() {
+		names[DefaultTenant] = true
+		locations[filepath.Clean(ExpandPath(config.Taskwarrior.DataLocation))] = DefaultTenant
+		if err := validateTokens("auth.tokens", DefaultTenant, config.Auth.Tokens); err != nil {
+			return err
+		}
+	}
+
+	for i, tenant := range config.Tenants {
+		key := fmt.Sprintf("tenants[%d]", i)
+		if !tenantNamePattern.MatchString(tenant.Name) {
+			return fmt.Errorf("%s.name: invalid tenant name %q", key, tenant.Name)
+		}
+		if names[tenant.Name] {
+			return fmt.Errorf("%s.name: duplicate tenant name %s", key, tenant.Name)
+		}
+		names[tenant.Name] = true
+
+		if tenant.DataLocation == "" {
+			return fmt.Errorf("%s.data_location: is required", key)
+		}
+		location := filepath.Clean(ExpandPath(tenant.DataLocation))
+		if other, ok := locations[location]; ok {
+			return fmt.Errorf("%s.data_location: already used by tenant %s", key, other)
+		}
+		locations[location] = tenant.Name
+
+		if len(tenant.Tokens) == 0 {
+			return fmt.Errorf("%s.tokens: at least one token is required", key)
+		}
+		if err := validateTokens(key+".tokens", tenant.Name, tenant.Tokens); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateOIDC checks the OIDC settings when JWT bearer tokens are enabled
+func validateOIDC(oidc OIDCConfig) error {
+	if !oidc.Enabled() {
+		return nil
+	}
+
+	if oidc.Audience == "" {
+		return fmt.Errorf("auth.oidc.audience: is required when auth.oidc.issuer is set")
+	}
+	if oidc.JWKSURL != "" && oidc.JWKSFile != "" {
+		return fmt.Errorf("auth.oidc.jwks_file: is mutually exclusive with auth.oidc.jwks_url")
+	}
+	if oidc.JWKSRefresh <= 0 {
+		return fmt.Errorf("auth.oidc.jwks_refresh: invalid refresh interval %s", oidc.JWKSRefresh)
+	}
+	if oidc.Leeway < 0 {
+		return fmt.Errorf("auth.oidc.leeway: invalid leeway %s", oidc.Leeway)
+	}
+	if oidc.NameClaim == "" {
+		return fmt.Errorf("auth.oidc.name_claim: is required")
+	}
+	if oidc.ScopeClaim == "" {
+		return fmt.Errorf("auth.oidc.scope_claim: is required")
+	}
+
+	switch oidc.DefaultScope {
+	case "", ScopeRead, ScopeWrite, ScopeAdmin:
+	default:
+		return fmt.Errorf("auth.oidc.default_scope: invalid scope %q (must be read, write or admin)", oidc.DefaultScope)
+	}
+
+	return nil
+}
+
+// loadTokensFile reads token definitions from a YAML file with a top-level
+// tokens list
+func loadTokensFile(path string) ([]TokenConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Tokens []TokenConfig `yaml:"tokens"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	return file.Tokens, nil
+}
+
+// loadTenantsFile reads tenant definitions from a YAML file with a
+// top-level tenants list
+func loadTenantsFile(path string) ([]TenantConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Tenants []TenantConfig `yaml:"tenants"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	applyTenantDefaults(file.Tenants)
+	return file.Tenants, nil
+}
+
+// applyTenantDefaults places the taskrc of tenants without one inside
+// their data location
+func applyTenantDefaults(tenants []TenantConfig) {
+	for i := range tenants {
+		if tenants[i].TaskrcLocation == "" && tenants[i].DataLocation != "" {
+			tenants[i].TaskrcLocation = filepath.Join(tenants[i].DataLocation, "taskrc")
+		}
+	}
+}
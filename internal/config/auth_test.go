@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const testHash = "$argon2id$v=19$m=65536,t=3,p=4$eUkb5BPeVtu/F3f6u8Tujw$MyNZbWwj/GAy6rgSS4wSPdMQOOUNuxIF+QDCujpG7BQ"
+
+func TestHashedTokensWithoutIDLimited(t *testing.T) {
+	tests := []struct {
+		name      string
+		withoutID int
+		withID    int
+		tenant    int
+		wantErr   bool
+	}{
+		{name: "at the limit", withoutID: MaxHashedTokensWithoutID},
+		{name: "over the limit", withoutID: MaxHashedTokensWithoutID + 1, wantErr: true},
+		{name: "with ids", withoutID: 1, withID: 20},
+		{name: "across tenants", withoutID: MaxHashedTokensWithoutID, tenant: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := func(i int, id string) TokenConfig {
+				// Hashes must differ, as tokens cannot be shared
+				return TokenConfig{Name: fmt.Sprintf("token-%d-%s", i, id), ID: id, Hash: fmt.Sprintf("%s%d", testHash, i), Scope: ScopeRead}
+			}
+
+			cfg := &Config{Taskwarrior: TaskwarriorConfig{DataLocation: t.TempDir()}}
+			n := 0
+			for range tt.withoutID {
+				cfg.Auth.Tokens = append(cfg.Auth.Tokens, token(n, ""))
+				n++
+			}
+			for i := range tt.withID {
+				cfg.Auth.Tokens = append(cfg.Auth.Tokens, token(n, fmt.Sprintf("%08x", i)))
+				n++
+			}
+			if tt.tenant > 0 {
+				tenant := TenantConfig{Name: "other", DataLocation: t.TempDir()}
+				for range tt.tenant {
+					tenant.Tokens = append(tenant.Tokens, token(n, ""))
+					n++
+				}
+				cfg.Tenants = append(cfg.Tenants, tenant)
+			}
+
+			err := validateAuth(cfg)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "without an id") {
+				t.Errorf("error %q does not explain the limit", err)
+			}
+		})
+	}
+}
@@ -3,8 +3,11 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Config holds all application configuration
@@ -14,29 +17,78 @@ type Config struct {
 	Auth        AuthConfig        `yaml:"auth"`
 	Logging     LoggingConfig     `yaml:"logging"`
 	CORS        CORSConfig        `yaml:"cors"`
+	CalDAV      CalDAVConfig      `yaml:"caldav"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks"`
+	Sync        SyncConfig        `yaml:"sync"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	Audit       AuditConfig       `yaml:"audit"`
+	Metrics     MetricsConfig     `yaml:"metrics"`
+	Tracing     TracingConfig     `yaml:"tracing"`
+	Tenants     []TenantConfig    `yaml:"tenants"`
 }
 
 // ServerConfig holds server-specific configuration
 type ServerConfig struct {
-	Host     string `yaml:"host"`
-	Port     int    `yaml:"port"`
-	EnableUI bool   `yaml:"enable_ui"`
+	Host           string   `yaml:"host"`
+	Port           int      `yaml:"port"`
+	EnableUI       bool     `yaml:"enable_ui"`
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// ReloadInterval is how often the configuration, tokens and tenants
+	// files are checked for changes. 0 reloads only on SIGHUP.
+	ReloadInterval time.Duration `yaml:"reload_interval"`
 }
 
 // TaskwarriorConfig holds Taskwarrior-specific configuration
 type TaskwarriorConfig struct {
-	DataLocation   string `yaml:"data_location"`
-	TaskrcLocation string `yaml:"taskrc_location"`
+	DataLocation   string        `yaml:"data_location"`
+	TaskrcLocation string        `yaml:"taskrc_location"`
+	WatchInterval  time.Duration `yaml:"watch_interval"`
+	CacheEnabled   bool          `yaml:"cache_enabled"`
 }
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
-	Tokens []string `yaml:"tokens"`
+	Tokens      []TokenConfig    `yaml:"tokens"`
+	TokensFile  string           `yaml:"tokens_file"`
+	TenantsFile string           `yaml:"tenants_file"`
+	Store       TokenStoreConfig `yaml:"store"`
+	OIDC        OIDCConfig       `yaml:"oidc"`
 }
 
-// LoggingConfig holds logging configuration
+// TokenStoreConfig holds configuration of tokens managed through the API
+type TokenStoreConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	DataLocation string `yaml:"data_location"`
+}
+
+// OIDCConfig holds configuration for JWT bearer tokens issued by an OIDC
+// provider. Claims may name nested values with dots, e.g.
+// realm_access.roles.
+type OIDCConfig struct {
+	Issuer       string        `yaml:"issuer"`
+	Audience     string        `yaml:"audience"`
+	JWKSURL      string        `yaml:"jwks_url"`
+	JWKSFile     string        `yaml:"jwks_file"`
+	JWKSRefresh  time.Duration `yaml:"jwks_refresh"`
+	Leeway       time.Duration `yaml:"leeway"`
+	NameClaim    string        `yaml:"name_claim"`
+	ScopeClaim   string        `yaml:"scope_claim"`
+	ScopePrefix  string        `yaml:"scope_prefix"`
+	DefaultScope string        `yaml:"default_scope"`
+	TenantClaim  string        `yaml:"tenant_claim"`
+	ProjectClaim string        `yaml:"project_claim"`
+	TagsClaim    string        `yaml:"tags_claim"`
+}
+
+// Enabled reports whether JWT bearer tokens are accepted
+func (o OIDCConfig) Enabled() bool {
+	return o.Issuer != ""
+}
+
+// LoggingConfig holds logging configuration. Format is text or json.
 type LoggingConfig struct {
-	Level string `yaml:"level"`
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
 }
 
 // CORSConfig holds CORS configuration
@@ -45,8 +97,76 @@ type CORSConfig struct {
 	AllowedOrigins []string `yaml:"allowed_origins"`
 }
 
-// Load loads configuration from environment variables
-func Load() (*Config, error) {
+// CalDAVConfig holds CalDAV server configuration
+type CalDAVConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// WebhooksConfig holds outgoing webhook configuration
+type WebhooksConfig struct {
+	Enabled      bool          `yaml:"enabled"`
+	DataLocation string        `yaml:"data_location"`
+	MaxAttempts  int           `yaml:"max_attempts"`
+	Timeout      time.Duration `yaml:"timeout"`
+}
+
+// SyncConfig holds configuration of task sync. Interval and OnChange
+// schedule syncs in the background; the API can always start one.
+type SyncConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	OnChange bool          `yaml:"on_change"`
+	// Debounce is how long to wait after the last change before syncing
+	Debounce time.Duration `yaml:"debounce"`
+}
+
+// RateLimitConfig holds rate limiting and lockout configuration. Limits
+// are requests per minute: per token depending on its scope, and per client
+// IP for requests failing authentication. A lockout threshold of 0
+// disables the lockout.
+type RateLimitConfig struct {
+	Enabled          bool          `yaml:"enabled"`
+	Read             int           `yaml:"read"`
+	Write            int           `yaml:"write"`
+	Admin            int           `yaml:"admin"`
+	Failures         int           `yaml:"failures"`
+	LockoutThreshold int           `yaml:"lockout_threshold"`
+	LockoutDuration  time.Duration `yaml:"lockout_duration"`
+	LockoutMax       time.Duration `yaml:"lockout_max"`
+}
+
+// AuditConfig holds audit log configuration. MaxSize is in megabytes; a
+// MaxFiles of 0 keeps all rotated files.
+type AuditConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	DataLocation string `yaml:"data_location"`
+	MaxSize      int    `yaml:"max_size"`
+	MaxFiles     int    `yaml:"max_files"`
+}
+
+// MetricsConfig holds configuration of the Prometheus metrics endpoint.
+// With an address, metrics are served there on their own; otherwise they
+// are served on the API port and require the token.
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Token   string `yaml:"token"`
+	Address string `yaml:"address"`
+}
+
+// TracingConfig holds OpenTelemetry tracing configuration
+type TracingConfig struct {
+	// Exporter is none, otlp or stdout
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the OTLP/HTTP endpoint URL. If empty, the standard
+	// OTEL_EXPORTER_OTLP_* variables apply.
+	Endpoint    string  `yaml:"endpoint"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Load loads the configuration. Each source overrides the previous one:
+// defaults, the configuration file, environment variables and finally
+// command line flags. flags may be nil.
+func Load(flags *Flags) (*Config, error) {
 	config := &Config{
 		// Set defaults
 		Server: ServerConfig{
@@ -57,21 +177,96 @@ func Load() (*Config, error) {
 		Taskwarrior: TaskwarriorConfig{
 			DataLocation:   "~/.task",
 			TaskrcLocation: "~/.taskrc",
+			WatchInterval:  2 * time.Second,
+			CacheEnabled:   true,
 		},
 		Auth: AuthConfig{
-			Tokens: []string{},
+			Tokens: []TokenConfig{},
+			Store: TokenStoreConfig{
+				Enabled:      false,
+				DataLocation: "~/.taskwarrior-api/tokens",
+			},
+			OIDC: OIDCConfig{
+				JWKSRefresh: time.Hour,
+				Leeway:      time.Minute,
+				NameClaim:   "sub",
+				ScopeClaim:  "scope",
+				ScopePrefix: "taskwarrior:",
+			},
 		},
 		Logging: LoggingConfig{
-			Level: "info",
+			Level:  "info",
+			Format: "text",
 		},
 		CORS: CORSConfig{
 			Enabled:        true,
 			AllowedOrigins: []string{"http://localhost:3000"},
 		},
+		CalDAV: CalDAVConfig{
+			Enabled: false,
+		},
+		Webhooks: WebhooksConfig{
+			Enabled:      false,
+			DataLocation: "~/.taskwarrior-api/webhooks",
+			MaxAttempts:  6,
+			Timeout:      10 * time.Second,
+		},
+		Sync: SyncConfig{
+			Enabled:  false,
+			Interval: 0,
+			OnChange: false,
+			Debounce: 30 * time.Second,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:          true,
+			Read:             300,
+			Write:            300,
+			Admin:            600,
+			Failures:         20,
+			LockoutThreshold: 10,
+			LockoutDuration:  time.Minute,
+			LockoutMax:       time.Hour,
+		},
+		Audit: AuditConfig{
+			Enabled:      false,
+			DataLocation: "~/.taskwarrior-api/audit",
+			MaxSize:      10,
+			MaxFiles:     10,
+		},
+		Metrics: MetricsConfig{
+			Enabled: false,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+		},
 	}
 
-	// Load from environment variables
+	// Load from the configuration file, environment variables and flags
+	if path := flags.File(); path != "" {
+		if err := loadFile(ExpandPath(path), config); err != nil {
+			return nil, fmt.Errorf("failed to load config file %s: %w", path, err)
+		}
+	}
 	loadFromEnv(config)
+	flags.apply(config)
+
+	// Load scoped tokens and tenants
+	if config.Auth.TokensFile != "" {
+		tokens, err := loadTokensFile(ExpandPath(config.Auth.TokensFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tokens file: %w", err)
+		}
+		config.Auth.Tokens = append(config.Auth.Tokens, tokens...)
+	}
+	if config.Auth.TenantsFile != "" {
+		tenants, err := loadTenantsFile(ExpandPath(config.Auth.TenantsFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tenants file: %w", err)
+		}
+		config.Tenants = tenants
+	}
+	applyTokenDefaults(config)
 
 	// Validate configuration
 	if err := validate(config); err != nil {
@@ -95,6 +290,18 @@ func loadFromEnv(config *Config) {
 	if enableUIStr := os.Getenv("TW_API_ENABLE_UI"); enableUIStr != "" {
 		config.Server.EnableUI = enableUIStr == "true" || enableUIStr == "1"
 	}
+	if proxiesStr := os.Getenv("TW_API_TRUSTED_PROXIES"); proxiesStr != "" {
+		proxies := strings.Split(proxiesStr, ",")
+		for i, proxy := range proxies {
+			proxies[i] = strings.TrimSpace(proxy)
+		}
+		config.Server.TrustedProxies = proxies
+	}
+	if intervalStr := os.Getenv("TW_API_RELOAD_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err == nil {
+			config.Server.ReloadInterval = interval
+		}
+	}
 
 	// Taskwarrior configuration
 	if dataLocation := os.Getenv("TW_DATA_LOCATION"); dataLocation != "" {
@@ -103,12 +310,33 @@ func loadFromEnv(config *Config) {
 	if taskrcLocation := os.Getenv("TW_TASKRC_LOCATION"); taskrcLocation != "" {
 		config.Taskwarrior.TaskrcLocation = taskrcLocation
 	}
+	if intervalStr := os.Getenv("TW_WATCH_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err == nil {
+			config.Taskwarrior.WatchInterval = interval
+		}
+	}
+	if cacheStr := os.Getenv("TW_CACHE_ENABLED"); cacheStr != "" {
+		config.Taskwarrior.CacheEnabled = cacheStr == "true" || cacheStr == "1"
+	}
 
 	// Auth configuration
+	if tenantsFile := os.Getenv("TW_API_TENANTS_FILE"); tenantsFile != "" {
+		config.Auth.TenantsFile = tenantsFile
+	}
+	if tokensFile := os.Getenv("TW_API_TOKENS_FILE"); tokensFile != "" {
+		config.Auth.TokensFile = tokensFile
+	}
+	if storeStr := os.Getenv("TW_API_TOKEN_STORE_ENABLED"); storeStr != "" {
+		config.Auth.Store.Enabled = storeStr == "true" || storeStr == "1"
+	}
+	if storeLocation := os.Getenv("TW_API_TOKEN_STORE_DATA_LOCATION"); storeLocation != "" {
+		config.Auth.Store.DataLocation = storeLocation
+	}
+	loadOIDCFromEnv(&config.Auth.OIDC)
 	if tokensStr := os.Getenv("TW_API_TOKENS"); tokensStr != "" {
-		tokens := strings.Split(tokensStr, ",")
-		for i, token := range tokens {
-			tokens[i] = strings.TrimSpace(token)
+		tokens := []TokenConfig{}
+		for _, token := range strings.Split(tokensStr, ",") {
+			tokens = append(tokens, TokenConfig{Token: strings.TrimSpace(token)})
 		}
 		config.Auth.Tokens = tokens
 	}
@@ -117,6 +345,9 @@ func loadFromEnv(config *Config) {
 	if level := os.Getenv("TW_API_LOG_LEVEL"); level != "" {
 		config.Logging.Level = level
 	}
+	if format := os.Getenv("TW_API_LOG_FORMAT"); format != "" {
+		config.Logging.Format = format
+	}
 
 	// CORS configuration
 	if enabledStr := os.Getenv("TW_API_CORS_ENABLED"); enabledStr != "" {
@@ -129,20 +360,214 @@ func loadFromEnv(config *Config) {
 		}
 		config.CORS.AllowedOrigins = origins
 	}
+
+	// CalDAV configuration
+	if enabledStr := os.Getenv("TW_API_CALDAV_ENABLED"); enabledStr != "" {
+		config.CalDAV.Enabled = enabledStr == "true" || enabledStr == "1"
+	}
+
+	// Webhooks configuration
+	if enabledStr := os.Getenv("TW_API_WEBHOOKS_ENABLED"); enabledStr != "" {
+		config.Webhooks.Enabled = enabledStr == "true" || enabledStr == "1"
+	}
+	if dataLocation := os.Getenv("TW_API_WEBHOOKS_DATA_LOCATION"); dataLocation != "" {
+		config.Webhooks.DataLocation = dataLocation
+	}
+	if attemptsStr := os.Getenv("TW_API_WEBHOOKS_MAX_ATTEMPTS"); attemptsStr != "" {
+		if attempts, err := strconv.Atoi(attemptsStr); err == nil {
+			config.Webhooks.MaxAttempts = attempts
+		}
+	}
+	if timeoutStr := os.Getenv("TW_API_WEBHOOKS_TIMEOUT"); timeoutStr != "" {
+		if timeout, err := time.ParseDuration(timeoutStr); err == nil {
+			config.Webhooks.Timeout = timeout
+		}
+	}
+
+	// Sync configuration
+	if enabledStr := os.Getenv("TW_API_SYNC_ENABLED"); enabledStr != "" {
+		config.Sync.Enabled = enabledStr == "true" || enabledStr == "1"
+	}
+	if intervalStr := os.Getenv("TW_API_SYNC_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err == nil {
+			config.Sync.Interval = interval
+		}
+	}
+	if onChangeStr := os.Getenv("TW_API_SYNC_ON_CHANGE"); onChangeStr != "" {
+		config.Sync.OnChange = onChangeStr == "true" || onChangeStr == "1"
+	}
+	if debounceStr := os.Getenv("TW_API_SYNC_DEBOUNCE"); debounceStr != "" {
+		if debounce, err := time.ParseDuration(debounceStr); err == nil {
+			config.Sync.Debounce = debounce
+		}
+	}
+
+	// Rate limit configuration
+	if enabledStr := os.Getenv("TW_API_RATE_LIMIT_ENABLED"); enabledStr != "" {
+		config.RateLimit.Enabled = enabledStr == "true" || enabledStr == "1"
+	}
+	if readStr := os.Getenv("TW_API_RATE_LIMIT_READ"); readStr != "" {
+		if read, err := strconv.Atoi(readStr); err == nil {
+			config.RateLimit.Read = read
+		}
+	}
+	if writeStr := os.Getenv("TW_API_RATE_LIMIT_WRITE"); writeStr != "" {
+		if write, err := strconv.Atoi(writeStr); err == nil {
+			config.RateLimit.Write = write
+		}
+	}
+	if adminStr := os.Getenv("TW_API_RATE_LIMIT_ADMIN"); adminStr != "" {
+		if admin, err := strconv.Atoi(adminStr); err == nil {
+			config.RateLimit.Admin = admin
+		}
+	}
+	if failuresStr := os.Getenv("TW_API_RATE_LIMIT_FAILURES"); failuresStr != "" {
+		if failures, err := strconv.Atoi(failuresStr); err == nil {
+			config.RateLimit.Failures = failures
+		}
+	}
+	if thresholdStr := os.Getenv("TW_API_LOCKOUT_THRESHOLD"); thresholdStr != "" {
+		if threshold, err := strconv.Atoi(thresholdStr); err == nil {
+			config.RateLimit.LockoutThreshold = threshold
+		}
+	}
+	if durationStr := os.Getenv("TW_API_LOCKOUT_DURATION"); durationStr != "" {
+		if duration, err := time.ParseDuration(durationStr); err == nil {
+			config.RateLimit.LockoutDuration = duration
+		}
+	}
+	if maxStr := os.Getenv("TW_API_LOCKOUT_MAX"); maxStr != "" {
+		if max, err := time.ParseDuration(maxStr); err == nil {
+			config.RateLimit.LockoutMax = max
+		}
+	}
+
+	// Audit configuration
+	if enabledStr := os.Getenv("TW_API_AUDIT_ENABLED"); enabledStr != "" {
+		config.Audit.Enabled = enabledStr == "true" || enabledStr == "1"
+	}
+	if dataLocation := os.Getenv("TW_API_AUDIT_DATA_LOCATION"); dataLocation != "" {
+		config.Audit.DataLocation = dataLocation
+	}
+	if sizeStr := os.Getenv("TW_API_AUDIT_MAX_SIZE"); sizeStr != "" {
+		if size, err := strconv.Atoi(sizeStr); err == nil {
+			config.Audit.MaxSize = size
+		}
+	}
+	if filesStr := os.Getenv("TW_API_AUDIT_MAX_FILES"); filesStr != "" {
+		if files, err := strconv.Atoi(filesStr); err == nil {
+			config.Audit.MaxFiles = files
+		}
+	}
+
+	// Metrics configuration
+	if enabledStr := os.Getenv("TW_API_METRICS_ENABLED"); enabledStr != "" {
+		config.Metrics.Enabled = enabledStr == "true" || enabledStr == "1"
+	}
+	if token := os.Getenv("TW_API_METRICS_TOKEN"); token != "" {
+		config.Metrics.Token = token
+	}
+	if address := os.Getenv("TW_API_METRICS_ADDRESS"); address != "" {
+		config.Metrics.Address = address
+	}
+
+	// Tracing configuration
+	if exporter := os.Getenv("TW_API_TRACING_EXPORTER"); exporter != "" {
+		config.Tracing.Exporter = exporter
+	}
+	if endpoint := os.Getenv("TW_API_TRACING_ENDPOINT"); endpoint != "" {
+		config.Tracing.Endpoint = endpoint
+	}
+	if ratioStr := os.Getenv("TW_API_TRACING_SAMPLE_RATIO"); ratioStr != "" {
+		if ratio, err := strconv.ParseFloat(ratioStr, 64); err == nil {
+			config.Tracing.SampleRatio = ratio
+		}
+	}
 }
 
-// validate validates the configuration
+// loadOIDCFromEnv loads the TW_API_OIDC_* variables
+func loadOIDCFromEnv(oidc *OIDCConfig) {
+	if issuer := os.Getenv("TW_API_OIDC_ISSUER"); issuer != "" {
+		oidc.Issuer = issuer
+	}
+	if audience := os.Getenv("TW_API_OIDC_AUDIENCE"); audience != "" {
+		oidc.Audience = audience
+	}
+	if jwksURL := os.Getenv("TW_API_OIDC_JWKS_URL"); jwksURL != "" {
+		oidc.JWKSURL = jwksURL
+	}
+	if jwksFile := os.Getenv("TW_API_OIDC_JWKS_FILE"); jwksFile != "" {
+		oidc.JWKSFile = jwksFile
+	}
+	if claim := os.Getenv("TW_API_OIDC_NAME_CLAIM"); claim != "" {
+		oidc.NameClaim = claim
+	}
+	if claim := os.Getenv("TW_API_OIDC_SCOPE_CLAIM"); claim != "" {
+		oidc.ScopeClaim = claim
+	}
+	if prefix := os.Getenv("TW_API_OIDC_SCOPE_PREFIX"); prefix != "" {
+		oidc.ScopePrefix = prefix
+	}
+	if scope := os.Getenv("TW_API_OIDC_DEFAULT_SCOPE"); scope != "" {
+		oidc.DefaultScope = scope
+	}
+	if claim := os.Getenv("TW_API_OIDC_TENANT_CLAIM"); claim != "" {
+		oidc.TenantClaim = claim
+	}
+	if claim := os.Getenv("TW_API_OIDC_PROJECT_CLAIM"); claim != "" {
+		oidc.ProjectClaim = claim
+	}
+	if claim := os.Getenv("TW_API_OIDC_TAGS_CLAIM"); claim != "" {
+		oidc.TagsClaim = claim
+	}
+	if refreshStr := os.Getenv("TW_API_OIDC_JWKS_REFRESH"); refreshStr != "" {
+		if refresh, err := time.ParseDuration(refreshStr); err == nil {
+			oidc.JWKSRefresh = refresh
+		}
+	}
+	if leewayStr := os.Getenv("TW_API_OIDC_LEEWAY"); leewayStr != "" {
+		if leeway, err := time.ParseDuration(leewayStr); err == nil {
+			oidc.Leeway = leeway
+		}
+	}
+}
+
+// validate validates the configuration. Errors start with the key of the
+// offending setting.
 func validate(config *Config) error {
 	if config.Server.Port < 1 || config.Server.Port > 65535 {
-		return fmt.Errorf("invalid port: %d", config.Server.Port)
+		return fmt.Errorf("server.port: invalid port %d", config.Server.Port)
+	}
+
+	if config.Server.ReloadInterval < 0 {
+		return fmt.Errorf("server.reload_interval: invalid interval %s", config.Server.ReloadInterval)
 	}
 
 	if config.Taskwarrior.DataLocation == "" {
-		return fmt.Errorf("taskwarrior data location is required")
+		return fmt.Errorf("taskwarrior.data_location: is required")
+	}
+
+	if config.Taskwarrior.WatchInterval < 0 {
+		return fmt.Errorf("taskwarrior.watch_interval: invalid interval %s", config.Taskwarrior.WatchInterval)
+	}
+
+	// Tokens may also be created later through the token store or be
+	// issued by an OIDC provider
+	if len(config.Auth.Tokens) == 0 && len(config.Tenants) == 0 &&
+		!config.Auth.Store.Enabled && !config.Auth.OIDC.Enabled() {
+		return fmt.Errorf("auth.tokens: at least one auth token is required")
+	}
+
+	if config.Auth.Store.Enabled && config.Auth.Store.DataLocation == "" {
+		return fmt.Errorf("auth.store.data_location: is required when the token store is enabled")
+	}
+
+	if err := validateAuth(config); err != nil {
+		return err
 	}
 
-	if len(config.Auth.Tokens) == 0 {
-		return fmt.Errorf("at least one auth token is required")
+	if err := validateOIDC(config.Auth.OIDC); err != nil {
+		return err
 	}
 
 	validLogLevels := map[string]bool{
@@ -152,7 +577,82 @@ func validate(config *Config) error {
 		"error": true,
 	}
 	if !validLogLevels[config.Logging.Level] {
-		return fmt.Errorf("invalid log level: %s (must be debug, info, warn, or error)", config.Logging.Level)
+		return fmt.Errorf("logging.level: invalid log level %q (must be debug, info, warn, or error)", config.Logging.Level)
+	}
+
+	if config.Logging.Format != "text" && config.Logging.Format != "json" {
+		return fmt.Errorf("logging.format: invalid log format %q (must be text or json)", config.Logging.Format)
+	}
+
+	if config.Webhooks.Enabled {
+		if config.Webhooks.DataLocation == "" {
+			return fmt.Errorf("webhooks.data_location: is required when webhooks are enabled")
+		}
+		if config.Webhooks.MaxAttempts < 1 {
+			return fmt.Errorf("webhooks.max_attempts: invalid max attempts %d", config.Webhooks.MaxAttempts)
+		}
+	}
+
+	if config.Sync.Enabled {
+		if config.Sync.Interval < 0 {
+			return fmt.Errorf("sync.interval: invalid interval %s", config.Sync.Interval)
+		}
+		if config.Sync.OnChange && config.Sync.Debounce <= 0 {
+			return fmt.Errorf("sync.debounce: must be positive when syncing on change")
+		}
+	}
+
+	if config.Audit.Enabled {
+		if config.Audit.DataLocation == "" {
+			return fmt.Errorf("audit.data_location: is required when the audit log is enabled")
+		}
+		if config.Audit.MaxSize < 1 {
+			return fmt.Errorf("audit.max_size: must be at least 1 MB")
+		}
+		if config.Audit.MaxFiles < 0 {
+			return fmt.Errorf("audit.max_files: must not be negative")
+		}
+	}
+
+	if config.Metrics.Enabled && config.Metrics.Token == "" && config.Metrics.Address == "" {
+		return fmt.Errorf("metrics.token: a token or metrics.address is required when metrics are enabled")
+	}
+
+	switch config.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	default:
+		return fmt.Errorf("tracing.exporter: invalid exporter %q (must be none, otlp or stdout)", config.Tracing.Exporter)
+	}
+	if config.Tracing.SampleRatio < 0 || config.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("tracing.sample_ratio: invalid sample ratio %g (must be between 0 and 1)", config.Tracing.SampleRatio)
+	}
+
+	if config.RateLimit.Enabled {
+		limits := config.RateLimit
+		for _, limit := range []struct {
+			key   string
+			value int
+		}{
+			{"read", limits.Read},
+			{"write", limits.Write},
+			{"admin", limits.Admin},
+			{"failures", limits.Failures},
+		} {
+			if limit.value < 1 {
+				return fmt.Errorf("rate_limit.%s: must be at least 1 request per minute", limit.key)
+			}
+		}
+		if limits.LockoutThreshold < 0 {
+			return fmt.Errorf("rate_limit.lockout_threshold: invalid threshold %d", limits.LockoutThreshold)
+		}
+		if limits.LockoutThreshold > 0 {
+			if limits.LockoutDuration <= 0 {
+				return fmt.Errorf("rate_limit.lockout_duration: must be positive")
+			}
+			if limits.LockoutMax < limits.LockoutDuration {
+				return fmt.Errorf("rate_limit.lockout_max: must not be less than rate_limit.lockout_duration")
+			}
+		}
 	}
 
 	return nil
@@ -162,3 +662,38 @@ func validate(config *Config) error {
 func (c *Config) GetAddress() string {
 	return fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Port)
 }
+
+// Redacted returns a copy of the configuration with plain text tokens
+// and the metrics token hidden, for display
+func (c *Config) Redacted() *Config {
+	redact := func(tokens []TokenConfig) []TokenConfig {
+		redacted := slices.Clone(tokens)
+		for i := range redacted {
+			if redacted[i].Token != "" {
+				redacted[i].Token = "REDACTED"
+			}
+		}
+		return redacted
+	}
+
+	copied := *c
+	copied.Auth.Tokens = redact(c.Auth.Tokens)
+	if copied.Metrics.Token != "" {
+		copied.Metrics.Token = "REDACTED"
+	}
+	copied.Tenants = slices.Clone(c.Tenants)
+	for i := range copied.Tenants {
+		copied.Tenants[i].Tokens = redact(c.Tenants[i].Tokens)
+	}
+	return &copied
+}
+
+// ExpandPath expands a leading "~/" to the user's home directory
+func ExpandPath(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"go.yaml.in/yaml/v3"
+)
+
+var (
+	configPkgPath    = reflect.TypeOf(Config{}).PkgPath()
+	durationType     = reflect.TypeOf(time.Duration(0))
+	yamlUnmarshalerT = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+)
+
+// loadFile applies a YAML or TOML configuration file on top of config.
+// Files ending in .toml are read as TOML, everything else as YAML. Keys
+// that are left out keep their current value.
+func loadFile(path string, config *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// TOML is converted to YAML so that both formats are decoded and
+	// checked the same way
+	isTOML := strings.EqualFold(filepath.Ext(path), ".toml")
+	if isTOML {
+		var values map[string]any
+		if err := toml.Unmarshal(data, &values); err != nil {
+			return err
+		}
+		if data, err = yaml.Marshal(values); err != nil {
+			return err
+		}
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	if isTOML {
+		// Line numbers of the converted document would be misleading
+		clearLines(&doc)
+	}
+
+	if err := decodeNode(doc.Content[0], reflect.ValueOf(config).Elem(), ""); err != nil {
+		return err
+	}
+
+	applyTenantDefaults(config.Tenants)
+	return nil
+}
+
+// decodeNode decodes node into v. Unlike yaml.Node.Decode it rejects
+// unknown keys and names the offending key in errors.
+func decodeNode(node *yaml.Node, v reflect.Value, path string) error {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	t := v.Type()
+
+	switch {
+	case t == durationType:
+		// yaml.v3 reads plain numbers as nanoseconds, which is never meant
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!str" {
+			return keyError(path, node, "must be a duration such as 30s or 5m")
+		}
+	case node.Kind == yaml.ScalarNode && reflect.PointerTo(t).Implements(yamlUnmarshalerT):
+		// Types with their own shorthand, such as tokens given as strings
+	case t.Kind() == reflect.Struct && t.PkgPath() == configPkgPath:
+		return decodeStruct(node, v, path)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct && t.Elem().PkgPath() == configPkgPath:
+		return decodeSlice(node, v, path)
+	}
+
+	if err := node.Decode(v.Addr().Interface()); err != nil {
+		return keyError(path, node, fmt.Sprintf("invalid value %q", node.Value))
+	}
+	return nil
+}
+
+// decodeStruct decodes a mapping into the fields of a struct
+func decodeStruct(node *yaml.Node, v reflect.Value, path string) error {
+	if node.Kind != yaml.MappingNode {
+		return keyError(path, node, "must be a mapping")
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		keyPath := joinKey(path, key.Value)
+
+		field, ok := fieldByKey(v, key.Value)
+		if !ok {
+			return keyError(keyPath, key, "unknown key")
+		}
+		if err := decodeNode(value, field, keyPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeSlice decodes a sequence into a new slice, replacing the current
+// one
+func decodeSlice(node *yaml.Node, v reflect.Value, path string) error {
+	if node.Kind != yaml.SequenceNode {
+		return keyError(path, node, "must be a list")
+	}
+
+	slice := reflect.MakeSlice(v.Type(), len(node.Content), len(node.Content))
+	for i, item := range node.Content {
+		if err := decodeNode(item, slice.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return err
+		}
+	}
+	v.Set(slice)
+
+	return nil
+}
+
+// fieldByKey returns the struct field with the given yaml key
+func fieldByKey(v reflect.Value, key string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == key && name != "" && name != "-" {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func clearLines(node *yaml.Node) {
+	node.Line = 0
+	for _, child := range node.Content {
+		clearLines(child)
+	}
+}
+
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func keyError(path string, node *yaml.Node, message string) error {
+	if node.Line > 0 {
+		return fmt.Errorf("%s: %s (line %d)", path, message, node.Line)
+	}
+	return fmt.Errorf("%s: %s", path, message)
+}
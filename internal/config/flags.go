@@ -0,0 +1,66 @@
+package config
+
+import (
+	"flag"
+	"os"
+)
+
+// Flags holds the command line flags of the server. Flags that are set
+// take precedence over environment variables and the configuration file.
+type Flags struct {
+	// ConfigFile is the configuration file, falling back to TW_API_CONFIG
+	ConfigFile string
+
+	fs             *flag.FlagSet
+	host           string
+	port           int
+	dataLocation   string
+	taskrcLocation string
+	logLevel       string
+	logFormat      string
+}
+
+// RegisterFlags defines the configuration flags on fs
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs}
+	fs.StringVar(&f.ConfigFile, "config", "", "YAML or TOML configuration file (env TW_API_CONFIG)")
+	fs.StringVar(&f.host, "host", "", "address to listen on (env TW_API_HOST)")
+	fs.IntVar(&f.port, "port", 0, "port to listen on (env TW_API_PORT)")
+	fs.StringVar(&f.dataLocation, "data-location", "", "Taskwarrior data directory (env TW_DATA_LOCATION)")
+	fs.StringVar(&f.taskrcLocation, "taskrc-location", "", "Taskwarrior configuration file (env TW_TASKRC_LOCATION)")
+	fs.StringVar(&f.logLevel, "log-level", "", "log level: debug, info, warn or error (env TW_API_LOG_LEVEL)")
+	fs.StringVar(&f.logFormat, "log-format", "", "log format: text or json (env TW_API_LOG_FORMAT)")
+	return f
+}
+
+// File returns the configuration file to load, if any
+func (f *Flags) File() string {
+	if f != nil && f.ConfigFile != "" {
+		return f.ConfigFile
+	}
+	return os.Getenv("TW_API_CONFIG")
+}
+
+// apply overrides config with the flags that were set
+func (f *Flags) apply(config *Config) {
+	if f == nil {
+		return
+	}
+
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "host":
+			config.Server.Host = f.host
+		case "port":
+			config.Server.Port = f.port
+		case "data-location":
+			config.Taskwarrior.DataLocation = f.dataLocation
+		case "taskrc-location":
+			config.Taskwarrior.TaskrcLocation = f.taskrcLocation
+		case "log-level":
+			config.Logging.Level = f.logLevel
+		case "log-format":
+			config.Logging.Format = f.logFormat
+		}
+	})
+}
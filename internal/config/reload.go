@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// Reload returns a copy of c with the settings of next that can change
+// while serving: tokens, CORS and the log level. The keys of other
+// settings that differ are returned, as they only take effect after a
+// restart.
+func (c *Config) Reload(next *Config) (*Config, []string, error) {
+	reloaded := *c
+	var restart []string
+
+	for _, section := range []struct {
+		key           string
+		current, next any
+	}{
+		{"server", c.Server, next.Server},
+		{"taskwarrior", c.Taskwarrior, next.Taskwarrior},
+		{"auth.store", c.Auth.Store, next.Auth.Store},
+		{"auth.oidc", c.Auth.OIDC, next.Auth.OIDC},
+		{"caldav", c.CalDAV, next.CalDAV},
+		{"webhooks", c.Webhooks, next.Webhooks},
+		{"rate_limit", c.RateLimit, next.RateLimit},
+		{"audit", c.Audit, next.Audit},
+		{"metrics", c.Metrics, next.Metrics},
+		{"tracing", c.Tracing, next.Tracing},
+		{"sync", c.Sync, next.Sync},
+	} {
+		if !reflect.DeepEqual(section.current, section.next) {
+			restart = append(restart, section.key)
+		}
+	}
+
+	reloaded.CORS = next.CORS
+	reloaded.Logging = next.Logging
+	reloaded.Auth.TokensFile = next.Auth.TokensFile
+	reloaded.Auth.TenantsFile = next.Auth.TenantsFile
+
+	// The default tenant only exists if it was served from the start
+	if c.HasDefaultTenant() || !next.HasDefaultTenant() {
+		reloaded.Auth.Tokens = next.Auth.Tokens
+	} else {
+		restart = append(restart, "auth.tokens")
+	}
+
+	// Tokens of running tenants are replaced and removed tenants lose
+	// their tokens. New tenants and new data locations need a restart.
+	reloaded.Tenants = nil
+	tenantsChanged := false
+	for _, tenant := range c.Tenants {
+		i := slices.IndexFunc(next.Tenants, func(t TenantConfig) bool { return t.Name == tenant.Name })
+		if i < 0 {
+			continue
+		}
+		if next.Tenants[i].DataLocation != tenant.DataLocation || next.Tenants[i].TaskrcLocation != tenant.TaskrcLocation {
+			tenantsChanged = true
+		}
+		tenant.Tokens = next.Tenants[i].Tokens
+		reloaded.Tenants = append(reloaded.Tenants, tenant)
+	}
+	for _, tenant := range next.Tenants {
+		if !slices.ContainsFunc(c.Tenants, func(t TenantConfig) bool { return t.Name == tenant.Name }) {
+			tenantsChanged = true
+		}
+	}
+	if tenantsChanged {
+		restart = append(restart, "tenants")
+	}
+
+	if err := validateAuth(&reloaded); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return &reloaded, restart, nil
+}
@@ -0,0 +1,171 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+// Type identifies the kind of task change
+type Type string
+
+// Event type constants
+const (
+	TaskCreated   Type = "task.created"
+	TaskModified  Type = "task.modified"
+	TaskCompleted Type = "task.completed"
+	TaskDeleted   Type = "task.deleted"
+	TaskStarted   Type = "task.started"
+	TaskStopped   Type = "task.stopped"
+)
+
+// Types lists all known event types
+var Types = []Type{
+	TaskCreated,
+	TaskModified,
+	TaskCompleted,
+	TaskDeleted,
+	TaskStarted,
+	TaskStopped,
+}
+
+// IsValidType checks if t is a known event type
+func IsValidType(t Type) bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Source identifies where a change was observed
+type Source string
+
+// Event source constants
+const (
+	// SourceAPI marks changes made through this server
+	SourceAPI Source = "api"
+	// SourceExternal marks changes made by the task CLI, sync or other
+	// processes, detected by watching the data location
+	SourceExternal Source = "external"
+)
+
+// Event describes a change to a single task
+type Event struct {
+	ID       uint64            `json:"id"`
+	Type     Type              `json:"type"`
+	Source   Source            `json:"source"`
+	Time     time.Time         `json:"time"`
+	TaskUUID string            `json:"task_uuid"`
+	Before   *taskwarrior.Task `json:"before"`
+	After    *taskwarrior.Task `json:"after"`
+}
+
+// Bus fans out task change events to subscribers and keeps a bounded
+// history of recent events so that consumers can resume after a gap
+type Bus struct {
+	mu          sync.RWMutex
+	deliverMu   sync.Mutex
+	nextID      uint64
+	nextSub     int
+	subscribers map[int]func(Event)
+	history     []Event
+	historySize int
+}
+
+// NewBus creates a new event bus keeping the last historySize events.
+// Event IDs are seeded from the current time so that they keep increasing
+// across restarts.
+func NewBus(historySize int) *Bus {
+	return &Bus{
+		nextID:      uint64(time.Now().UnixMicro()),
+		subscribers: make(map[int]func(Event)),
+		historySize: historySize,
+	}
+}
+
+// Publish assigns an ID and timestamp to the event and delivers it to all
+// subscribers. Subscribers are called synchronously, in event order, and
+// must neither block nor publish events themselves.
+func (b *Bus) Publish(event Event) Event {
+	b.mu.Lock()
+	b.nextID++
+	event.ID = b.nextID
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if event.Source == "" {
+		event.Source = SourceAPI
+	}
+	if event.TaskUUID == "" {
+		if event.After != nil {
+			event.TaskUUID = event.After.UUID
+		} else if event.Before != nil {
+			event.TaskUUID = event.Before.UUID
+		}
+	}
+	if b.historySize > 0 {
+		if len(b.history) >= b.historySize {
+			b.history = append(b.history[:0], b.history[1:]...)
+		}
+		b.history = append(b.history, event)
+	}
+	subscribers := make([]func(Event), 0, len(b.subscribers))
+	for _, fn := range b.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	// Hand over to the delivery lock before releasing the state lock so
+	// that subscribers observe events in ID order
+	b.deliverMu.Lock()
+	defer b.deliverMu.Unlock()
+	b.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(event)
+	}
+
+	return event
+}
+
+// Subscribe registers fn for all future events and returns a function
+// that removes the subscription
+func (b *Bus) Subscribe(fn func(Event)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextSub
+	b.nextSub++
+	b.subscribers[id] = fn
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+// Since returns the retained events with an ID greater than id and the ID
+// of the latest event. The boolean is false when events after id have
+// already been dropped from the history, or when id is ahead of the
+// latest event, in which case the caller has missed changes and should
+// continue from latest.
+func (b *Bus) Since(id uint64) ([]Event, uint64, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if id >= b.nextID {
+		return nil, b.nextID, id == b.nextID
+	}
+
+	result := make([]Event, 0)
+	for _, event := range b.history {
+		if event.ID > id {
+			result = append(result, event)
+		}
+	}
+
+	complete := len(b.history) > 0 && b.history[0].ID <= id+1
+	return result, b.nextID, complete
+}
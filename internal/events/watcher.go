@@ -0,0 +1,228 @@
+package events
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+// ChangeWatcher publishes events for changes made outside the API, such
+// as by the task CLI or by sync. It keeps a snapshot of all tasks and
+// diffs it against a fresh export whenever the data files change.
+type ChangeWatcher struct {
+	client   *taskwarrior.Client
+	bus      *Bus
+	mu       sync.Mutex
+	snapshot map[string]taskwarrior.Task
+	// expected holds the tasks being changed through the API, and when,
+	// until the event for the change arrives
+	expected map[string]time.Time
+	unsub    func()
+}
+
+// expectTimeout is how long a task announced by Expect is left to its API
+// event. A change still unreported after it is published as external.
+const expectTimeout = time.Minute
+
+// NewChangeWatcher creates a change watcher publishing to bus
+func NewChangeWatcher(client *taskwarrior.Client, bus *Bus) *ChangeWatcher {
+	return &ChangeWatcher{
+		client:   client,
+		bus:      bus,
+		snapshot: make(map[string]taskwarrior.Task),
+		expected: make(map[string]time.Time),
+	}
+}
+
+// Start loads the initial snapshot and begins listening for file changes
+func (w *ChangeWatcher) Start(files *taskwarrior.FileWatcher) error {
+	tasks, err := w.client.Export()
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	for _, task := range tasks {
+		w.snapshot[task.UUID] = task
+	}
+	w.mu.Unlock()
+
+	// Keep the snapshot in step with API writes so they are not reported
+	// a second time as external changes
+	w.unsub = w.bus.Subscribe(w.track)
+
+	files.OnChange(w.refresh)
+	return nil
+}
+
+// Stop stops tracking API events
+func (w *ChangeWatcher) Stop() {
+	if w.unsub != nil {
+		w.unsub()
+	}
+}
+
+// Expect marks tasks as being changed through the API, so that refresh
+// leaves their changes to the events the API publishes. Clients making
+// those changes pass it to taskwarrior.Client.WithWriteObserver.
+func (w *ChangeWatcher) Expect(uuids []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	for _, uuid := range uuids {
+		w.expected[uuid] = now
+	}
+}
+
+func (w *ChangeWatcher) track(event Event) {
+	if event.Source != SourceAPI || event.After == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.snapshot[event.After.UUID] = *event.After
+	delete(w.expected, event.After.UUID)
+}
+
+// Counts returns the number of pending tasks and how many of them are
+// started
+func (w *ChangeWatcher) Counts() (pending, active int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, task := range w.snapshot {
+		if task.Status != taskwarrior.StatusPending {
+			continue
+		}
+		pending++
+		if task.Start != nil {
+			active++
+		}
+	}
+	return pending, active
+}
+
+// refresh exports all tasks and publishes an event for each difference
+func (w *ChangeWatcher) refresh() {
+	// Holding the write lock, every API write that shows in the export
+	// has already been announced to Expect
+	var changes []Event
+	err := w.client.Locked(func(locked *taskwarrior.Client) error {
+		tasks, err := locked.Export()
+		if err != nil {
+			return err
+		}
+		changes = w.diff(tasks)
+		return nil
+	})
+	if err != nil {
+		slog.Error("Failed to export tasks after data change", "error", err)
+		return
+	}
+
+	for _, event := range changes {
+		w.bus.Publish(event)
+	}
+}
+
+// diff replaces the snapshot with tasks and returns an event for each
+// difference, except for tasks whose API event is still to come
+func (w *ChangeWatcher) diff(tasks []taskwarrior.Task) []Event {
+	var changes []Event
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	for uuid, since := range w.expected {
+		if now.Sub(since) > expectTimeout {
+			delete(w.expected, uuid)
+		}
+	}
+
+	current := make(map[string]taskwarrior.Task, len(tasks))
+	for _, task := range tasks {
+		if _, ok := w.expected[task.UUID]; ok {
+			// track takes the task from the API event
+			if previous, existed := w.snapshot[task.UUID]; existed {
+				current[task.UUID] = previous
+			}
+			continue
+		}
+		current[task.UUID] = task
+
+		after := task
+		previous, existed := w.snapshot[task.UUID]
+		var before *taskwarrior.Task
+		if existed {
+			before = &previous
+		}
+
+		if eventType, changed := classifyChange(before, &after); changed {
+			changes = append(changes, Event{
+				Type:   eventType,
+				Source: SourceExternal,
+				Before: before,
+				After:  &after,
+			})
+		}
+	}
+
+	// Tasks that disappeared from the export were purged
+	for uuid, task := range w.snapshot {
+		if _, ok := current[uuid]; ok || task.Status == taskwarrior.StatusDeleted {
+			continue
+		}
+		if _, ok := w.expected[uuid]; ok {
+			continue
+		}
+		before := task
+		changes = append(changes, Event{
+			Type:   TaskDeleted,
+			Source: SourceExternal,
+			Before: &before,
+		})
+	}
+
+	w.snapshot = current
+	return changes
+}
+
+// classifyChange determines the event type for a task transition. The
+// boolean is false when the task has not changed.
+func classifyChange(before, after *taskwarrior.Task) (Type, bool) {
+	if before == nil {
+		if after.Status == taskwarrior.StatusDeleted {
+			return TaskDeleted, true
+		}
+		return TaskCreated, true
+	}
+
+	if sameTime(before.Modified, after.Modified) && before.Status == after.Status {
+		return "", false
+	}
+
+	switch {
+	case after.Status != before.Status && after.Status == taskwarrior.StatusCompleted:
+		return TaskCompleted, true
+	case after.Status != before.Status && after.Status == taskwarrior.StatusDeleted:
+		return TaskDeleted, true
+	case before.Start == nil && after.Start != nil:
+		return TaskStarted, true
+	case before.Start != nil && after.Start == nil && after.Status == taskwarrior.StatusPending:
+		return TaskStopped, true
+	default:
+		return TaskModified, true
+	}
+}
+
+func sameTime(a, b *taskwarrior.TaskwarriorTime) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Time.Equal(b.Time)
+}
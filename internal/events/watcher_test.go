@@ -0,0 +1,120 @@
+package events
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/tasktest"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+func TestMain(m *testing.M) {
+	tasktest.Main()
+	os.Exit(m.Run())
+}
+
+const taskUUID = "a360fc44-315c-4366-b70c-ea7e7520b749"
+
+// startWatcher returns a watcher over fake and the external events it
+// publishes. Refreshes are run by the tests.
+func startWatcher(t *testing.T, fake *tasktest.Fake) (*ChangeWatcher, *taskwarrior.Client, *[]Event) {
+	t.Helper()
+
+	client := taskwarrior.NewClient(fake.DataLocation(), "")
+	bus := NewBus(0)
+	watcher := NewChangeWatcher(client, bus)
+	if err := watcher.Start(taskwarrior.NewFileWatcher(fake.DataLocation(), time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(watcher.Stop)
+
+	var external []Event
+	bus.Subscribe(func(event Event) {
+		if event.Source == SourceExternal {
+			external = append(external, event)
+		}
+	})
+	return watcher, client, &external
+}
+
+func watcherFixture(t *testing.T) *tasktest.Fake {
+	t.Helper()
+
+	return tasktest.Install(t, []map[string]any{
+		{"uuid": taskUUID, "description": "Write docs", "status": "pending", "entry": "20260101T090000Z", "modified": "20260101T090000Z"},
+	})
+}
+
+// A refresh between an API write and its event does not report the write
+// as an external change
+func TestWatcherSkipsAPIWrites(t *testing.T) {
+	fake := watcherFixture(t)
+	watcher, client, external := startWatcher(t, fake)
+	api := client.WithWriteObserver(watcher.Expect)
+
+	project := "work"
+	if err := api.Modify(taskUUID, taskwarrior.TaskModify{Project: &project}); err != nil {
+		t.Fatal(err)
+	}
+	created, err := api.Add(taskwarrior.TaskCreate{Description: "Water plants"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	watcher.refresh()
+	if len(*external) != 0 {
+		t.Fatalf("API writes reported as external: %+v", *external)
+	}
+
+	for _, uuid := range []string{taskUUID, created} {
+		after, err := client.GetByUUID(uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		watcher.bus.Publish(Event{Type: TaskModified, After: after})
+	}
+	watcher.refresh()
+	if len(*external) != 0 {
+		t.Errorf("API writes reported as external after their events: %+v", *external)
+	}
+	if pending, _ := watcher.Counts(); pending != 2 {
+		t.Errorf("%d pending tasks in the snapshot, want 2", pending)
+	}
+}
+
+func TestWatcherReportsExternalWrites(t *testing.T) {
+	fake := watcherFixture(t)
+	watcher, client, external := startWatcher(t, fake)
+
+	if err := client.Done(taskUUID); err != nil {
+		t.Fatal(err)
+	}
+	watcher.refresh()
+	if len(*external) != 1 || (*external)[0].Type != TaskCompleted {
+		t.Errorf("got %+v, want one task.completed", *external)
+	}
+}
+
+// An API write whose event never arrives is reported as external once it
+// is no longer expected
+func TestWatcherExpectTimeout(t *testing.T) {
+	fake := watcherFixture(t)
+	watcher, client, external := startWatcher(t, fake)
+
+	if err := client.WithWriteObserver(watcher.Expect).Start(taskUUID); err != nil {
+		t.Fatal(err)
+	}
+	watcher.refresh()
+	if len(*external) != 0 {
+		t.Fatalf("expected write reported: %+v", *external)
+	}
+
+	watcher.mu.Lock()
+	watcher.expected[taskUUID] = time.Now().Add(-2 * expectTimeout)
+	watcher.mu.Unlock()
+	watcher.refresh()
+	if len(*external) != 1 || (*external)[0].Type != TaskStarted {
+		t.Errorf("got %+v, want one task.started", *external)
+	}
+}
@@ -0,0 +1,79 @@
+package exporters
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+// csvHeader names the CSV columns. The names match the query parameters
+// of the CSV import, so an export can be imported again.
+var csvHeader = []string{
+	"uuid", "id", "description", "status", "project", "tags", "priority",
+	"due", "wait", "scheduled", "entry", "end", "urgency",
+}
+
+// CSVWriter writes one row per task after a header row. Tags are
+// separated by commas and dates are in RFC 3339.
+type CSVWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+// NewCSVWriter creates a CSV writer
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *CSVWriter) writeHeader() error {
+	if cw.header {
+		return nil
+	}
+	cw.header = true
+	return cw.w.Write(csvHeader)
+}
+
+// WriteTask implements Writer
+func (cw *CSVWriter) WriteTask(task taskwarrior.Task) error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+
+	id := ""
+	if task.ID != 0 {
+		id = strconv.Itoa(task.ID)
+	}
+	err := cw.w.Write([]string{
+		task.UUID,
+		id,
+		task.Description,
+		task.Status,
+		task.Project,
+		strings.Join(task.Tags, ","),
+		task.Priority,
+		timestamp(task.Due),
+		timestamp(task.Wait),
+		timestamp(task.Scheduled),
+		timestamp(task.Entry),
+		timestamp(task.End),
+		strconv.FormatFloat(task.Urgency, 'f', -1, 64),
+	})
+	if err != nil {
+		return err
+	}
+	// Flush each row so the response streams
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// Close implements Writer
+func (cw *CSVWriter) Close() error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
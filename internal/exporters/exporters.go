@@ -0,0 +1,114 @@
+// Package exporters writes tasks in formats other tools read, such as CSV,
+// Markdown, todo.txt, org-mode and iCalendar. Writers receive one task at
+// a time, so exports are streamed rather than built in memory.
+package exporters
+
+import (
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/caldav"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+// Writer writes tasks in one format
+type Writer interface {
+	// WriteTask writes the next task
+	WriteTask(task taskwarrior.Task) error
+	// Close writes whatever follows the last task. It does not close the
+	// underlying writer.
+	Close() error
+}
+
+// dateLayout is how the line-based formats write dates
+const dateLayout = "2006-01-02"
+
+// date formats t as a day, or returns "" if t is unset
+func date(t *taskwarrior.TaskwarriorTime) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(dateLayout)
+}
+
+// timestamp formats t in RFC 3339, or returns "" if t is unset
+func timestamp(t *taskwarrior.TaskwarriorTime) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// singleLine replaces line breaks, which would end the task early in the
+// line-based formats
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// letterPriority maps Taskwarrior's H/M/L to the A/B/C used by todo.txt
+// and org-mode
+func letterPriority(priority string) string {
+	switch priority {
+	case "H":
+		return "A"
+	case "M":
+		return "B"
+	case "L":
+		return "C"
+	}
+	return ""
+}
+
+// isDone reports whether a task is no longer open. Formats without a
+// deleted state write deleted tasks as done.
+func isDone(task taskwarrior.Task) bool {
+	return task.Status == taskwarrior.StatusCompleted || task.Status == taskwarrior.StatusDeleted
+}
+
+// Format describes an export format
+type Format struct {
+	Name        string
+	ContentType string
+	// Extension is the file name extension of downloads
+	Extension string
+	New       func(w io.Writer) Writer
+}
+
+// Formats lists the export formats. JSON comes first and is the default.
+var Formats = []Format{
+	{Name: "json", ContentType: "application/json", Extension: "json",
+		New: func(w io.Writer) Writer { return NewJSONWriter(w) }},
+	{Name: "ndjson", ContentType: "application/x-ndjson", Extension: "ndjson",
+		New: func(w io.Writer) Writer { return NewNDJSONWriter(w) }},
+	{Name: "csv", ContentType: "text/csv", Extension: "csv",
+		New: func(w io.Writer) Writer { return NewCSVWriter(w) }},
+	{Name: "markdown", ContentType: "text/markdown", Extension: "md",
+		New: func(w io.Writer) Writer { return NewMarkdownWriter(w) }},
+	{Name: "todotxt", ContentType: "text/plain", Extension: "txt",
+		New: func(w io.Writer) Writer { return NewTodoTxtWriter(w) }},
+	{Name: "org", ContentType: "text/org", Extension: "org",
+		New: func(w io.Writer) Writer { return NewOrgWriter(w) }},
+	{Name: "ics", ContentType: "text/calendar", Extension: "ics",
+		New: func(w io.Writer) Writer { return caldav.NewCalendarWriter(w) }},
+}
+
+// FormatByName returns the format called name
+func FormatByName(name string) (Format, bool) {
+	for _, f := range Formats {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// FormatByContentType returns the format served as contentType
+func FormatByContentType(contentType string) (Format, bool) {
+	for _, f := range Formats {
+		if f.ContentType == contentType {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
@@ -0,0 +1,215 @@
+package exporters
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func at(value string) *taskwarrior.TaskwarriorTime {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return &taskwarrior.TaskwarriorTime{Time: t}
+}
+
+// goldenTasks is the fixed task set every format is exported from. It
+// covers tags, annotations, unicode, values that need CSV quoting and a
+// description long enough to be folded in iCalendar.
+func goldenTasks() []taskwarrior.Task {
+	return []taskwarrior.Task{
+		{
+			ID:          1,
+			UUID:        "a360fc44-315c-4366-b70c-ea7e7520b749",
+			Description: `Write docs, then review the "draft"`,
+			Status:      taskwarrior.StatusPending,
+			Project:     "work.docs",
+			Tags:        []string{"docs", "writing"},
+			Priority:    taskwarrior.PriorityHigh,
+			Entry:       at("2026-01-01T09:00:00Z"),
+			Modified:    at("2026-01-03T10:30:00Z"),
+			Due:         at("2026-02-01T17:00:00Z"),
+			Urgency:     12.5,
+			Annotations: []taskwarrior.Annotation{
+				{Entry: *at("2026-01-02T08:00:00Z"), Description: "Outline agreed"},
+				{Entry: *at("2026-01-03T10:30:00Z"), Description: "Ask Sam, Kim; and Alex"},
+			},
+		},
+		{
+			UUID:        "0f6b2c1e-9d4a-4c3b-8e2f-7a1b5c9d3e4f",
+			Description: "Release 1.0",
+			Status:      taskwarrior.StatusCompleted,
+			Project:     "work",
+			Tags:        []string{"release"},
+			Priority:    taskwarrior.PriorityMedium,
+			Entry:       at("2026-01-01T09:00:00Z"),
+			Modified:    at("2026-01-05T16:00:00Z"),
+			End:         at("2026-01-05T16:00:00Z"),
+		},
+		{
+			ID:          2,
+			UUID:        "5e7d9c3a-2b1f-4e8d-a6c4-3f2e1d0c9b8a",
+			Description: "Café mit Jürgen 🎉 — 東京の資料を準備して、会議室を予約する",
+			Status:      taskwarrior.StatusPending,
+			Project:     "privat",
+			Tags:        []string{"ünïcode", "旅行"},
+			Priority:    taskwarrior.PriorityLow,
+			Entry:       at("2026-01-02T12:00:00Z"),
+			Modified:    at("2026-01-02T12:00:00Z"),
+			Scheduled:   at("2026-01-10T08:00:00Z"),
+			Urgency:     3.2,
+			Annotations: []taskwarrior.Annotation{
+				{Entry: *at("2026-01-02T12:05:00Z"), Description: "Zug um 8:15 — Gleis 7"},
+			},
+		},
+		{
+			UUID:        "c2d4e6f8-1a3b-4c5d-9e7f-0a2b4c6d8e0f",
+			Description: "Line one\nLine two; with a semicolon",
+			Status:      taskwarrior.StatusDeleted,
+			Entry:       at("2026-01-01T09:00:00Z"),
+			Modified:    at("2026-01-04T09:00:00Z"),
+			End:         at("2026-01-04T09:00:00Z"),
+		},
+		{
+			ID:          3,
+			UUID:        "9b8a7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d",
+			Description: "Prepare the quarterly planning meeting with every team lead and the Größenordnung of the budget",
+			Status:      taskwarrior.StatusPending,
+			Project:     "work",
+			Tags:        []string{"meeting"},
+			Entry:       at("2026-01-02T09:00:00Z"),
+			Modified:    at("2026-01-06T11:00:00Z"),
+			Start:       at("2026-01-06T11:00:00Z"),
+			Wait:        at("2026-01-08T00:00:00Z"),
+			Depends:     []string{"a360fc44-315c-4366-b70c-ea7e7520b749"},
+			Urgency:     7,
+		},
+	}
+}
+
+// export writes tasks in format
+func export(t *testing.T, format Format, tasks []taskwarrior.Task) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := format.New(&buf)
+	for _, task := range tasks {
+		if err := w.WriteTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// checkGolden compares got with testdata/<name>.golden, or rewrites the
+// file when the tests run with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test ./internal/exporters -update to create it", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s; run go test ./internal/exporters -update if the change is intended\ngot:\n%s\nwant:\n%s",
+			path, got, want)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(format.Name, func(t *testing.T) {
+			checkGolden(t, format.Name, export(t, format, goldenTasks()))
+		})
+	}
+}
+
+func TestGoldenEmpty(t *testing.T) {
+	for _, format := range Formats {
+		t.Run(format.Name, func(t *testing.T) {
+			checkGolden(t, format.Name+"-empty", export(t, format, nil))
+		})
+	}
+}
+
+// The CSV export must read back as the values it was written from, even
+// with commas, quotes and line breaks in them
+func TestCSVQuoting(t *testing.T) {
+	format, _ := FormatByName("csv")
+	tasks := goldenTasks()
+
+	rows, err := csv.NewReader(bytes.NewReader(export(t, format, tasks))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(tasks)+1 {
+		t.Fatalf("got %d rows, want a header and %d tasks", len(rows), len(tasks))
+	}
+	for i, task := range tasks {
+		row := rows[i+1]
+		if row[2] != task.Description {
+			t.Errorf("description %q read back as %q", task.Description, row[2])
+		}
+		if tags := strings.Join(task.Tags, ","); row[5] != tags {
+			t.Errorf("tags %q read back as %q", tags, row[5])
+		}
+	}
+}
+
+// iCalendar lines must be folded at 75 octets without splitting UTF-8
+// sequences, and unfold to the original line
+func TestICSLineFolding(t *testing.T) {
+	format, _ := FormatByName("ics")
+	out := string(export(t, format, goldenTasks()))
+
+	if !strings.HasSuffix(out, "\r\n") {
+		t.Error("output does not end with CRLF")
+	}
+	folded := false
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line splits a UTF-8 sequence: %q", line)
+		}
+		if strings.HasPrefix(line, " ") {
+			folded = true
+		}
+	}
+	if !folded {
+		t.Fatal("no line was folded, so folding is not tested")
+	}
+
+	unfolded := strings.ReplaceAll(out, "\r\n ", "")
+	for _, task := range []taskwarrior.Task{goldenTasks()[2], goldenTasks()[4]} {
+		if !strings.Contains(unfolded, "SUMMARY:"+task.Description+"\r\n") {
+			t.Errorf("the folded summary does not unfold to %q", task.Description)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package exporters
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+// JSONWriter writes the {"tasks": [...], "count": n} object returned by
+// the list endpoints
+type JSONWriter struct {
+	w     io.Writer
+	count int
+}
+
+// NewJSONWriter creates a JSON writer
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{w: w}
+}
+
+// exportTask is a task with its times written in full, in Taskwarrior's
+// format, rather than as the dates the other endpoints return. That keeps
+// the time of day and lets task import read the export back.
+type exportTask struct {
+	taskwarrior.Task
+	Entry       *exportTime        `json:"entry,omitempty"`
+	Modified    *exportTime        `json:"modified,omitempty"`
+	Start       *exportTime        `json:"start,omitempty"`
+	End         *exportTime        `json:"end,omitempty"`
+	Due         *exportTime        `json:"due,omitempty"`
+	Until       *exportTime        `json:"until,omitempty"`
+	Wait        *exportTime        `json:"wait,omitempty"`
+	Scheduled   *exportTime        `json:"scheduled,omitempty"`
+	Annotations []exportAnnotation `json:"annotations,omitempty"`
+}
+
+type exportAnnotation struct {
+	Entry       *exportTime `json:"entry,omitempty"`
+	Description string      `json:"description"`
+}
+
+// exportTime is a time written as 20260101T131042Z
+type exportTime time.Time
+
+// MarshalJSON implements json.Marshaler
+func (t exportTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Time(t).UTC().Format(taskwarrior.TimeLayout))
+}
+
+func fullTime(t *taskwarrior.TaskwarriorTime) *exportTime {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	full := exportTime(t.Time)
+	return &full
+}
+
+func newExportTask(task taskwarrior.Task) exportTask {
+	et := exportTask{
+		Task:      task,
+		Entry:     fullTime(task.Entry),
+		Modified:  fullTime(task.Modified),
+		Start:     fullTime(task.Start),
+		End:       fullTime(task.End),
+		Due:       fullTime(task.Due),
+		Until:     fullTime(task.Until),
+		Wait:      fullTime(task.Wait),
+		Scheduled: fullTime(task.Scheduled),
+	}
+	for _, annotation := range task.Annotations {
+		et.Annotations = append(et.Annotations, exportAnnotation{
+			Entry:       fullTime(&annotation.Entry),
+			Description: annotation.Description,
+		})
+	}
+	return et
+}
+
+// WriteTask implements Writer
+func (jw *JSONWriter) WriteTask(task taskwarrior.Task) error {
+	data, err := json.Marshal(newExportTask(task))
+	if err != nil {
+		return err
+	}
+	sep := ","
+	if jw.count == 0 {
+		sep = `{"tasks":[`
+	}
+	if _, err := io.WriteString(jw.w, sep); err != nil {
+		return err
+	}
+	if _, err := jw.w.Write(data); err != nil {
+		return err
+	}
+	jw.count++
+	return nil
+}
+
+// Close implements Writer
+func (jw *JSONWriter) Close() error {
+	if jw.count == 0 {
+		_, err := io.WriteString(jw.w, `{"tasks":[],"count":0}`+"\n")
+		return err
+	}
+	_, err := fmt.Fprintf(jw.w, "],\"count\":%d}\n", jw.count)
+	return err
+}
+
+// NDJSONWriter writes one JSON task per line
+type NDJSONWriter struct {
+	enc *json.Encoder
+}
+
+// NewNDJSONWriter creates an NDJSON writer
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &NDJSONWriter{enc: enc}
+}
+
+// WriteTask implements Writer
+func (nw *NDJSONWriter) WriteTask(task taskwarrior.Task) error {
+	return nw.enc.Encode(newExportTask(task))
+}
+
+// Close implements Writer
+func (nw *NDJSONWriter) Close() error {
+	return nil
+}
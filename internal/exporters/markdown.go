@@ -0,0 +1,73 @@
+package exporters
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+// markdownHeader is the table header and delimiter row
+const markdownHeader = "| | Description | Project | Tags | Priority | Due | UUID |\n" +
+	"|---|---|---|---|---|---|---|\n"
+
+// MarkdownWriter writes a GitHub-flavored Markdown table with a checkbox
+// column that is ticked for done tasks
+type MarkdownWriter struct {
+	w      io.Writer
+	header bool
+}
+
+// NewMarkdownWriter creates a Markdown writer
+func NewMarkdownWriter(w io.Writer) *MarkdownWriter {
+	return &MarkdownWriter{w: w}
+}
+
+func (mw *MarkdownWriter) writeHeader() error {
+	if mw.header {
+		return nil
+	}
+	mw.header = true
+	_, err := io.WriteString(mw.w, markdownHeader)
+	return err
+}
+
+// WriteTask implements Writer
+func (mw *MarkdownWriter) WriteTask(task taskwarrior.Task) error {
+	if err := mw.writeHeader(); err != nil {
+		return err
+	}
+
+	check := "[ ]"
+	if isDone(task) {
+		check = "[x]"
+	}
+	tags := make([]string, len(task.Tags))
+	for i, tag := range task.Tags {
+		tags[i] = "`" + tag + "`"
+	}
+
+	_, err := fmt.Fprintf(mw.w, "| %s | %s | %s | %s | %s | %s | `%s` |\n",
+		check,
+		markdownCell(task.Description),
+		markdownCell(task.Project),
+		markdownCell(strings.Join(tags, " ")),
+		task.Priority,
+		date(task.Due),
+		task.UUID,
+	)
+	return err
+}
+
+// Close implements Writer
+func (mw *MarkdownWriter) Close() error {
+	return mw.writeHeader()
+}
+
+// markdownCell escapes text that would end or break a table cell
+func markdownCell(s string) string {
+	s = singleLine(s)
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, "|", `\|`)
+}
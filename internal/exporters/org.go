@@ -0,0 +1,98 @@
+package exporters
+
+import (
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+// OrgWriter writes one org-mode heading per task. Open tasks are TODO
+// and done tasks DONE; priorities become [#A] to [#C], tags become
+// heading tags, and the due, scheduled and end dates go on the planning
+// line as DEADLINE, SCHEDULED and CLOSED. The UUID, project and status
+// are kept in a property drawer and annotations follow as list items.
+type OrgWriter struct {
+	w io.Writer
+}
+
+// NewOrgWriter creates an org-mode writer
+func NewOrgWriter(w io.Writer) *OrgWriter {
+	return &OrgWriter{w: w}
+}
+
+const (
+	orgDate      = "2006-01-02 Mon"
+	orgTimestamp = "2006-01-02 Mon 15:04"
+)
+
+// WriteTask implements Writer
+func (ow *OrgWriter) WriteTask(task taskwarrior.Task) error {
+	var b strings.Builder
+
+	keyword := "TODO"
+	if isDone(task) {
+		keyword = "DONE"
+	}
+	b.WriteString("* " + keyword + " ")
+	if priority := letterPriority(task.Priority); priority != "" {
+		b.WriteString("[#" + priority + "] ")
+	}
+	b.WriteString(singleLine(task.Description))
+	if len(task.Tags) > 0 {
+		tags := make([]string, len(task.Tags))
+		for i, tag := range task.Tags {
+			tags[i] = orgTag(tag)
+		}
+		b.WriteString(" :" + strings.Join(tags, ":") + ":")
+	}
+	b.WriteString("\n")
+
+	var planning []string
+	if task.End != nil && !task.End.IsZero() && isDone(task) {
+		planning = append(planning, "CLOSED: ["+task.End.UTC().Format(orgTimestamp)+"]")
+	}
+	if task.Due != nil && !task.Due.IsZero() {
+		planning = append(planning, "DEADLINE: <"+task.Due.UTC().Format(orgDate)+">")
+	}
+	if task.Scheduled != nil && !task.Scheduled.IsZero() {
+		planning = append(planning, "SCHEDULED: <"+task.Scheduled.UTC().Format(orgDate)+">")
+	}
+	if len(planning) > 0 {
+		b.WriteString("  " + strings.Join(planning, " ") + "\n")
+	}
+
+	b.WriteString("  :PROPERTIES:\n")
+	b.WriteString("  :ID: " + task.UUID + "\n")
+	if task.Project != "" {
+		b.WriteString("  :PROJECT: " + task.Project + "\n")
+	}
+	b.WriteString("  :STATUS: " + task.Status + "\n")
+	if task.Entry != nil && !task.Entry.IsZero() {
+		b.WriteString("  :CREATED: [" + task.Entry.UTC().Format(orgTimestamp) + "]\n")
+	}
+	b.WriteString("  :END:\n")
+
+	for _, annotation := range task.Annotations {
+		b.WriteString("  - " + singleLine(annotation.Description) + "\n")
+	}
+
+	_, err := io.WriteString(ow.w, b.String())
+	return err
+}
+
+// Close implements Writer
+func (ow *OrgWriter) Close() error {
+	return nil
+}
+
+// orgTag replaces characters org-mode does not allow in tags
+func orgTag(tag string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_@#%", r) {
+			return r
+		}
+		return '_'
+	}, tag)
+}
@@ -0,0 +1,75 @@
+package exporters
+
+import (
+	"io"
+	"strings"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+// TodoTxtWriter writes one todo.txt line per task, in the form read by
+// the todo.txt import: priorities H, M and L become (A), (B) and (C),
+// the project becomes +project, tags become @contexts, and due and wait
+// dates become due: and t:. Done tasks keep their priority as pri:.
+type TodoTxtWriter struct {
+	w io.Writer
+}
+
+// NewTodoTxtWriter creates a todo.txt writer
+func NewTodoTxtWriter(w io.Writer) *TodoTxtWriter {
+	return &TodoTxtWriter{w: w}
+}
+
+// WriteTask implements Writer
+func (tw *TodoTxtWriter) WriteTask(task taskwarrior.Task) error {
+	var parts []string
+	priority := letterPriority(task.Priority)
+
+	if isDone(task) {
+		parts = append(parts, "x")
+		// The completion date is only allowed before a creation date
+		if end := date(task.End); end != "" {
+			parts = append(parts, end)
+			if entry := date(task.Entry); entry != "" {
+				parts = append(parts, entry)
+			}
+		}
+	} else {
+		if priority != "" {
+			parts = append(parts, "("+priority+")")
+		}
+		if entry := date(task.Entry); entry != "" {
+			parts = append(parts, entry)
+		}
+	}
+
+	parts = append(parts, singleLine(task.Description))
+	if task.Project != "" {
+		parts = append(parts, "+"+todoTxtWord(task.Project))
+	}
+	for _, tag := range task.Tags {
+		parts = append(parts, "@"+todoTxtWord(tag))
+	}
+	if due := date(task.Due); due != "" {
+		parts = append(parts, "due:"+due)
+	}
+	if wait := date(task.Wait); wait != "" {
+		parts = append(parts, "t:"+wait)
+	}
+	if isDone(task) && priority != "" {
+		parts = append(parts, "pri:"+priority)
+	}
+
+	_, err := io.WriteString(tw.w, strings.Join(parts, " ")+"\n")
+	return err
+}
+
+// Close implements Writer
+func (tw *TodoTxtWriter) Close() error {
+	return nil
+}
+
+// todoTxtWord replaces spaces, which would split a project or context
+func todoTxtWord(s string) string {
+	return strings.Join(strings.Fields(s), "_")
+}
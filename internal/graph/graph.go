@@ -0,0 +1,68 @@
+// Package graph serves the task data as a GraphQL API. Resolvers use the
+// same taskwarrior.Client as the REST handlers and share a per-request
+// loader, so a nested query runs as few task processes as possible.
+package graph
+
+import (
+	"context"
+	_ "embed"
+
+	"github.com/dotbinio/taskwarrior-api/internal/auth"
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// maxDepth limits how deeply queries may nest, so a query cannot follow
+// dependencies indefinitely
+const maxDepth = 10
+
+// NewSchema parses the GraphQL schema
+func NewSchema() *graphql.Schema {
+	return graphql.MustParseSchema(schema, &Resolver{},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(maxDepth),
+	)
+}
+
+// Session is the state of one GraphQL request
+type Session struct {
+	Client      *taskwarrior.Client
+	Restriction taskwarrior.Restriction
+	Scope       auth.Scope
+	// Publish reports a task change made by a mutation
+	Publish func(eventType events.Type, uuid string, before, after *taskwarrior.Task)
+
+	loader *loader
+}
+
+type sessionKey struct{}
+
+// WithSession returns a context carrying s for the resolvers
+func WithSession(ctx context.Context, s *Session) context.Context {
+	s.loader = newLoader(s.Client, s.Restriction)
+	return context.WithValue(ctx, sessionKey{}, s)
+}
+
+func sessionFrom(ctx context.Context) *Session {
+	return ctx.Value(sessionKey{}).(*Session)
+}
+
+// Error is a resolver error carrying one of the API's error codes in its
+// extensions
+type Error struct {
+	Message string
+	Code    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Extensions implements the graphql-go interface for error extensions
+func (e *Error) Extensions() map[string]any {
+	return map[string]any{"code": e.Code}
+}
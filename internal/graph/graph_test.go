@@ -0,0 +1,371 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dotbinio/taskwarrior-api/internal/auth"
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/tasktest"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+func TestMain(m *testing.M) {
+	tasktest.Main()
+	os.Exit(m.Run())
+}
+
+const (
+	docsUUID    = "a360fc44-315c-4366-b70c-ea7e7520b749"
+	releaseUUID = "0f6b2c1e-9d4a-4c3b-8e2f-7a1b5c9d3e4f"
+	plantsUUID  = "5e7d9c3a-2b1f-4e8d-a6c4-3f2e1d0c9b8a"
+	notesUUID   = "c2d4e6f8-1a3b-4c5d-9e7f-0a2b4c6d8e0f"
+	reviewUUID  = "9b8a7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d"
+	budgetUUID  = "3f2e1d0c-9b8a-4c6d-8e7f-6a5b4c3d2e1f"
+	unknownUUID = "11111111-2222-4333-8444-555555555555"
+)
+
+// fixtureTasks has pending tasks in two projects whose dependencies are
+// completed, so they are not part of the pending export
+func fixtureTasks() []map[string]any {
+	task := func(uuid, description, status, project string, tags []string, depends ...string) map[string]any {
+		t := map[string]any{
+			"uuid": uuid, "description": description, "status": status,
+			"entry": "20260101T090000Z", "modified": "20260101T090000Z",
+		}
+		if project != "" {
+			t["project"] = project
+		}
+		if tags != nil {
+			t["tags"] = tags
+		}
+		if depends != nil {
+			t["depends"] = depends
+		}
+		return t
+	}
+	return []map[string]any{
+		task(docsUUID, "Write docs", "pending", "work", []string{"docs"}, notesUUID, reviewUUID),
+		task(releaseUUID, "Release 1.0", "pending", "work.release", []string{"release"}, reviewUUID, budgetUUID),
+		task(plantsUUID, "Water plants", "pending", "home", []string{"docs"}),
+		task(notesUUID, "Gather notes", "completed", "work", nil),
+		task(reviewUUID, "Review draft", "completed", "work", nil),
+		task(budgetUUID, "Approve budget", "completed", "finance", nil),
+	}
+}
+
+type testSession struct {
+	*Session
+	fake      *tasktest.Fake
+	published []events.Type
+}
+
+func newSession(t *testing.T, restriction taskwarrior.Restriction) *testSession {
+	t.Helper()
+
+	fake := tasktest.Install(t, fixtureTasks())
+	ts := &testSession{fake: fake}
+	ts.Session = &Session{
+		Client:      taskwarrior.NewClient(fake.DataLocation(), ""),
+		Restriction: restriction,
+		Scope:       auth.ScopeWrite,
+		Publish: func(eventType events.Type, uuid string, before, after *taskwarrior.Task) {
+			ts.published = append(ts.published, eventType)
+		},
+	}
+	return ts
+}
+
+// exec runs query in a fresh request and returns its data and error codes
+func (ts *testSession) exec(t *testing.T, query string) (map[string]any, []string) {
+	t.Helper()
+
+	ctx := WithSession(context.Background(), ts.Session)
+	resp := NewSchema().Exec(ctx, query, "", nil)
+
+	var codes []string
+	for _, err := range resp.Errors {
+		code, _ := err.Extensions["code"].(string)
+		if code == "" {
+			t.Fatalf("error without a code: %v", err)
+		}
+		codes = append(codes, code)
+	}
+
+	var data map[string]any
+	if len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, &data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return data, codes
+}
+
+func descriptions(list any) []string {
+	var result []string
+	for _, item := range list.([]any) {
+		result = append(result, item.(map[string]any)["description"].(string))
+	}
+	slices.Sort(result)
+	return result
+}
+
+func TestTasksFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{"+docs", []string{"Water plants", "Write docs"}},
+		{"project:work and +release", []string{"Release 1.0"}},
+		{"(+docs or +release) and project.not:home", []string{"Release 1.0", "Write docs"}},
+		{"+docs xor project:work", []string{"Release 1.0", "Water plants"}},
+		{docsUUID + " " + plantsUUID, []string{"Water plants", "Write docs"}},
+		{"", []string{"Release 1.0", "Water plants", "Write docs"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			ts := newSession(t, taskwarrior.Restriction{})
+			data, codes := ts.exec(t, `{ tasks(filter: `+jsonString(tt.filter)+`) { description } }`)
+			if codes != nil {
+				t.Fatal(codes)
+			}
+			if got := descriptions(data["tasks"]); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// The filter is wrapped in parentheses so that an or in it cannot reach
+// past the token's restriction
+func TestTasksFilterWithinRestriction(t *testing.T) {
+	ts := newSession(t, taskwarrior.Restriction{Project: "work"})
+
+	data, codes := ts.exec(t, `{ tasks(filter: "+release or +docs") { description } }`)
+	if codes != nil {
+		t.Fatal(codes)
+	}
+	if got, want := descriptions(data["tasks"]), []string{"Release 1.0", "Write docs"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	calls := ts.fake.CallsOf("export")
+	want := []string{"status:pending", "(", "+release", "or", "+docs", ")", "project:work", "export"}
+	if len(calls) != 1 || !slices.Equal(calls[0], want) {
+		t.Errorf("ran %q, want %q", calls, want)
+	}
+}
+
+func TestTasksFilterRejected(t *testing.T) {
+	for _, filter := range []string{
+		"rc.data.location=/tmp",
+		"rc:/tmp/taskrc",
+		"+docs rc.hooks=on",
+		"+docs delete",
+		"project:work) or (project:home",
+		"write docs",
+	} {
+		t.Run(filter, func(t *testing.T) {
+			ts := newSession(t, taskwarrior.Restriction{})
+			_, codes := ts.exec(t, `{ tasks(filter: `+jsonString(filter)+`) { uuid } }`)
+			if !slices.Equal(codes, []string{"INVALID_FILTER"}) {
+				t.Errorf("got %v, want INVALID_FILTER", codes)
+			}
+			if calls := ts.fake.Calls(); len(calls) != 0 {
+				t.Errorf("task ran for a rejected filter: %q", calls)
+			}
+		})
+	}
+}
+
+// Arguments that end up on the command line cannot carry settings, such
+// as another tenant's data location
+func TestTasksArgumentsRejected(t *testing.T) {
+	tests := []struct {
+		args string
+		want string
+	}{
+		{`report: "rc.data.location=/other/tenant"`, "INVALID_REPORT"},
+		{`report: "undefined"`, "INVALID_REPORT"},
+		{`status: "pending rc.data.location=/other/tenant"`, "INVALID_FILTER"},
+		{`project: "work) or (project:home"`, "INVALID_FILTER"},
+		{`tags: ["docs", "x rc:/other/taskrc"]`, "INVALID_FILTER"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			ts := newSession(t, taskwarrior.Restriction{})
+			ts.fake.SetShow("report.next.filter=status:pending\n")
+
+			_, codes := ts.exec(t, `{ tasks(`+tt.args+`) { uuid } }`)
+			if !slices.Equal(codes, []string{tt.want}) {
+				t.Errorf("got %v, want %s", codes, tt.want)
+			}
+			if calls := ts.fake.CallsOf("export"); len(calls) != 0 {
+				t.Errorf("exported: %q", calls)
+			}
+		})
+	}
+}
+
+func TestTasksReport(t *testing.T) {
+	ts := newSession(t, taskwarrior.Restriction{})
+	ts.fake.SetShow("report.next.filter=status:pending\n")
+
+	data, codes := ts.exec(t, `{ tasks(report: "next") { description } }`)
+	if codes != nil {
+		t.Fatal(codes)
+	}
+	if n := len(data["tasks"].([]any)); n != 6 {
+		t.Errorf("got %d tasks, want every task the fake exports", n)
+	}
+}
+
+// Dependencies of every task in a list are fetched with one export,
+// however many tasks share them
+func TestDependenciesBatched(t *testing.T) {
+	ts := newSession(t, taskwarrior.Restriction{})
+
+	data, codes := ts.exec(t, `{ tasks { description dependencies { description } } }`)
+	if codes != nil {
+		t.Fatal(codes)
+	}
+	deps := map[string][]string{}
+	for _, task := range data["tasks"].([]any) {
+		task := task.(map[string]any)
+		deps[task["description"].(string)] = descriptions(task["dependencies"])
+	}
+	if got := deps["Write docs"]; !slices.Equal(got, []string{"Gather notes", "Review draft"}) {
+		t.Errorf("dependencies of Write docs = %q", got)
+	}
+	if got := deps["Release 1.0"]; !slices.Equal(got, []string{"Approve budget", "Review draft"}) {
+		t.Errorf("dependencies of Release 1.0 = %q", got)
+	}
+
+	calls := ts.fake.CallsOf("export")
+	if len(calls) != 2 {
+		t.Fatalf("ran %d exports, want the list and one batch: %q", len(calls), calls)
+	}
+	want := []string{budgetUUID, notesUUID, reviewUUID, "export"}
+	slices.Sort(want[:3])
+	if !slices.Equal(calls[1], want) {
+		t.Errorf("batch ran %q, want %q", calls[1], want)
+	}
+}
+
+// A dependency outside the restriction is left out, not fetched twice
+func TestDependenciesWithinRestriction(t *testing.T) {
+	ts := newSession(t, taskwarrior.Restriction{Project: "work"})
+
+	data, codes := ts.exec(t, `{ task(uuid: "`+releaseUUID+`") { dependencies { description } } }`)
+	if codes != nil {
+		t.Fatal(codes)
+	}
+	deps := descriptions(data["task"].(map[string]any)["dependencies"])
+	if !slices.Equal(deps, []string{"Review draft"}) {
+		t.Errorf("dependencies = %q, want only the one in work", deps)
+	}
+	if calls := ts.fake.CallsOf("export"); len(calls) != 2 {
+		t.Errorf("ran %d exports, want the task and one batch: %q", len(calls), calls)
+	}
+}
+
+func TestExportsShared(t *testing.T) {
+	ts := newSession(t, taskwarrior.Restriction{})
+	ts.fake.SetShow(strings.Join([]string{
+		"context.home.read=project:home",
+		"context.home.write=project:home",
+		"report.next.description=Most urgent tasks",
+		"report.next.filter=status:pending",
+		"report.next.columns=id,description",
+		"report.next.labels=ID,Description",
+		"report.next.sort=urgency-",
+		"",
+	}, "\n"))
+
+	data, codes := ts.exec(t, `{
+		projects { name count tasks { uuid } }
+		work: project(name: "work") { tasks { uuid } }
+		tags { name count }
+		reports { name }
+		contexts { name }
+		a: tasks { uuid }
+		b: tasks { uuid }
+	}`)
+	if codes != nil {
+		t.Fatal(codes)
+	}
+	if n := len(data["projects"].([]any)); n != 3 {
+		t.Errorf("got %d projects, want 3", n)
+	}
+	if n := len(data["contexts"].([]any)); n != 1 {
+		t.Errorf("got %d contexts, want 1", n)
+	}
+
+	if calls := ts.fake.CallsOf("export"); len(calls) != 1 {
+		t.Errorf("ran %d exports, want one of the pending tasks: %q", len(calls), calls)
+	}
+	if calls := ts.fake.CallsOf("_show"); len(calls) != 1 {
+		t.Errorf("ran _show %d times, want once", len(calls))
+	}
+}
+
+func TestMutation(t *testing.T) {
+	ts := newSession(t, taskwarrior.Restriction{})
+
+	data, codes := ts.exec(t, `mutation { doneTask(uuid: "`+docsUUID+`") { status } }`)
+	if codes != nil {
+		t.Fatal(codes)
+	}
+	if got := data["doneTask"].(map[string]any)["status"]; got != "completed" {
+		t.Errorf("status = %v, want completed", got)
+	}
+	if !slices.Equal(ts.published, []events.Type{events.TaskCompleted}) {
+		t.Errorf("published %v", ts.published)
+	}
+}
+
+func TestMutationErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		restriction taskwarrior.Restriction
+		scope       auth.Scope
+		uuid        string
+		failExport  bool
+		want        string
+	}{
+		{name: "unknown task", uuid: unknownUUID, want: "TASK_NOT_FOUND"},
+		{name: "export fails", uuid: docsUUID, failExport: true, want: "TASK_EXPORT_FAILED"},
+		{name: "out of scope", restriction: taskwarrior.Restriction{Project: "home"}, uuid: docsUUID, want: "TASK_OUT_OF_SCOPE"},
+		{name: "read scope", scope: auth.ScopeRead, uuid: docsUUID, want: "INSUFFICIENT_SCOPE"},
+		{name: "invalid uuid", uuid: "docs", want: "INVALID_UUID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newSession(t, tt.restriction)
+			if tt.scope != "" {
+				ts.Scope = tt.scope
+			}
+			ts.fake.Fail("export", tt.failExport)
+
+			_, codes := ts.exec(t, `mutation { deleteTask(uuid: "`+tt.uuid+`") { uuid } }`)
+			if !slices.Equal(codes, []string{tt.want}) {
+				t.Errorf("got %v, want %s", codes, tt.want)
+			}
+			if calls := ts.fake.CallsOf("delete"); len(calls) != 0 {
+				t.Errorf("the task was deleted: %q", calls)
+			}
+			if len(ts.published) != 0 {
+				t.Errorf("published %v", ts.published)
+			}
+		})
+	}
+}
+
+func jsonString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
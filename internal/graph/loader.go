@@ -0,0 +1,208 @@
+package graph
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+const (
+	// batchWait is how long a task lookup waits for others to join its
+	// batch
+	batchWait = time.Millisecond
+	// maxBatch is the most UUIDs passed to a single task export
+	maxBatch = 500
+)
+
+// call is a result shared by all resolvers asking for it
+type call[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// loader batches and caches the task data of one request. Identical
+// exports run once, and tasks looked up by UUID in the same moment, such
+// as the dependencies of every task in a list, are fetched with a single
+// export.
+type loader struct {
+	client      *taskwarrior.Client
+	restriction taskwarrior.Restriction
+
+	mu      sync.Mutex
+	exports map[string]*call[[]taskwarrior.Task]
+	show    *call[string]
+	// tasks holds the tasks seen so far by UUID; nil marks a task that
+	// does not exist or is outside the restriction
+	tasks map[string]*taskwarrior.Task
+	// expected are UUIDs likely to be looked up, fetched with the next
+	// batch
+	expected map[string]bool
+	batch    *call[map[string]bool]
+}
+
+func newLoader(client *taskwarrior.Client, restriction taskwarrior.Restriction) *loader {
+	l := &loader{client: client, restriction: restriction}
+	l.reset()
+	return l
+}
+
+// reset forgets everything loaded, after a mutation changed tasks
+func (l *loader) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.exports = make(map[string]*call[[]taskwarrior.Task])
+	l.show = nil
+	l.tasks = make(map[string]*taskwarrior.Task)
+	l.expected = make(map[string]bool)
+}
+
+// export returns the tasks within the restriction that match filters, or
+// the tasks of report
+func (l *loader) export(filters []string, report string) ([]taskwarrior.Task, error) {
+	filters = append(filters, l.restriction.Filters()...)
+	key := report + "\x00" + strings.Join(filters, "\x00")
+
+	l.mu.Lock()
+	c, ok := l.exports[key]
+	if !ok {
+		c = &call[[]taskwarrior.Task]{done: make(chan struct{})}
+		l.exports[key] = c
+	}
+	l.mu.Unlock()
+
+	if !ok {
+		tasks, err := l.client.ExportReport(filters, report)
+		if err == nil {
+			tasks = l.restriction.Apply(tasks)
+			l.prime(tasks)
+		}
+		c.val, c.err = tasks, err
+		close(c.done)
+	}
+
+	<-c.done
+	return c.val, c.err
+}
+
+// showOutput returns the output of task _show, which holds the reports
+// and contexts
+func (l *loader) showOutput() (string, error) {
+	l.mu.Lock()
+	c := l.show
+	owner := c == nil
+	if owner {
+		c = &call[string]{done: make(chan struct{})}
+		l.show = c
+	}
+	l.mu.Unlock()
+
+	if owner {
+		c.val, c.err = l.client.Show()
+		close(c.done)
+	}
+
+	<-c.done
+	return c.val, c.err
+}
+
+// prime records tasks loaded by an export, so that lookups by UUID need
+// no further export
+func (l *loader) prime(tasks []taskwarrior.Task) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := range tasks {
+		task := tasks[i]
+		l.tasks[task.UUID] = &task
+	}
+}
+
+// expect marks UUIDs that are likely to be looked up, so the first lookup
+// fetches them all
+func (l *loader) expect(uuids []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, uuid := range uuids {
+		if _, ok := l.tasks[uuid]; !ok {
+			l.expected[uuid] = true
+		}
+	}
+}
+
+// task returns the task with uuid, or nil if it does not exist or is
+// outside the restriction
+func (l *loader) task(uuid string) (*taskwarrior.Task, error) {
+	l.mu.Lock()
+	if task, ok := l.tasks[uuid]; ok {
+		l.mu.Unlock()
+		return task, nil
+	}
+
+	b := l.batch
+	if b == nil {
+		b = &call[map[string]bool]{done: make(chan struct{}), val: make(map[string]bool)}
+		l.batch = b
+		time.AfterFunc(batchWait, func() { l.runBatch(b) })
+	}
+	b.val[uuid] = true
+	l.mu.Unlock()
+
+	<-b.done
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.tasks[uuid], nil
+}
+
+// runBatch exports the tasks of a batch together with the expected ones
+func (l *loader) runBatch(b *call[map[string]bool]) {
+	l.mu.Lock()
+	if l.batch == b {
+		l.batch = nil
+	}
+	for uuid := range l.expected {
+		if _, ok := l.tasks[uuid]; !ok {
+			b.val[uuid] = true
+		}
+	}
+	l.expected = make(map[string]bool)
+	uuids := make([]string, 0, len(b.val))
+	for uuid := range b.val {
+		uuids = append(uuids, uuid)
+	}
+	l.mu.Unlock()
+	slices.Sort(uuids)
+
+	found := make(map[string]*taskwarrior.Task)
+	for chunk := range slices.Chunk(uuids, maxBatch) {
+		tasks, err := l.client.Export(chunk...)
+		if err != nil {
+			b.err = err
+			close(b.done)
+			return
+		}
+		for i := range tasks {
+			found[tasks[i].UUID] = &tasks[i]
+		}
+	}
+
+	l.mu.Lock()
+	for _, uuid := range uuids {
+		task := found[uuid]
+		if task != nil && !l.restriction.Allows(*task) {
+			task = nil
+		}
+		l.tasks[uuid] = task
+	}
+	l.mu.Unlock()
+	close(b.done)
+}
@@ -0,0 +1,224 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/auth"
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/graph-gophers/graphql-go"
+)
+
+type taskCreateInput struct {
+	Description string
+	Project     *string
+	Tags        *[]string
+	Priority    *string
+	Due         *graphql.Time
+	Wait        *graphql.Time
+	Scheduled   *graphql.Time
+	Depends     *[]graphql.ID
+	Recur       *string
+}
+
+type taskModifyInput struct {
+	Description *string
+	Project     *string
+	Tags        *[]string
+	Priority    *string
+	Due         *graphql.Time
+	Wait        *graphql.Time
+	Scheduled   *graphql.Time
+	Depends     *[]graphql.ID
+}
+
+// CreateTask resolves Mutation.createTask like POST /api/v1/tasks
+func (r *Resolver) CreateTask(ctx context.Context, args struct{ Input taskCreateInput }) (*taskResolver, error) {
+	s := sessionFrom(ctx)
+	if err := s.requireWrite(); err != nil {
+		return nil, err
+	}
+
+	input := args.Input
+	create := taskwarrior.TaskCreate{
+		Description: taskwarrior.SanitizeInput(input.Description),
+		Project:     taskwarrior.SanitizeInput(deref(input.Project)),
+		Priority:    deref(input.Priority),
+		Due:         timeArg(input.Due),
+		Wait:        timeArg(input.Wait),
+		Scheduled:   timeArg(input.Scheduled),
+		Depends:     uuidArgs(input.Depends),
+		Recur:       deref(input.Recur),
+	}
+	if input.Tags != nil {
+		create.Tags = *input.Tags
+	}
+	if create.Description == "" {
+		return nil, &Error{Message: "description is required", Code: "INVALID_REQUEST"}
+	}
+	if !s.Restriction.Confine(&create) {
+		return nil, errProjectOutOfScope
+	}
+
+	uuid, err := s.Client.Add(create)
+	if err != nil {
+		return nil, &Error{Message: "failed to create task", Code: "TASK_CREATE_FAILED"}
+	}
+	return s.changed(events.TaskCreated, uuid, nil)
+}
+
+// UpdateTask resolves Mutation.updateTask like PATCH /api/v1/tasks/:uuid
+func (r *Resolver) UpdateTask(ctx context.Context, args struct {
+	UUID  graphql.ID
+	Input taskModifyInput
+}) (*taskResolver, error) {
+	s := sessionFrom(ctx)
+	if err := s.requireWrite(); err != nil {
+		return nil, err
+	}
+
+	input := args.Input
+	modify := taskwarrior.TaskModify{
+		Priority:  input.Priority,
+		Due:       timeArg(input.Due),
+		Wait:      timeArg(input.Wait),
+		Scheduled: timeArg(input.Scheduled),
+		Depends:   uuidArgs(input.Depends),
+	}
+	if input.Description != nil {
+		desc := taskwarrior.SanitizeInput(*input.Description)
+		modify.Description = &desc
+	}
+	if input.Project != nil {
+		proj := taskwarrior.SanitizeInput(*input.Project)
+		modify.Project = &proj
+		if !s.Restriction.AllowsProject(proj) {
+			return nil, errProjectOutOfScope
+		}
+	}
+	if input.Tags != nil {
+		modify.Tags = *input.Tags
+	}
+
+	return s.mutate(args.UUID, events.TaskModified, "failed to update task", "TASK_UPDATE_FAILED",
+		func(client *taskwarrior.Client, uuid string) error { return client.Modify(uuid, modify) })
+}
+
+// DeleteTask resolves Mutation.deleteTask like DELETE /api/v1/tasks/:uuid
+func (r *Resolver) DeleteTask(ctx context.Context, args struct{ UUID graphql.ID }) (*taskResolver, error) {
+	s := sessionFrom(ctx)
+	return s.mutate(args.UUID, events.TaskDeleted, "failed to delete task", "TASK_DELETE_FAILED", (*taskwarrior.Client).Delete)
+}
+
+// DoneTask resolves Mutation.doneTask like POST /api/v1/tasks/:uuid/done
+func (r *Resolver) DoneTask(ctx context.Context, args struct{ UUID graphql.ID }) (*taskResolver, error) {
+	s := sessionFrom(ctx)
+	return s.mutate(args.UUID, events.TaskCompleted, "failed to mark task as done", "TASK_DONE_FAILED", (*taskwarrior.Client).Done)
+}
+
+// StartTask resolves Mutation.startTask like POST /api/v1/tasks/:uuid/start
+func (r *Resolver) StartTask(ctx context.Context, args struct{ UUID graphql.ID }) (*taskResolver, error) {
+	s := sessionFrom(ctx)
+	return s.mutate(args.UUID, events.TaskStarted, "failed to start task", "TASK_START_FAILED", (*taskwarrior.Client).Start)
+}
+
+// StopTask resolves Mutation.stopTask like POST /api/v1/tasks/:uuid/stop
+func (r *Resolver) StopTask(ctx context.Context, args struct{ UUID graphql.ID }) (*taskResolver, error) {
+	s := sessionFrom(ctx)
+	return s.mutate(args.UUID, events.TaskStopped, "failed to stop task", "TASK_STOP_FAILED", (*taskwarrior.Client).Stop)
+}
+
+var errProjectOutOfScope = &Error{
+	Message: "project is outside the project this token may access",
+	Code:    "PROJECT_OUT_OF_SCOPE",
+}
+
+// requireWrite rejects mutations by tokens without the write scope
+func (s *Session) requireWrite() error {
+	if s.Scope.Includes(auth.ScopeWrite) {
+		return nil
+	}
+	return &Error{Message: "token does not have the write scope", Code: "INSUFFICIENT_SCOPE"}
+}
+
+// mutate runs a change to an existing task within the token's
+// restriction and returns the task afterwards. The task is checked and
+// changed under the client's write lock, so no other write can move it
+// out of the restriction in between.
+func (s *Session) mutate(id graphql.ID, eventType events.Type, message, code string, change func(client *taskwarrior.Client, uuid string) error) (*taskResolver, error) {
+	if err := s.requireWrite(); err != nil {
+		return nil, err
+	}
+
+	uuid := string(id)
+	if !taskwarrior.ValidateTaskUUID(uuid) {
+		return nil, &Error{Message: "invalid task UUID format", Code: "INVALID_UUID"}
+	}
+
+	var before *taskwarrior.Task
+	err := s.Client.CheckedWrite(uuid, func(task *taskwarrior.Task, err error) error {
+		if err != nil {
+			return taskError(err)
+		}
+		if !s.Restriction.Allows(*task) {
+			return &Error{
+				Message: "task is outside the projects and tags this token may access",
+				Code:    "TASK_OUT_OF_SCOPE",
+			}
+		}
+		before = task
+		return nil
+	}, func(locked *taskwarrior.Client) error {
+		if err := change(locked, uuid); err != nil {
+			return &Error{Message: message, Code: code}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.changed(eventType, uuid, before)
+}
+
+// taskError maps a failed task lookup onto TASK_NOT_FOUND or, when the
+// task could not be read, TASK_EXPORT_FAILED
+func taskError(err error) *Error {
+	if errors.Is(err, taskwarrior.ErrTaskNotFound) {
+		return &Error{Message: "task not found", Code: "TASK_NOT_FOUND"}
+	}
+	return &Error{Message: "failed to retrieve task", Code: "TASK_EXPORT_FAILED"}
+}
+
+// changed publishes a task change and returns the task afterwards. Later
+// fields of the request see the change, as everything loaded before is
+// forgotten.
+func (s *Session) changed(eventType events.Type, uuid string, before *taskwarrior.Task) (*taskResolver, error) {
+	s.loader.reset()
+
+	after, err := s.Client.GetByUUID(uuid)
+	s.Publish(eventType, uuid, before, after)
+	if err != nil {
+		return nil, taskError(err)
+	}
+	return &taskResolver{s: s, task: *after}, nil
+}
+
+func timeArg(t *graphql.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	return &t.Time
+}
+
+func uuidArgs(ids *[]graphql.ID) []string {
+	if ids == nil {
+		return nil
+	}
+	uuids := make([]string, len(*ids))
+	for i, id := range *ids {
+		uuids[i] = string(id)
+	}
+	return uuids
+}
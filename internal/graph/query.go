@@ -0,0 +1,401 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/graph-gophers/graphql-go"
+)
+
+// Resolver is the root resolver of queries and mutations
+type Resolver struct{}
+
+// Tasks resolves Query.tasks
+func (r *Resolver) Tasks(ctx context.Context, args struct {
+	Status  *string
+	Project *string
+	Tags    *[]string
+	Filter  *string
+	Report  *string
+}) ([]*taskResolver, error) {
+	s := sessionFrom(ctx)
+
+	expr, err := taskwarrior.ParseFilter(deref(args.Filter))
+	if err != nil {
+		return nil, &Error{Message: err.Error(), Code: "INVALID_FILTER"}
+	}
+
+	report := deref(args.Report)
+	status := taskwarrior.StatusPending
+	if report != "" {
+		status = ""
+	}
+	if args.Status != nil {
+		status = *args.Status
+	}
+
+	var tags []string
+	if args.Tags != nil {
+		tags = *args.Tags
+	}
+	filters, err := taskwarrior.FilterTerms(status, deref(args.Project), tags)
+	if err != nil {
+		return nil, &Error{Message: err.Error(), Code: "INVALID_FILTER"}
+	}
+	filters = append(filters, expr...)
+
+	tasks, err := s.loader.export(filters, report)
+	if errors.Is(err, taskwarrior.ErrUnknownReport) {
+		return nil, &Error{Message: "report is not defined", Code: "INVALID_REPORT"}
+	}
+	if err != nil {
+		return nil, &Error{Message: "failed to retrieve tasks", Code: "TASK_EXPORT_FAILED"}
+	}
+	return s.taskResolvers(tasks), nil
+}
+
+// Task resolves Query.task
+func (r *Resolver) Task(ctx context.Context, args struct{ UUID graphql.ID }) (*taskResolver, error) {
+	s := sessionFrom(ctx)
+
+	uuid := string(args.UUID)
+	if !taskwarrior.ValidateTaskUUID(uuid) {
+		return nil, &Error{Message: "invalid task UUID format", Code: "INVALID_UUID"}
+	}
+
+	task, err := s.loader.task(uuid)
+	if err != nil {
+		return nil, &Error{Message: "failed to retrieve task", Code: "TASK_EXPORT_FAILED"}
+	}
+	if task == nil {
+		return nil, nil
+	}
+	return &taskResolver{s: s, task: *task}, nil
+}
+
+// pendingTasks returns the pending tasks the projects and tags are taken
+// from
+func (s *Session) pendingTasks() ([]taskwarrior.Task, error) {
+	return s.loader.export([]string{"status:" + taskwarrior.StatusPending}, "")
+}
+
+var errProjects = &Error{Message: "failed to retrieve projects", Code: "PROJECT_LIST_FAILED"}
+
+// Projects resolves Query.projects
+func (r *Resolver) Projects(ctx context.Context) ([]*projectResolver, error) {
+	s := sessionFrom(ctx)
+
+	tasks, err := s.pendingTasks()
+	if err != nil {
+		return nil, errProjects
+	}
+
+	projects := taskwarrior.ProjectsOf(tasks)
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].Name < projects[j].Name
+	})
+
+	resolvers := make([]*projectResolver, len(projects))
+	for i, project := range projects {
+		resolvers[i] = &projectResolver{s: s, project: project}
+	}
+	return resolvers, nil
+}
+
+// Project resolves Query.project
+func (r *Resolver) Project(ctx context.Context, args struct{ Name string }) (*projectResolver, error) {
+	s := sessionFrom(ctx)
+
+	tasks, err := s.pendingTasks()
+	if err != nil {
+		return nil, errProjects
+	}
+
+	for _, project := range taskwarrior.ProjectsOf(tasks) {
+		if project.Name == args.Name {
+			return &projectResolver{s: s, project: project}, nil
+		}
+	}
+	return nil, nil
+}
+
+// Tags resolves Query.tags
+func (r *Resolver) Tags(ctx context.Context) ([]*tagResolver, error) {
+	s := sessionFrom(ctx)
+
+	tasks, err := s.pendingTasks()
+	if err != nil {
+		return nil, &Error{Message: "failed to retrieve tags", Code: "TASK_EXPORT_FAILED"}
+	}
+
+	counts := make(map[string]int32)
+	for _, task := range tasks {
+		for _, tag := range task.Tags {
+			counts[tag]++
+		}
+	}
+
+	resolvers := make([]*tagResolver, 0, len(counts))
+	for _, name := range taskwarrior.ExtractTagsFromTasks(tasks) {
+		resolvers = append(resolvers, &tagResolver{name: name, count: counts[name]})
+	}
+	return resolvers, nil
+}
+
+// reports returns the reports defined in the taskrc, sorted by name
+func (s *Session) reports() ([]taskwarrior.ReportInfo, error) {
+	output, err := s.loader.showOutput()
+	if err != nil {
+		return nil, &Error{Message: "failed to retrieve reports", Code: "REPORTS_LIST_FAILED"}
+	}
+
+	reports := taskwarrior.ParseReports(output)
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Name < reports[j].Name
+	})
+	return reports, nil
+}
+
+// Reports resolves Query.reports
+func (r *Resolver) Reports(ctx context.Context) ([]*reportResolver, error) {
+	s := sessionFrom(ctx)
+
+	reports, err := s.reports()
+	if err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*reportResolver, len(reports))
+	for i, report := range reports {
+		resolvers[i] = &reportResolver{s: s, ReportInfo: report}
+	}
+	return resolvers, nil
+}
+
+// Report resolves Query.report
+func (r *Resolver) Report(ctx context.Context, args struct{ Name string }) (*reportResolver, error) {
+	s := sessionFrom(ctx)
+
+	reports, err := s.reports()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, report := range reports {
+		if report.Name == args.Name {
+			return &reportResolver{s: s, ReportInfo: report}, nil
+		}
+	}
+	return nil, nil
+}
+
+// Contexts resolves Query.contexts
+func (r *Resolver) Contexts(ctx context.Context) ([]*contextResolver, error) {
+	s := sessionFrom(ctx)
+
+	output, err := s.loader.showOutput()
+	if err != nil {
+		return nil, &Error{Message: "failed to retrieve contexts", Code: "CONTEXTS_LIST_FAILED"}
+	}
+
+	contexts := taskwarrior.ParseContexts(output)
+	sort.Slice(contexts, func(i, j int) bool {
+		return contexts[i].Name < contexts[j].Name
+	})
+
+	resolvers := make([]*contextResolver, len(contexts))
+	for i, info := range contexts {
+		resolvers[i] = &contextResolver{info}
+	}
+	return resolvers, nil
+}
+
+// taskResolvers wraps tasks, telling the loader about their dependencies
+// so that they are fetched together
+func (s *Session) taskResolvers(tasks []taskwarrior.Task) []*taskResolver {
+	resolvers := make([]*taskResolver, len(tasks))
+	for i, task := range tasks {
+		s.loader.expect(task.Depends)
+		resolvers[i] = &taskResolver{s: s, task: task}
+	}
+	return resolvers
+}
+
+type taskResolver struct {
+	s    *Session
+	task taskwarrior.Task
+}
+
+func (r *taskResolver) ID() *int32 {
+	if r.task.ID == 0 {
+		return nil
+	}
+	id := int32(r.task.ID)
+	return &id
+}
+
+func (r *taskResolver) UUID() graphql.ID         { return graphql.ID(r.task.UUID) }
+func (r *taskResolver) Description() string      { return r.task.Description }
+func (r *taskResolver) Status() string           { return r.task.Status }
+func (r *taskResolver) Project() *string         { return optional(r.task.Project) }
+func (r *taskResolver) Tags() []string           { return nonNil(r.task.Tags) }
+func (r *taskResolver) Priority() *string        { return optional(r.task.Priority) }
+func (r *taskResolver) Entry() *graphql.Time     { return timeOf(r.task.Entry) }
+func (r *taskResolver) Modified() *graphql.Time  { return timeOf(r.task.Modified) }
+func (r *taskResolver) Start() *graphql.Time     { return timeOf(r.task.Start) }
+func (r *taskResolver) End() *graphql.Time       { return timeOf(r.task.End) }
+func (r *taskResolver) Due() *graphql.Time       { return timeOf(r.task.Due) }
+func (r *taskResolver) Until() *graphql.Time     { return timeOf(r.task.Until) }
+func (r *taskResolver) Wait() *graphql.Time      { return timeOf(r.task.Wait) }
+func (r *taskResolver) Scheduled() *graphql.Time { return timeOf(r.task.Scheduled) }
+func (r *taskResolver) Urgency() float64         { return r.task.Urgency }
+func (r *taskResolver) Recur() *string           { return optional(r.task.Recur) }
+
+func (r *taskResolver) Parent() (*taskResolver, error) {
+	if r.task.Parent == "" {
+		return nil, nil
+	}
+	task, err := r.s.loader.task(r.task.Parent)
+	if err != nil {
+		return nil, &Error{Message: "failed to retrieve task", Code: "TASK_EXPORT_FAILED"}
+	}
+	if task == nil {
+		return nil, nil
+	}
+	return &taskResolver{s: r.s, task: *task}, nil
+}
+
+func (r *taskResolver) Annotations() []*annotationResolver {
+	resolvers := make([]*annotationResolver, len(r.task.Annotations))
+	for i, annotation := range r.task.Annotations {
+		resolvers[i] = &annotationResolver{annotation}
+	}
+	return resolvers
+}
+
+func (r *taskResolver) Depends() []graphql.ID {
+	ids := make([]graphql.ID, len(r.task.Depends))
+	for i, uuid := range r.task.Depends {
+		ids[i] = graphql.ID(uuid)
+	}
+	return ids
+}
+
+func (r *taskResolver) Dependencies() ([]*taskResolver, error) {
+	// Announce all dependencies before the first lookup starts the batch
+	r.s.loader.expect(r.task.Depends)
+
+	var tasks []taskwarrior.Task
+	for _, uuid := range r.task.Depends {
+		task, err := r.s.loader.task(uuid)
+		if err != nil {
+			return nil, &Error{Message: "failed to retrieve task", Code: "TASK_EXPORT_FAILED"}
+		}
+		if task != nil {
+			tasks = append(tasks, *task)
+		}
+	}
+	return r.s.taskResolvers(tasks), nil
+}
+
+type annotationResolver struct {
+	annotation taskwarrior.Annotation
+}
+
+func (r *annotationResolver) Entry() *graphql.Time { return timeOf(&r.annotation.Entry) }
+func (r *annotationResolver) Description() string  { return r.annotation.Description }
+
+type projectResolver struct {
+	s       *Session
+	project taskwarrior.Project
+}
+
+func (r *projectResolver) Name() string { return r.project.Name }
+func (r *projectResolver) Count() int32 { return int32(r.project.Count) }
+
+// Tasks resolves Project.tasks from a single export of all tasks with the
+// status, shared by every project in the query
+func (r *projectResolver) Tasks(args struct{ Status string }) ([]*taskResolver, error) {
+	filters, err := taskwarrior.FilterTerms(args.Status, "", nil)
+	if err != nil {
+		return nil, &Error{Message: err.Error(), Code: "INVALID_FILTER"}
+	}
+	tasks, err := r.s.loader.export(filters, "")
+	if err != nil {
+		return nil, &Error{Message: "failed to retrieve project tasks", Code: "PROJECT_TASKS_FAILED"}
+	}
+
+	restriction := taskwarrior.Restriction{Project: r.project.Name}
+	return r.s.taskResolvers(slices.DeleteFunc(slices.Clone(tasks), func(task taskwarrior.Task) bool {
+		return !restriction.AllowsProject(task.Project)
+	})), nil
+}
+
+type tagResolver struct {
+	name  string
+	count int32
+}
+
+func (r *tagResolver) Name() string { return r.name }
+func (r *tagResolver) Count() int32 { return r.count }
+
+type reportResolver struct {
+	s *Session
+	taskwarrior.ReportInfo
+}
+
+func (r *reportResolver) Name() string        { return r.ReportInfo.Name }
+func (r *reportResolver) Description() string { return r.ReportInfo.Description }
+func (r *reportResolver) Filter() string      { return r.ReportInfo.Filter }
+func (r *reportResolver) Columns() string     { return r.ReportInfo.Columns }
+func (r *reportResolver) Labels() string      { return r.ReportInfo.Labels }
+func (r *reportResolver) Sort() string        { return r.ReportInfo.Sort }
+func (r *reportResolver) Context() string     { return r.ReportInfo.Context }
+
+func (r *reportResolver) Tasks() ([]*taskResolver, error) {
+	tasks, err := r.s.loader.export(nil, r.ReportInfo.Name)
+	if err != nil {
+		return nil, &Error{Message: "failed to retrieve tasks", Code: "REPORT_FAILED"}
+	}
+	return r.s.taskResolvers(tasks), nil
+}
+
+type contextResolver struct {
+	info taskwarrior.ContextInfo
+}
+
+func (r *contextResolver) Name() string  { return r.info.Name }
+func (r *contextResolver) Read() string  { return r.info.Read }
+func (r *contextResolver) Write() string { return r.info.Write }
+func (r *contextResolver) Active() bool  { return r.info.Active }
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func timeOf(t *taskwarrior.TaskwarriorTime) *graphql.Time {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return &graphql.Time{Time: t.Time}
+}
@@ -0,0 +1,248 @@
+package importers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+// CSVMapping says which columns hold which task fields. Columns are given
+// by header name (case-insensitive) or by 1-based number; empty fields
+// are not imported.
+type CSVMapping struct {
+	Description string
+	Project     string
+	Tags        string
+	Priority    string
+	Due         string
+	Wait        string
+	Scheduled   string
+	Status      string
+	Entry       string
+	End         string
+
+	// TagSeparator splits the tags column, "," if empty
+	TagSeparator string
+	// DateFormat is a Go time layout tried before RFC 3339 and
+	// "2006-01-02"
+	DateFormat string
+	// Delimiter separates columns, "," if zero
+	Delimiter rune
+	// NoHeader is set when the first row holds data, not column names
+	NoHeader bool
+}
+
+// columns resolves the mapping against the header row
+type columns map[string]int
+
+// ParseCSV converts a CSV file using mapping. Every data row becomes a
+// record.
+func ParseCSV(r io.Reader, mapping CSVMapping) ([]Record, error) {
+	if mapping.Description == "" {
+		return nil, errors.New("a description column is required")
+	}
+
+	reader := csv.NewReader(r)
+	if mapping.Delimiter != 0 {
+		reader.Comma = mapping.Delimiter
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var header []string
+	if !mapping.NoHeader {
+		var err error
+		if header, err = reader.Read(); err != nil {
+			if err == io.EOF {
+				return nil, errors.New("no header row")
+			}
+			return nil, err
+		}
+	}
+
+	cols, err := resolveColumns(mapping, header)
+	if err != nil {
+		return nil, err
+	}
+
+	delimiter := string(reader.Comma)
+	var records []Record
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		source := strings.Join(row, delimiter)
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+		record := Record{Line: line, Source: source}
+		task, err := convertRow(row, cols, mapping, &record)
+		if err != nil {
+			record.Error = err.Error()
+		} else {
+			record.Task = task
+		}
+		records = append(records, record)
+	}
+
+	assignUUIDs("csv", records)
+	return records, nil
+}
+
+func resolveColumns(mapping CSVMapping, header []string) (columns, error) {
+	cols := make(columns)
+	for field, ref := range map[string]string{
+		"description": mapping.Description,
+		"project":     mapping.Project,
+		"tags":        mapping.Tags,
+		"priority":    mapping.Priority,
+		"due":         mapping.Due,
+		"wait":        mapping.Wait,
+		"scheduled":   mapping.Scheduled,
+		"status":      mapping.Status,
+		"entry":       mapping.Entry,
+		"end":         mapping.End,
+	} {
+		if ref == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(ref); err == nil {
+			if n < 1 {
+				return nil, fmt.Errorf("%s: invalid column %d", field, n)
+			}
+			cols[field] = n - 1
+			continue
+		}
+
+		found := false
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), ref) {
+				cols[field], found = i, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s: no column named %q", field, ref)
+		}
+	}
+	return cols, nil
+}
+
+func convertRow(row []string, cols columns, mapping CSVMapping, record *Record) (*taskwarrior.TaskCreate, error) {
+	value := func(field string) string {
+		i, ok := cols[field]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	date := func(field string) (*time.Time, error) {
+		v := value(field)
+		if v == "" {
+			return nil, nil
+		}
+		t, err := parseCSVDate(v, mapping.DateFormat)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s date %q", field, v)
+		}
+		return &t, nil
+	}
+
+	task := &taskwarrior.TaskCreate{
+		Description: value("description"),
+		Project:     value("project"),
+	}
+	if task.Description == "" {
+		return nil, errors.New("description is empty")
+	}
+
+	if tags := value("tags"); tags != "" {
+		separator := mapping.TagSeparator
+		if separator == "" {
+			separator = ","
+		}
+		for _, tag := range strings.Split(tags, separator) {
+			// Tags cannot contain spaces
+			if tag = strings.Join(strings.Fields(tag), "_"); tag != "" {
+				task.Tags = append(task.Tags, tag)
+			}
+		}
+	}
+
+	if p := value("priority"); p != "" {
+		priority, ok := csvPriority(p)
+		if !ok {
+			return nil, fmt.Errorf("invalid priority %q", p)
+		}
+		task.Priority = priority
+	}
+
+	var err error
+	if task.Due, err = date("due"); err != nil {
+		return nil, err
+	}
+	if task.Wait, err = date("wait"); err != nil {
+		return nil, err
+	}
+	if task.Scheduled, err = date("scheduled"); err != nil {
+		return nil, err
+	}
+	if record.Entry, err = date("entry"); err != nil {
+		return nil, err
+	}
+	if record.End, err = date("end"); err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(value("status")) {
+	case "", "pending", "open", "todo", "no", "false", "0":
+	case "completed", "done", "x", "yes", "true", "1":
+		record.Completed = true
+		if record.End == nil {
+			record.End = record.Entry
+		}
+	default:
+		return nil, fmt.Errorf("invalid status %q", value("status"))
+	}
+
+	return task, nil
+}
+
+func parseCSVDate(s, layout string) (time.Time, error) {
+	layouts := []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"}
+	if layout != "" {
+		layouts = append([]string{layout}, layouts...)
+	}
+	for _, l := range layouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("unrecognized date")
+}
+
+// csvPriority accepts Taskwarrior priorities, their names and todo.txt
+// letters
+func csvPriority(p string) (string, bool) {
+	switch strings.ToLower(p) {
+	case "h", "high", "a":
+		return taskwarrior.PriorityHigh, true
+	case "m", "medium", "b":
+		return taskwarrior.PriorityMedium, true
+	case "l", "low", "c":
+		return taskwarrior.PriorityLow, true
+	}
+	return "", false
+}
@@ -0,0 +1,92 @@
+package importers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+func TestParseCSV(t *testing.T) {
+	input := strings.Join([]string{
+		"Title,Labels,Due Date,Done,Prio",
+		"Write report,\"work, q1\",2026-04-01,no,high",
+		"Pay rent,,2026-02-01T17:00:00Z,yes,",
+		"Bad date,,tomorrow,no,",
+		"Bad priority,,,no,urgent",
+		",,,no,",
+		"Short row",
+		"",
+	}, "\n")
+	mapping := CSVMapping{Description: "title", Tags: "Labels", Due: "Due Date", Status: "done", Priority: "5"}
+
+	records, err := ParseCSV(strings.NewReader(input), mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	due := time.Date(2026, 2, 1, 17, 0, 0, 0, time.UTC)
+	tests := []struct {
+		line      int
+		want      *taskwarrior.TaskCreate
+		completed bool
+		err       string
+	}{
+		{line: 2, want: &taskwarrior.TaskCreate{Description: "Write report", Tags: []string{"work", "q1"}, Due: day("2026-04-01"), Priority: "H"}},
+		{line: 3, want: &taskwarrior.TaskCreate{Description: "Pay rent", Due: &due}, completed: true},
+		{line: 4, err: `invalid due date "tomorrow"`},
+		{line: 5, err: `invalid priority "urgent"`},
+		{line: 6, err: "description is empty"},
+		{line: 7, want: &taskwarrior.TaskCreate{Description: "Short row"}},
+	}
+	if len(records) != len(tests) {
+		t.Fatalf("got %d records, want %d", len(records), len(tests))
+	}
+	for i, tt := range tests {
+		r := records[i]
+		if r.Line != tt.line || r.Error != tt.err || r.Completed != tt.completed {
+			t.Errorf("record %d: line %d, error %q, completed %v", i, r.Line, r.Error, r.Completed)
+		}
+		if !reflect.DeepEqual(r.Task, tt.want) {
+			t.Errorf("record %d: task %+v, want %+v", i, r.Task, tt.want)
+		}
+	}
+}
+
+func TestParseCSVOptions(t *testing.T) {
+	records, err := ParseCSV(strings.NewReader("01/02/2026;a|b c;Pay rent\n"), CSVMapping{
+		Description:  "3",
+		Tags:         "2",
+		Due:          "1",
+		TagSeparator: "|",
+		DateFormat:   "01/02/2006",
+		Delimiter:    ';',
+		NoHeader:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &taskwarrior.TaskCreate{Description: "Pay rent", Tags: []string{"a", "b_c"}, Due: day("2026-01-02")}
+	if len(records) != 1 || !reflect.DeepEqual(records[0].Task, want) {
+		t.Errorf("got %+v, want %+v", records, want)
+	}
+}
+
+func TestParseCSVMappingErrors(t *testing.T) {
+	tests := []struct {
+		mapping CSVMapping
+		err     string
+	}{
+		{CSVMapping{}, "a description column is required"},
+		{CSVMapping{Description: "Missing"}, `description: no column named "Missing"`},
+		{CSVMapping{Description: "0"}, "description: invalid column 0"},
+	}
+	for _, tt := range tests {
+		_, err := ParseCSV(strings.NewReader("Title\nWrite report\n"), tt.mapping)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%+v: got %v, want %s", tt.mapping, err, tt.err)
+		}
+	}
+}
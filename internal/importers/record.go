@@ -0,0 +1,115 @@
+// Package importers converts tasks kept in other formats, such as todo.txt
+// and spreadsheet exports, into Taskwarrior tasks for the bulk import.
+package importers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+// Record is what one source line became
+type Record struct {
+	Line   int                     `json:"line"`
+	Source string                  `json:"source"`
+	UUID   string                  `json:"uuid,omitempty"`
+	Task   *taskwarrior.TaskCreate `json:"task,omitempty"`
+	// Completed tasks are imported with the end date
+	Completed bool       `json:"completed,omitempty"`
+	Entry     *time.Time `json:"entry,omitempty"`
+	End       *time.Time `json:"end,omitempty"`
+	// Error is set when the line could not be converted
+	Error string `json:"error,omitempty"`
+}
+
+// namespace makes the UUIDs of imported lines distinct from those of
+// other name-based UUIDs
+var namespace = [16]byte{0x6b, 0xa7, 0xb8, 0x14, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+
+// assignUUIDs gives every record a version 5 UUID derived from what
+// identifies its task: the description, project and creation date. Once
+// imported, completing a task or changing its priority, tags or dates in
+// the file updates the task when the file is imported again. Changing the
+// description, project or creation date makes it a new task. Repeated
+// identical tasks are numbered, so each becomes a task of its own.
+func assignUUIDs(format string, records []Record) {
+	seen := make(map[string]int)
+	for i := range records {
+		record := &records[i]
+
+		key := "line\x00" + record.Source
+		if task := record.Task; task != nil {
+			key = "task\x00" + task.Description + "\x00" + task.Project
+			if record.Entry != nil {
+				key += "\x00" + record.Entry.UTC().Format(time.RFC3339)
+			}
+		}
+		seen[key]++
+		if n := seen[key]; n > 1 {
+			key += "\x00" + strconv.Itoa(n)
+		}
+
+		record.UUID = nameUUID(format + ":" + key)
+	}
+}
+
+// nameUUID derives a version 5 UUID from name
+func nameUUID(name string) string {
+	h := sha1.New()
+	h.Write(namespace[:])
+	h.Write([]byte(name))
+	sum := h.Sum(nil)
+
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+
+	s := hex.EncodeToString(sum[:16])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+// ImportItem returns the record in the Taskwarrior JSON format read by
+// task import
+func (r Record) ImportItem() json.RawMessage {
+	task := r.Task
+	item := map[string]any{
+		"uuid":        r.UUID,
+		"description": task.Description,
+		"status":      taskwarrior.StatusPending,
+	}
+	if r.Completed {
+		item["status"] = taskwarrior.StatusCompleted
+	}
+	if task.Project != "" {
+		item["project"] = task.Project
+	}
+	if len(task.Tags) > 0 {
+		item["tags"] = task.Tags
+	}
+	if task.Priority != "" {
+		item["priority"] = task.Priority
+	}
+	if task.Recur != "" {
+		item["recur"] = task.Recur
+	}
+	if len(task.Depends) > 0 {
+		item["depends"] = task.Depends
+	}
+	for key, t := range map[string]*time.Time{
+		"due":       task.Due,
+		"wait":      task.Wait,
+		"scheduled": task.Scheduled,
+		"entry":     r.Entry,
+		"end":       r.End,
+	} {
+		if t != nil {
+			item[key] = t.UTC().Format("20060102T150405Z")
+		}
+	}
+
+	data, _ := json.Marshal(item)
+	return data
+}
@@ -0,0 +1,73 @@
+package importers
+
+import (
+	"strings"
+	"testing"
+)
+
+func todoUUIDs(t *testing.T, file string) []string {
+	t.Helper()
+
+	records, err := ParseTodoTxt(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuids := make([]string, len(records))
+	for i, r := range records {
+		uuids[i] = r.UUID
+	}
+	return uuids
+}
+
+// A task keeps its UUID when its line is completed or its other fields
+// change, so importing the file again updates it
+func TestUUIDStable(t *testing.T) {
+	original := todoUUIDs(t, "(A) 2026-01-01 Write report +work\n")[0]
+	for _, line := range []string{
+		"x 2026-01-05 2026-01-01 Write report +work",
+		"(B) 2026-01-01 Write report +work @office due:2026-02-01",
+		"2026-01-01 Write   report +work",
+	} {
+		if got := todoUUIDs(t, line+"\n")[0]; got != original {
+			t.Errorf("%q: UUID %s, want %s", line, got, original)
+		}
+	}
+
+	for _, line := range []string{
+		"(A) 2026-01-01 Write the report +work",
+		"(A) 2026-01-01 Write report +home",
+		"(A) 2026-01-02 Write report +work",
+	} {
+		if got := todoUUIDs(t, line+"\n")[0]; got == original {
+			t.Errorf("%q: UUID unchanged, want a new task", line)
+		}
+	}
+}
+
+// Identical lines become tasks of their own, and the first one keeps its
+// UUID when more are added
+func TestUUIDDuplicates(t *testing.T) {
+	uuids := todoUUIDs(t, "Water plants\nWater plants\nWater plants\n")
+	if uuids[0] == uuids[1] || uuids[1] == uuids[2] || uuids[0] == uuids[2] {
+		t.Errorf("identical lines share UUIDs: %v", uuids)
+	}
+	if single := todoUUIDs(t, "Water plants\n"); single[0] != uuids[0] {
+		t.Errorf("first UUID %s, want %s", uuids[0], single[0])
+	}
+}
+
+// The same task in todo.txt and CSV, and failed lines, get UUIDs of their
+// own
+func TestUUIDFormats(t *testing.T) {
+	todo := todoUUIDs(t, "Water plants\nWater plants due:never\n")
+	records, err := ParseCSV(strings.NewReader("Water plants\n"), CSVMapping{Description: "1", NoHeader: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[0].UUID == todo[0] {
+		t.Error("todo.txt and CSV share a UUID")
+	}
+	if todo[1] == "" || todo[1] == todo[0] {
+		t.Errorf("failed line UUID %q", todo[1])
+	}
+}
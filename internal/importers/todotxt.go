@@ -0,0 +1,135 @@
+package importers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+var (
+	todoPriorityPattern = regexp.MustCompile(`^\(([A-Z])\) `)
+	todoDatePattern     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2} `)
+)
+
+// ParseTodoTxt converts a todo.txt file. Every non-empty line becomes a
+// record:
+//
+//   - "x " marks a completed task, optionally followed by its completion
+//     date
+//   - "(A)" to "(C)" become priorities H, M and L; lower priorities become L
+//   - a leading date is the creation date
+//   - the first +project becomes the project, further ones become tags
+//   - @contexts become tags
+//   - due:, t: (threshold, mapped to wait) and pri: are read as dates and
+//     priority; other key:value pairs are left in the description
+func ParseTodoTxt(r io.Reader) ([]Record, error) {
+	var records []Record
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		record := Record{Line: n, Source: line}
+		task, err := parseTodoLine(line, &record)
+		if err != nil {
+			record.Error = err.Error()
+		} else {
+			record.Task = task
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	assignUUIDs("todotxt", records)
+	return records, nil
+}
+
+func parseTodoLine(line string, record *Record) (*taskwarrior.TaskCreate, error) {
+	task := &taskwarrior.TaskCreate{}
+	rest := line
+
+	if strings.HasPrefix(rest, "x ") {
+		record.Completed = true
+		rest = strings.TrimSpace(rest[2:])
+		if todoDatePattern.MatchString(rest + " ") {
+			end, _ := time.Parse("2006-01-02", rest[:10])
+			record.End = &end
+			rest = strings.TrimSpace(rest[10:])
+		}
+	}
+
+	if m := todoPriorityPattern.FindStringSubmatch(rest); m != nil {
+		task.Priority = todoPriority(m[1])
+		rest = rest[len(m[0]):]
+	}
+
+	if todoDatePattern.MatchString(rest + " ") {
+		entry, _ := time.Parse("2006-01-02", rest[:10])
+		record.Entry = &entry
+		rest = strings.TrimSpace(rest[10:])
+	}
+
+	var words []string
+	for _, word := range strings.Fields(rest) {
+		switch {
+		case len(word) > 1 && word[0] == '+':
+			if task.Project == "" {
+				task.Project = word[1:]
+			} else {
+				task.Tags = append(task.Tags, word[1:])
+			}
+		case len(word) > 1 && word[0] == '@':
+			task.Tags = append(task.Tags, word[1:])
+		case strings.HasPrefix(word, "due:"), strings.HasPrefix(word, "t:"):
+			key, value, _ := strings.Cut(word, ":")
+			date, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s date %q", key, value)
+			}
+			if key == "due" {
+				task.Due = &date
+			} else {
+				task.Wait = &date
+			}
+		case strings.HasPrefix(word, "pri:") && len(word) == 5:
+			task.Priority = todoPriority(word[4:])
+		default:
+			words = append(words, word)
+		}
+	}
+
+	task.Description = strings.Join(words, " ")
+	if task.Description == "" {
+		return nil, fmt.Errorf("description is empty")
+	}
+
+	// Completed tasks need an end date; fall back to the creation date
+	if record.Completed && record.End == nil {
+		record.End = record.Entry
+	}
+
+	return task, nil
+}
+
+// todoPriority maps todo.txt priorities A to Z onto H, M and L
+func todoPriority(p string) string {
+	switch strings.ToUpper(p) {
+	case "A":
+		return taskwarrior.PriorityHigh
+	case "B":
+		return taskwarrior.PriorityMedium
+	default:
+		return taskwarrior.PriorityLow
+	}
+}
@@ -0,0 +1,103 @@
+package importers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+func day(value string) *time.Time {
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+func TestParseTodoTxt(t *testing.T) {
+	tests := []struct {
+		line      string
+		want      *taskwarrior.TaskCreate
+		completed bool
+		entry     *time.Time
+		end       *time.Time
+		err       string
+	}{
+		{
+			line: "Call mom",
+			want: &taskwarrior.TaskCreate{Description: "Call mom"},
+		},
+		{
+			line: "(A) 2026-01-01 Write report +work +q1 @office due:2026-02-01 t:2026-01-20",
+			want: &taskwarrior.TaskCreate{
+				Description: "Write report", Project: "work", Tags: []string{"q1", "office"},
+				Priority: "H", Due: day("2026-02-01"), Wait: day("2026-01-20"),
+			},
+			entry: day("2026-01-01"),
+		},
+		{
+			line:      "x 2026-01-05 2026-01-01 Pay rent +home",
+			want:      &taskwarrior.TaskCreate{Description: "Pay rent", Project: "home"},
+			completed: true,
+			entry:     day("2026-01-01"),
+			end:       day("2026-01-05"),
+		},
+		{
+			// Without a completion date, the creation date is the end
+			line:      "x Pay rent",
+			want:      &taskwarrior.TaskCreate{Description: "Pay rent"},
+			completed: true,
+		},
+		{
+			line: "(D) Someday pri:B url:https://example.com",
+			want: &taskwarrior.TaskCreate{Description: "Someday url:https://example.com", Priority: "M"},
+		},
+		{
+			line: "(Z) Café mit Jürgen @旅行",
+			want: &taskwarrior.TaskCreate{Description: "Café mit Jürgen", Priority: "L", Tags: []string{"旅行"}},
+		},
+		{line: "Pay rent due:tomorrow", err: `invalid due date "tomorrow"`},
+		{line: "(A) +work @office", err: "description is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			records, err := ParseTodoTxt(strings.NewReader(tt.line + "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 1 {
+				t.Fatalf("got %d records, want 1", len(records))
+			}
+			r := records[0]
+			if r.Line != 1 || r.Source != tt.line || r.UUID == "" {
+				t.Errorf("line %d, source %q, uuid %q", r.Line, r.Source, r.UUID)
+			}
+			if r.Error != tt.err {
+				t.Fatalf("error %q, want %q", r.Error, tt.err)
+			}
+			if !reflect.DeepEqual(r.Task, tt.want) {
+				t.Errorf("task %+v, want %+v", r.Task, tt.want)
+			}
+			if r.Completed != tt.completed || !reflect.DeepEqual(r.Entry, tt.entry) || !reflect.DeepEqual(r.End, tt.end) {
+				t.Errorf("completed %v, entry %v, end %v", r.Completed, r.Entry, r.End)
+			}
+		})
+	}
+}
+
+func TestParseTodoTxtLines(t *testing.T) {
+	records, err := ParseTodoTxt(strings.NewReader("First\n\n  \nSecond\r\nThird"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []int
+	for _, r := range records {
+		lines = append(lines, r.Line)
+	}
+	if !reflect.DeepEqual(lines, []int{1, 4, 5}) {
+		t.Errorf("lines %v, want blank lines skipped", lines)
+	}
+}
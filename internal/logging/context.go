@@ -0,0 +1,23 @@
+package logging
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+const contextKey = "logger"
+
+// SetLogger stores the request's logger in the Gin context
+func SetLogger(c *gin.Context, logger *slog.Logger) {
+	c.Set(contextKey, logger)
+}
+
+// FromContext returns the request's logger, which adds the request ID to
+// every record, or the default logger outside of requests
+func FromContext(c *gin.Context) *slog.Logger {
+	if logger, ok := c.Get(contextKey); ok {
+		return logger.(*slog.Logger)
+	}
+	return slog.Default()
+}
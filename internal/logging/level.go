@@ -0,0 +1,70 @@
+// Package logging configures structured logging with log/slog. The level
+// may change while serving.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Redacted replaces the value of sensitive attributes
+const Redacted = "[REDACTED]"
+
+var level = new(slog.LevelVar)
+
+// sensitiveKeys are attribute keys whose values are never logged
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"password":      true,
+	"secret":        true,
+	"token":         true,
+}
+
+// Setup makes a text or JSON logger writing to w the default logger,
+// which the standard log package then writes through as well
+func Setup(w io.Writer, format, levelName string) error {
+	SetLevel(levelName)
+
+	opts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}
+
+	var handler slog.Handler
+	switch format {
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// SetLevel sets the log level by name: debug, info, warn or error.
+// Unknown names select info.
+func SetLevel(name string) {
+	switch name {
+	case "debug":
+		level.Set(slog.LevelDebug)
+	case "warn":
+		level.Set(slog.LevelWarn)
+	case "error":
+		level.Set(slog.LevelError)
+	default:
+		level.Set(slog.LevelInfo)
+	}
+}
+
+// redact hides the values of sensitive attributes
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
@@ -0,0 +1,323 @@
+// Package metrics collects metrics and serves them in the Prometheus text
+// exposition format
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram buckets in seconds suited to request and
+// subprocess latencies
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Default is the registry metrics are created in
+var Default = &Registry{}
+
+// metric is anything that can write itself in the exposition format
+type metric interface {
+	write(w io.Writer)
+}
+
+// Registry holds metrics in the order they were created
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes all metrics in the text exposition format
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler serves the metrics of the registry
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		buf := bufio.NewWriter(w)
+		r.Write(buf)
+		buf.Flush()
+	})
+}
+
+// desc holds what every metric has in common
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d desc) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, d.kind)
+}
+
+// series holds the values of a metric by label values
+type series[T any] struct {
+	mu     sync.Mutex
+	values map[string]*T
+	labels map[string][]string
+}
+
+func (s *series[T]) get(labelValues []string, create func() *T) *T {
+	key := strings.Join(labelValues, "\xff")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v, ok := s.values[key]; ok {
+		return v
+	}
+	if s.values == nil {
+		s.values = make(map[string]*T)
+		s.labels = make(map[string][]string)
+	}
+	v := create()
+	s.values[key] = v
+	s.labels[key] = append([]string(nil), labelValues...)
+	return v
+}
+
+// each calls fn for every series, sorted by label values
+func (s *series[T]) each(fn func(labelValues []string, v *T)) {
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	s.mu.Unlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s.mu.Lock()
+		v, labels := s.values[key], s.labels[key]
+		s.mu.Unlock()
+		fn(labels, v)
+	}
+}
+
+// value is a float64 updated under a lock
+type value struct {
+	mu sync.Mutex
+	v  float64
+}
+
+func (v *value) add(delta float64) {
+	v.mu.Lock()
+	v.v += delta
+	v.mu.Unlock()
+}
+
+func (v *value) load() float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.v
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	desc
+	series series[value]
+}
+
+// NewCounterVec creates a counter in the default registry
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name: name, help: help, kind: "counter", labels: labels}}
+	Default.register(c)
+	return c
+}
+
+// Inc increments the counter with the given label values
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.series.get(labelValues, newValue).add(1)
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.header(w)
+	c.series.each(func(labelValues []string, v *value) {
+		writeSample(w, c.name, c.labels, labelValues, v.load())
+	})
+}
+
+// GaugeVec is a gauge partitioned by labels
+type GaugeVec struct {
+	desc
+	series series[value]
+}
+
+// NewGaugeVec creates a gauge in the default registry
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{desc: desc{name: name, help: help, kind: "gauge", labels: labels}}
+	if len(labels) == 0 {
+		// A gauge without labels is reported from the start
+		g.series.get(nil, newValue)
+	}
+	Default.register(g)
+	return g
+}
+
+// Add adds delta to the gauge with the given label values
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.series.get(labelValues, newValue).add(delta)
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.header(w)
+	g.series.each(func(labelValues []string, v *value) {
+		writeSample(w, g.name, g.labels, labelValues, v.load())
+	})
+}
+
+// histogram holds the observations of one series
+type histogram struct {
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	desc
+	buckets []float64
+	series  series[histogram]
+}
+
+// NewHistogramVec creates a histogram in the default registry
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{
+		desc:    desc{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+	}
+	Default.register(h)
+	return h
+}
+
+// Observe records v for the given label values
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	s := h.series.get(labelValues, func() *histogram {
+		return &histogram{counts: make([]uint64, len(h.buckets))}
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.header(w)
+	labels := append(append([]string(nil), h.labels...), "le")
+	h.series.each(func(labelValues []string, s *histogram) {
+		s.mu.Lock()
+		counts := append([]uint64(nil), s.counts...)
+		count, sum := s.count, s.sum
+		s.mu.Unlock()
+
+		// Clipped so that appending never writes to the shared label values
+		labelValues = slices.Clip(labelValues)
+		for i, bound := range h.buckets {
+			writeSample(w, h.name+"_bucket", labels, append(labelValues, formatFloat(bound)), float64(counts[i]))
+		}
+		writeSample(w, h.name+"_bucket", labels, append(labelValues, "+Inf"), float64(count))
+		writeSample(w, h.name+"_sum", h.labels, labelValues, sum)
+		writeSample(w, h.name+"_count", h.labels, labelValues, float64(count))
+	})
+}
+
+// Func is a metric whose values are read when metrics are scraped
+type Func struct {
+	desc
+	collect func(observe func(v float64, labelValues ...string))
+}
+
+// NewGaugeFunc creates a gauge in the default registry whose values are
+// reported by collect on every scrape
+func NewGaugeFunc(name, help string, labels []string, collect func(observe func(v float64, labelValues ...string))) *Func {
+	f := &Func{desc: desc{name: name, help: help, kind: "gauge", labels: labels}, collect: collect}
+	Default.register(f)
+	return f
+}
+
+// NewCounterFunc is like NewGaugeFunc for values that only increase
+func NewCounterFunc(name, help string, labels []string, collect func(observe func(v float64, labelValues ...string))) *Func {
+	f := &Func{desc: desc{name: name, help: help, kind: "counter", labels: labels}, collect: collect}
+	Default.register(f)
+	return f
+}
+
+func (f *Func) write(w io.Writer) {
+	f.header(w)
+	f.collect(func(v float64, labelValues ...string) {
+		writeSample(w, f.name, f.labels, labelValues, v)
+	})
+}
+
+func newValue() *value {
+	return &value{}
+}
+
+func writeSample(w io.Writer, name string, labels, labelValues []string, v float64) {
+	io.WriteString(w, name)
+	if len(labels) > 0 {
+		io.WriteString(w, "{")
+		for i, label := range labels {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			fmt.Fprintf(w, "%s=%q", label, escapeLabel(labelValues[i]))
+		}
+		io.WriteString(w, "}")
+	}
+	io.WriteString(w, " "+formatFloat(v)+"\n")
+}
+
+// escapeLabel prepares a label value for %q, which escapes backslashes,
+// quotes and newlines the way the exposition format expects. Other
+// non-printable characters would be escaped differently, so they are
+// replaced.
+func escapeLabel(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r != '\n' && (r < 0x20 || r == 0x7f) {
+			return '?'
+		}
+		return r
+	}, strings.ToValidUTF8(s, "?"))
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often idle buckets are dropped
+const sweepInterval = time.Minute
+
+// Result describes the state of a bucket after a request
+type Result struct {
+	Allowed bool
+	// Limit is the capacity of the bucket
+	Limit int
+	// Remaining is the number of requests that may be made right away
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, when the
+	// request was not
+	RetryAfter time.Duration
+}
+
+// bucket is a token bucket. Tokens are refilled lazily when the bucket is
+// used.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Limiter keeps a token bucket per key. Each bucket holds up to perMinute
+// tokens and is refilled at perMinute tokens per minute, so a client may
+// burst through a minute's allowance at once but not exceed it on average.
+type Limiter struct {
+	capacity float64
+	rate     float64 // tokens per second
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewLimiter creates a limiter allowing perMinute requests per minute and
+// key
+func NewLimiter(perMinute int) *Limiter {
+	return &Limiter{
+		capacity:  float64(perMinute),
+		rate:      float64(perMinute) / 60,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket of key
+func (l *Limiter) Allow(key string) Result {
+	return l.take(key, true)
+}
+
+// Peek reports whether the bucket of key has a token left without taking
+// it
+func (l *Limiter) Peek(key string) Result {
+	return l.take(key, false)
+}
+
+func (l *Limiter) take(key string, consume bool) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.capacity, updated: now}
+		if consume {
+			l.buckets[key] = b
+		}
+	}
+	b.tokens = math.Min(l.capacity, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+
+	result := Result{Limit: int(l.capacity)}
+	if b.tokens >= 1 {
+		result.Allowed = true
+		if consume {
+			b.tokens--
+		}
+	} else {
+		result.RetryAfter = l.duration(1 - b.tokens)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = l.duration(l.capacity - b.tokens)
+
+	return result
+}
+
+// duration returns the time needed to refill tokens
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / l.rate * float64(time.Second)))
+}
+
+// sweep drops buckets that have refilled completely, as they are in the
+// same state as a bucket that was never used
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.rate >= l.capacity {
+			delete(l.buckets, key)
+		}
+	}
+}
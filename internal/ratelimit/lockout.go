@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// failures tracks the failed attempts of one client
+type failures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// Lockout locks clients out after repeated failures. Once threshold
+// failures have been recorded, each further failure locks the client out
+// for twice as long as the previous one, starting at base and capped at
+// max. The count is forgotten after max has passed without a failure.
+type Lockout struct {
+	threshold int
+	base      time.Duration
+	max       time.Duration
+
+	mu        sync.Mutex
+	clients   map[string]*failures
+	lastSweep time.Time
+}
+
+// NewLockout creates a lockout
+func NewLockout(threshold int, base, max time.Duration) *Lockout {
+	return &Lockout{
+		threshold: threshold,
+		base:      base,
+		max:       max,
+		clients:   make(map[string]*failures),
+		lastSweep: time.Now(),
+	}
+}
+
+// Locked returns how long key is still locked out, or zero
+func (l *Lockout) Locked(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.clients[key]
+	if !ok {
+		return 0
+	}
+	return max(0, time.Until(f.lockedUntil))
+}
+
+// Fail records a failure of key and returns how long it is locked out as
+// a result, or zero
+func (l *Lockout) Fail(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	f, ok := l.clients[key]
+	if !ok || now.Sub(f.last) > l.max {
+		f = &failures{}
+		l.clients[key] = f
+	}
+	f.count++
+	f.last = now
+
+	if f.count < l.threshold {
+		return 0
+	}
+
+	lockout := l.base
+	for i := l.threshold; i < f.count && lockout < l.max; i++ {
+		lockout *= 2
+	}
+	lockout = min(lockout, l.max)
+	f.lockedUntil = now.Add(lockout)
+
+	return lockout
+}
+
+// sweep forgets clients whose failures have expired
+func (l *Lockout) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, f := range l.clients {
+		if now.Sub(f.last) > l.max && now.After(f.lockedUntil) {
+			delete(l.clients, key)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Package tasksync runs task sync on request, on a schedule and after
+// changes made through the API, and remembers the outcome of the last run.
+package tasksync
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+)
+
+// Triggers of a sync
+const (
+	TriggerAPI      = "api"
+	TriggerSchedule = "schedule"
+	TriggerChange   = "change"
+)
+
+// Results of a sync
+const (
+	ResultSuccess = "success"
+	ResultFailed  = "failed"
+)
+
+// Status describes the last sync
+type Status struct {
+	Running    bool       `json:"running"`
+	LastRun    *time.Time `json:"last_run,omitempty"`
+	Duration   string     `json:"duration,omitempty"`
+	Trigger    string     `json:"trigger,omitempty"`
+	Result     string     `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Output     string     `json:"output,omitempty"`
+	Stderr     string     `json:"stderr,omitempty"`
+	Uploaded   *int       `json:"uploaded,omitempty"`
+	Downloaded *int       `json:"downloaded,omitempty"`
+}
+
+// Options holds the background sync schedule
+type Options struct {
+	// Interval between scheduled syncs, or 0 to disable them
+	Interval time.Duration
+	// OnChange syncs after changes made through the API, once no further
+	// change has arrived for Debounce
+	OnChange bool
+	Debounce time.Duration
+}
+
+// Syncer runs task sync for one tenant
+type Syncer struct {
+	client *taskwarrior.Client
+	opts   Options
+
+	// runMu is held while a sync runs
+	runMu sync.Mutex
+
+	mu      sync.Mutex
+	status  Status
+	pending *time.Timer
+	unsub   func()
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// New creates a syncer for client
+func New(client *taskwarrior.Client, opts Options) *Syncer {
+	return &Syncer{
+		client: client,
+		opts:   opts,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start begins scheduled syncs and, if enabled, listens for changes on bus
+func (s *Syncer) Start(bus *events.Bus) {
+	if s.opts.OnChange {
+		s.unsub = bus.Subscribe(s.handleEvent)
+	}
+	go s.run()
+}
+
+// Stop stops scheduling syncs and waits for a running one
+func (s *Syncer) Stop() {
+	if s.unsub != nil {
+		s.unsub()
+	}
+
+	s.mu.Lock()
+	if s.pending != nil {
+		s.pending.Stop()
+	}
+	s.mu.Unlock()
+
+	close(s.stop)
+	<-s.done
+
+	s.runMu.Lock()
+	s.runMu.Unlock()
+}
+
+// Status returns the outcome of the last sync
+func (s *Syncer) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Sync runs task sync, waiting for a sync that is already running to
+// finish first, and returns its outcome
+func (s *Syncer) Sync(ctx context.Context, trigger string) (Status, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	s.mu.Lock()
+	s.status.Running = true
+	s.mu.Unlock()
+
+	start := time.Now()
+	result, err := s.client.WithContext(ctx).Sync()
+
+	status := Status{
+		LastRun:    &start,
+		Duration:   time.Since(start).Round(time.Millisecond).String(),
+		Trigger:    trigger,
+		Result:     ResultSuccess,
+		Output:     result.Output,
+		Stderr:     result.Stderr,
+		Uploaded:   result.Uploaded,
+		Downloaded: result.Downloaded,
+	}
+	if err != nil {
+		status.Result = ResultFailed
+		status.Error = err.Error()
+		slog.Warn("Sync failed", "trigger", trigger, "error", err, "stderr", result.Stderr)
+	} else {
+		slog.Info("Sync finished", "trigger", trigger, "duration", status.Duration)
+	}
+
+	s.mu.Lock()
+	s.status = status
+	s.mu.Unlock()
+
+	return status, err
+}
+
+// run runs scheduled syncs
+func (s *Syncer) run() {
+	defer close(s.done)
+
+	if s.opts.Interval <= 0 {
+		<-s.stop
+		return
+	}
+
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.Sync(context.Background(), TriggerSchedule)
+		}
+	}
+}
+
+// handleEvent schedules a sync once changes made through the API have
+// settled. Changes brought in by sync itself are external and ignored.
+func (s *Syncer) handleEvent(event events.Event) {
+	if event.Source != events.SourceAPI {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending != nil {
+		s.pending.Stop()
+	}
+	s.pending = time.AfterFunc(s.opts.Debounce, func() {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		s.Sync(context.Background(), TriggerChange)
+	})
+}
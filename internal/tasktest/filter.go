@@ -0,0 +1,307 @@
+package tasktest
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type matcher func(task map[string]any) bool
+
+// parseFilter parses filter arguments into a matcher. Terms next to each
+// other are joined by and, which binds tighter than xor and or.
+func parseFilter(args []string) (matcher, error) {
+	var tokens []string
+	for _, arg := range args {
+		for strings.HasPrefix(arg, "(") {
+			tokens = append(tokens, "(")
+			arg = arg[1:]
+		}
+		closing := 0
+		for strings.HasSuffix(arg, ")") {
+			closing++
+			arg = arg[:len(arg)-1]
+		}
+		if arg != "" {
+			tokens = append(tokens, arg)
+		}
+		for range closing {
+			tokens = append(tokens, ")")
+		}
+	}
+	if len(tokens) == 0 {
+		return func(map[string]any) bool { return true }, nil
+	}
+
+	p := &filterParser{tokens: tokens}
+	m, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter", p.tokens[p.pos])
+	}
+	return m, nil
+}
+
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *filterParser) or() (matcher, error) {
+	left, err := p.xor()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" {
+		p.pos++
+		right, err := p.xor()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(task map[string]any) bool { return l(task) || right(task) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) xor() (matcher, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "xor" {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(task map[string]any) bool { return l(task) != right(task) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) and() (matcher, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek() {
+		case "", ")", "or", "xor":
+			return left, nil
+		case "and":
+			p.pos++
+		}
+		right, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(task map[string]any) bool { return l(task) && right(task) }
+	}
+}
+
+func (p *filterParser) primary() (matcher, error) {
+	token := p.peek()
+	switch token {
+	case "":
+		return nil, fmt.Errorf("filter ends early")
+	case "(":
+		p.pos++
+		m, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("unbalanced parentheses in filter")
+		}
+		p.pos++
+		return m, nil
+	case ")", "and", "or", "xor":
+		return nil, fmt.Errorf("unexpected %q in filter", token)
+	}
+	p.pos++
+	if isUUID(token) {
+		// Like Taskwarrior, a run of UUIDs matches any of them
+		uuids := map[string]bool{token: true}
+		for isUUID(p.peek()) {
+			uuids[p.peek()] = true
+			p.pos++
+		}
+		return func(task map[string]any) bool {
+			uuid, _ := task["uuid"].(string)
+			return uuids[uuid]
+		}, nil
+	}
+	return parseTerm(token)
+}
+
+func parseTerm(term string) (matcher, error) {
+	if tag, ok := strings.CutPrefix(term, "+"); ok {
+		return tagMatcher(tag, true)
+	}
+	if tag, ok := strings.CutPrefix(term, "-"); ok {
+		return tagMatcher(tag, false)
+	}
+
+	name, value, ok := strings.Cut(term, ":")
+	if !ok {
+		return nil, fmt.Errorf("unsupported filter term %q", term)
+	}
+	attr, modifier, _ := strings.Cut(name, ".")
+
+	switch attr {
+	case "due", "wait", "scheduled", "entry", "modified", "end", "start":
+		return dateMatcher(attr, modifier, value)
+	case "status", "project", "priority", "description", "uuid", "recur", "parent":
+		return stringMatcher(attr, modifier, value)
+	}
+	return nil, fmt.Errorf("unsupported filter attribute %q", attr)
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if r != '-' {
+				return false
+			}
+		case !strings.ContainsRune("0123456789abcdef", r):
+			return false
+		}
+	}
+	return true
+}
+
+func tagMatcher(tag string, want bool) (matcher, error) {
+	if tag == "" {
+		return nil, fmt.Errorf("empty tag in filter")
+	}
+	if tag == strings.ToUpper(tag) && tag != strings.ToLower(tag) {
+		// Of the virtual tags, only those the tests need are known
+		switch tag {
+		case "ACTIVE":
+			return func(task map[string]any) bool { return (task["start"] != nil) == want }, nil
+		case "PENDING":
+			return func(task map[string]any) bool { return (task["status"] == "pending") == want }, nil
+		}
+		return nil, fmt.Errorf("unsupported virtual tag %q", tag)
+	}
+	return func(task map[string]any) bool {
+		return slices.Contains(stringList(task["tags"]), tag) == want
+	}, nil
+}
+
+func stringMatcher(attr, modifier, value string) (matcher, error) {
+	get := func(task map[string]any) string {
+		s, _ := task[attr].(string)
+		return s
+	}
+
+	switch modifier {
+	case "":
+		if attr == "project" {
+			return func(task map[string]any) bool {
+				project := get(task)
+				if value == "" {
+					return project == ""
+				}
+				return project == value || strings.HasPrefix(project, value+".")
+			}, nil
+		}
+		if attr == "description" {
+			return func(task map[string]any) bool { return strings.Contains(get(task), value) }, nil
+		}
+		return func(task map[string]any) bool { return get(task) == value }, nil
+	case "is", "equals":
+		return func(task map[string]any) bool { return get(task) == value }, nil
+	case "not", "isnt":
+		if attr == "project" && modifier == "not" {
+			return func(task map[string]any) bool {
+				project := get(task)
+				return project != value && !strings.HasPrefix(project, value+".")
+			}, nil
+		}
+		return func(task map[string]any) bool { return get(task) != value }, nil
+	case "has", "contains":
+		return func(task map[string]any) bool { return strings.Contains(get(task), value) }, nil
+	case "hasnt":
+		return func(task map[string]any) bool { return !strings.Contains(get(task), value) }, nil
+	case "startswith", "left":
+		return func(task map[string]any) bool { return strings.HasPrefix(get(task), value) }, nil
+	case "endswith", "right":
+		return func(task map[string]any) bool { return strings.HasSuffix(get(task), value) }, nil
+	case "none":
+		return func(task map[string]any) bool { return get(task) == "" }, nil
+	case "any":
+		return func(task map[string]any) bool { return get(task) != "" }, nil
+	}
+	return nil, fmt.Errorf("unsupported modifier %q", modifier)
+}
+
+func dateMatcher(attr, modifier, value string) (matcher, error) {
+	get := func(task map[string]any) (time.Time, bool) {
+		s, _ := task[attr].(string)
+		t, err := time.Parse(twTime, s)
+		return t, err == nil
+	}
+
+	switch modifier {
+	case "none":
+		return func(task map[string]any) bool { _, ok := get(task); return !ok }, nil
+	case "any":
+		return func(task map[string]any) bool { _, ok := get(task); return ok }, nil
+	}
+
+	ref, err := parseDate(value)
+	if err != nil {
+		return nil, err
+	}
+	compare := func(cmp func(t time.Time) bool) matcher {
+		return func(task map[string]any) bool {
+			t, ok := get(task)
+			return ok && cmp(t)
+		}
+	}
+
+	switch modifier {
+	case "", "is", "equals":
+		return compare(func(t time.Time) bool { return t.Equal(ref) }), nil
+	case "before", "below":
+		return compare(func(t time.Time) bool { return t.Before(ref) }), nil
+	case "after", "above":
+		return compare(func(t time.Time) bool { return t.After(ref) }), nil
+	case "by":
+		return compare(func(t time.Time) bool { return !t.After(ref) }), nil
+	}
+	return nil, fmt.Errorf("unsupported modifier %q", modifier)
+}
+
+// parseDate parses the absolute dates the tests use: epoch seconds, ISO
+// dates and times, and Taskwarrior's own format
+func parseDate(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	for _, layout := range []string{twTime, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported date %q", value)
+}
@@ -0,0 +1,496 @@
+// Package tasktest provides a fake task command for tests, so code that
+// runs Taskwarrior can be tested without Taskwarrior installed. The fake
+// is the test binary itself: Install puts a task script on PATH that runs
+// the test binary again, where Main takes over before any test runs.
+//
+// The fake keeps tasks in a JSON file in its data location and supports
+// the commands and filters the API uses: export, add, modify, done,
+// start, stop, delete, import, _version and _show, with filters made of UUIDs,
+// tags, status, project, priority and description terms joined by and,
+// or, xor and parentheses. Anything else fails, so a test notices when
+// the API starts relying on more.
+package tasktest
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// envFake marks a run of the test binary as the fake task command
+const envFake = "TASKTEST_FAKE_TASK"
+
+// Taskwarrior's date format in exports
+const twTime = "20060102T150405Z"
+
+// Main runs the fake task command instead of the tests when the test
+// binary was started by the task script. Call it first in TestMain.
+func Main() {
+	if os.Getenv(envFake) == "" {
+		return
+	}
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Fake is an installed fake task command
+type Fake struct {
+	tb  testing.TB
+	dir string
+}
+
+// Install puts the fake task command on PATH for the rest of the test,
+// holding tasks. Tasks are written as task export prints them, with
+// dates such as "20260101T120000Z".
+func Install(tb testing.TB, tasks []map[string]any) *Fake {
+	tb.Helper()
+
+	exe, err := os.Executable()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	bin := tb.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\n%s=1 exec '%s' \"$@\"\n", envFake, exe)
+	if err := os.WriteFile(filepath.Join(bin, "task"), []byte(script), 0o755); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	f := &Fake{tb: tb, dir: tb.TempDir()}
+	if tasks == nil {
+		tasks = []map[string]any{}
+	}
+	if err := saveTasks(f.dir, tasks); err != nil {
+		tb.Fatal(err)
+	}
+	return f
+}
+
+// DataLocation is the data location to pass to the client
+func (f *Fake) DataLocation() string {
+	return f.dir
+}
+
+// Tasks returns the stored tasks
+func (f *Fake) Tasks() []map[string]any {
+	f.tb.Helper()
+
+	tasks, err := loadTasks(f.dir)
+	if err != nil {
+		f.tb.Fatal(err)
+	}
+	return tasks
+}
+
+// Task returns the stored task with uuid, or nil
+func (f *Fake) Task(uuid string) map[string]any {
+	f.tb.Helper()
+
+	for _, task := range f.Tasks() {
+		if task["uuid"] == uuid {
+			return task
+		}
+	}
+	return nil
+}
+
+// Calls returns the arguments of every command run so far, without the
+// rc overrides
+func (f *Fake) Calls() [][]string {
+	f.tb.Helper()
+
+	data, err := os.ReadFile(filepath.Join(f.dir, "calls.log"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		f.tb.Fatal(err)
+	}
+
+	var calls [][]string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var call []string
+		if err := json.Unmarshal([]byte(line), &call); err != nil {
+			f.tb.Fatal(err)
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// CallsOf returns the calls running command
+func (f *Fake) CallsOf(command string) [][]string {
+	var calls [][]string
+	for _, call := range f.Calls() {
+		if _, cmd, _ := splitCommand(call); cmd == command {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// ResetCalls forgets the calls so far
+func (f *Fake) ResetCalls() {
+	f.tb.Helper()
+
+	if err := os.Remove(filepath.Join(f.dir, "calls.log")); err != nil && !os.IsNotExist(err) {
+		f.tb.Fatal(err)
+	}
+}
+
+// Fail makes command fail, or succeed again when fail is false
+func (f *Fake) Fail(command string, fail bool) {
+	f.tb.Helper()
+
+	path := filepath.Join(f.dir, "fail-"+command)
+	var err error
+	if fail {
+		err = os.WriteFile(path, nil, 0o644)
+	} else if err = os.Remove(path); os.IsNotExist(err) {
+		err = nil
+	}
+	if err != nil {
+		f.tb.Fatal(err)
+	}
+}
+
+// FailAfter makes command succeed n more times and fail after that
+func (f *Fake) FailAfter(command string, n int) {
+	f.tb.Helper()
+
+	path := filepath.Join(f.dir, "fail-"+command)
+	if err := os.WriteFile(path, []byte(strconv.Itoa(n)), 0o644); err != nil {
+		f.tb.Fatal(err)
+	}
+}
+
+// SetShow sets the output of task _show
+func (f *Fake) SetShow(output string) {
+	f.tb.Helper()
+
+	if err := os.WriteFile(filepath.Join(f.dir, "show"), []byte(output), 0o644); err != nil {
+		f.tb.Fatal(err)
+	}
+}
+
+var commands = map[string]bool{
+	"export": true, "add": true, "modify": true, "done": true, "start": true,
+	"stop": true, "delete": true, "import": true, "_version": true, "_show": true,
+}
+
+// splitCommand splits arguments into the filter, the command and the
+// arguments of the command
+func splitCommand(words []string) (filter []string, command string, args []string) {
+	for i, word := range words {
+		if commands[word] {
+			return words[:i], word, words[i+1:]
+		}
+	}
+	return words, "", nil
+}
+
+func run(argv []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	dir := ""
+	var words []string
+	for _, arg := range argv {
+		switch {
+		case strings.HasPrefix(arg, "rc.data.location="):
+			dir = strings.TrimPrefix(arg, "rc.data.location=")
+		case strings.HasPrefix(arg, "rc.") || strings.HasPrefix(arg, "rc:"):
+		default:
+			words = append(words, arg)
+		}
+	}
+	if dir == "" {
+		fmt.Fprintln(stderr, "fake task: no rc.data.location")
+		return 2
+	}
+
+	if err := logCall(dir, words); err != nil {
+		fmt.Fprintln(stderr, "fake task:", err)
+		return 2
+	}
+
+	filterArgs, command, args := splitCommand(words)
+	if command == "" {
+		fmt.Fprintf(stderr, "fake task: unsupported command in %q\n", words)
+		return 2
+	}
+	if shouldFail(dir, command) {
+		fmt.Fprintf(stderr, "fake task: %s failed\n", command)
+		return 1
+	}
+
+	switch command {
+	case "_version":
+		fmt.Fprintln(stdout, "3.1.0")
+		return 0
+	case "_show":
+		data, _ := os.ReadFile(filepath.Join(dir, "show"))
+		stdout.Write(data)
+		return 0
+	}
+
+	tasks, err := loadTasks(dir)
+	if err != nil {
+		fmt.Fprintln(stderr, "fake task:", err)
+		return 2
+	}
+	match, err := parseFilter(filterArgs)
+	if err != nil {
+		fmt.Fprintln(stderr, "fake task:", err)
+		return 2
+	}
+
+	if command == "export" {
+		exported := []map[string]any{}
+		id := 0
+		for _, task := range tasks {
+			if task["status"] == "pending" {
+				id++
+				task["id"] = id
+			}
+			if match(task) {
+				exported = append(exported, task)
+			}
+		}
+		data, _ := json.Marshal(exported)
+		fmt.Fprintf(stdout, "%s\n", data)
+		return 0
+	}
+
+	if command == "import" {
+		return importTasks(dir, tasks, stdin, stdout, stderr)
+	}
+
+	now := time.Now().UTC().Format(twTime)
+	if command == "add" {
+		task := map[string]any{
+			"uuid":   newUUID(),
+			"status": "pending",
+			"entry":  now,
+		}
+		if err := modify(task, args); err != nil {
+			fmt.Fprintln(stderr, "fake task:", err)
+			return 2
+		}
+		task["modified"] = now
+		tasks = append(tasks, task)
+		if err := saveTasks(dir, tasks); err != nil {
+			fmt.Fprintln(stderr, "fake task:", err)
+			return 2
+		}
+		fmt.Fprintf(stdout, "Created task %s.\n", task["uuid"])
+		return 0
+	}
+
+	if len(filterArgs) == 0 {
+		fmt.Fprintln(stderr, "Command prevented from running.")
+		return 1
+	}
+	changed := 0
+	for _, task := range tasks {
+		if !match(task) {
+			continue
+		}
+		changed++
+		switch command {
+		case "modify":
+			err = modify(task, args)
+		case "done":
+			task["status"] = "completed"
+			task["end"] = now
+			delete(task, "start")
+		case "start":
+			task["start"] = now
+		case "stop":
+			delete(task, "start")
+		case "delete":
+			task["status"] = "deleted"
+			task["end"] = now
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, "fake task:", err)
+			return 2
+		}
+		task["modified"] = now
+	}
+	if changed == 0 {
+		fmt.Fprintln(stderr, "No tasks specified.")
+		return 1
+	}
+	if err := saveTasks(dir, tasks); err != nil {
+		fmt.Fprintln(stderr, "fake task:", err)
+		return 2
+	}
+	fmt.Fprintf(stdout, "Modified %d task.\n", changed)
+	return 0
+}
+
+// shouldFail reports whether command is set to fail, counting down the
+// runs left before it does
+func shouldFail(dir, command string) bool {
+	path := filepath.Join(dir, "fail-"+command)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	left, err := strconv.Atoi(string(data))
+	if err != nil || left <= 0 {
+		return true
+	}
+	os.WriteFile(path, []byte(strconv.Itoa(left-1)), 0o644)
+	return false
+}
+
+// importTasks adds the tasks in the JSON array on stdin, replacing tasks
+// with the same UUID
+func importTasks(dir string, tasks []map[string]any, stdin io.Reader, stdout, stderr io.Writer) int {
+	var imported []map[string]any
+	if err := json.NewDecoder(stdin).Decode(&imported); err != nil {
+		fmt.Fprintln(stderr, "fake task: invalid import:", err)
+		return 2
+	}
+
+	for _, task := range imported {
+		i := slices.IndexFunc(tasks, func(t map[string]any) bool { return t["uuid"] == task["uuid"] })
+		if i < 0 {
+			tasks = append(tasks, task)
+		} else {
+			tasks[i] = task
+		}
+	}
+	if err := saveTasks(dir, tasks); err != nil {
+		fmt.Fprintln(stderr, "fake task:", err)
+		return 2
+	}
+	fmt.Fprintf(stdout, "Imported %d tasks.\n", len(imported))
+	return 0
+}
+
+func logCall(dir string, words []string) error {
+	data, err := json.Marshal(words)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "calls.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func loadTasks(dir string) ([]map[string]any, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "tasks.json"))
+	if err != nil {
+		return nil, err
+	}
+	var tasks []map[string]any
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func saveTasks(dir string, tasks []map[string]any) error {
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "tasks.json"), data, 0o644)
+}
+
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// modify applies the arguments of add or modify to task
+func modify(task map[string]any, args []string) error {
+	var description []string
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "+"):
+			task["tags"] = appendUnique(stringList(task["tags"]), arg[1:])
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			tags := stringList(task["tags"])
+			kept := []string{}
+			for _, tag := range tags {
+				if tag != arg[1:] {
+					kept = append(kept, tag)
+				}
+			}
+			task["tags"] = kept
+		case strings.Contains(arg, ":") && !strings.Contains(arg, " "):
+			name, value, _ := strings.Cut(arg, ":")
+			switch name {
+			case "project", "priority", "recur":
+				setOrDelete(task, name, value)
+			case "due", "wait", "scheduled":
+				if value == "" {
+					delete(task, name)
+					continue
+				}
+				t, err := time.Parse("2006-01-02T15:04:05", value)
+				if err != nil {
+					return fmt.Errorf("invalid %s %q", name, value)
+				}
+				task[name] = t.UTC().Format(twTime)
+			case "depends":
+				task["depends"] = appendUnique(stringList(task["depends"]), value)
+			default:
+				description = append(description, arg)
+			}
+		default:
+			description = append(description, arg)
+		}
+	}
+	if len(description) > 0 {
+		task["description"] = strings.Join(description, " ")
+	}
+	return nil
+}
+
+func setOrDelete(task map[string]any, name, value string) {
+	if value == "" {
+		delete(task, name)
+		return
+	}
+	task[name] = value
+}
+
+func stringList(value any) []string {
+	var result []string
+	switch v := value.(type) {
+	case []string:
+		result = append(result, v...)
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, item := range list {
+		if item == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
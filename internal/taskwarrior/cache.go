@@ -0,0 +1,198 @@
+package taskwarrior
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+// CacheStats holds counters describing cache effectiveness
+type CacheStats struct {
+	Hits     uint64    `json:"hits"`
+	Misses   uint64    `json:"misses"`
+	Reloads  uint64    `json:"reloads"`
+	Tasks    int       `json:"tasks"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// Cache is an in-memory index of all tasks, filled from a single full
+// export. It is invalidated after the client's own writes and whenever the
+// data files change, and reloaded lazily on the next read.
+type Cache struct {
+	load func() ([]Task, error)
+
+	mu         sync.RWMutex
+	tasks      []Task
+	byUUID     map[string]int
+	valid      bool
+	generation uint64
+	loadedAt   time.Time
+
+	// loadMu ensures concurrent readers share a single reload
+	loadMu sync.Mutex
+
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+	reloads atomic.Uint64
+}
+
+// newCache creates a cache that loads tasks with load
+func newCache(load func() ([]Task, error)) *Cache {
+	return &Cache{
+		load:   load,
+		byUUID: make(map[string]int),
+	}
+}
+
+// Invalidate marks the index as stale
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.valid = false
+	c.generation++
+}
+
+// Stats returns cache counters
+func (c *Cache) Stats() CacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return CacheStats{
+		Hits:     c.hits.Load(),
+		Misses:   c.misses.Load(),
+		Reloads:  c.reloads.Load(),
+		Tasks:    len(c.tasks),
+		LoadedAt: c.loadedAt,
+	}
+}
+
+// Filter returns the tasks matching filter, reloading the index first if
+// it is stale
+func (c *Cache) Filter(filter TaskFilter) ([]Task, error) {
+	if err := c.ensureLoaded(); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if filter.UUID != "" {
+		i, ok := c.byUUID[filter.UUID]
+		if !ok {
+			return []Task{}, nil
+		}
+		return FilterTasks(c.tasks[i:i+1], filter), nil
+	}
+
+	return FilterTasks(c.tasks, filter), nil
+}
+
+func (c *Cache) ensureLoaded() error {
+	c.mu.RLock()
+	valid := c.valid
+	c.mu.RUnlock()
+	if valid {
+		c.hits.Add(1)
+		return nil
+	}
+
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+
+	// Another reader may have reloaded while we waited
+	c.mu.RLock()
+	valid = c.valid
+	generation := c.generation
+	c.mu.RUnlock()
+	if valid {
+		c.hits.Add(1)
+		return nil
+	}
+
+	c.misses.Add(1)
+	tasks, err := c.load()
+	if err != nil {
+		return err
+	}
+	c.reloads.Add(1)
+
+	byUUID := make(map[string]int, len(tasks))
+	for i, task := range tasks {
+		byUUID[task.UUID] = i
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tasks = tasks
+	c.byUUID = byUUID
+	c.loadedAt = time.Now()
+	// A write that happened while exporting leaves the index stale, so the
+	// next read reloads again
+	c.valid = c.generation == generation
+
+	return nil
+}
+
+// parseSimpleFilter converts Taskwarrior filter arguments into a TaskFilter
+// when they only use status, project, tag and UUID terms that the index
+// can answer. The boolean is false for anything else, in which case the
+// query has to go to the task binary.
+func parseSimpleFilter(args []string) (TaskFilter, bool) {
+	var filter TaskFilter
+
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "status:"):
+			status := strings.TrimPrefix(arg, "status:")
+			if filter.Status != "" || !isStoredStatus(status) {
+				return TaskFilter{}, false
+			}
+			filter.Status = status
+		case strings.HasPrefix(arg, "project:"):
+			project := strings.TrimPrefix(arg, "project:")
+			if filter.Project != "" || project == "" {
+				return TaskFilter{}, false
+			}
+			filter.Project = project
+		case strings.HasPrefix(arg, "+"):
+			tag := strings.TrimPrefix(arg, "+")
+			// Upper-case tags such as +ACTIVE or +OVERDUE are virtual tags
+			// computed by Taskwarrior
+			if tag == "" || isVirtualTag(tag) {
+				return TaskFilter{}, false
+			}
+			filter.Tags = append(filter.Tags, tag)
+		case ValidateTaskUUID(arg):
+			if filter.UUID != "" {
+				return TaskFilter{}, false
+			}
+			filter.UUID = arg
+		default:
+			return TaskFilter{}, false
+		}
+	}
+
+	return filter, true
+}
+
+func isStoredStatus(status string) bool {
+	switch status {
+	case StatusPending, StatusCompleted, StatusDeleted, StatusRecurring:
+		return true
+	default:
+		return false
+	}
+}
+
+func isVirtualTag(tag string) bool {
+	for _, r := range tag {
+		if unicode.IsLower(r) {
+			return false
+		}
+	}
+	return true
+}
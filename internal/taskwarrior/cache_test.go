@@ -0,0 +1,218 @@
+package taskwarrior
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// benchTaskCount is the size of the fixture the benchmarks query, a large
+// but realistic task list
+const benchTaskCount = 3000
+
+// benchTasks returns a fixture of n tasks in the format of task export,
+// spread over statuses, projects and tags
+func benchTasks(n int) []map[string]any {
+	statuses := []string{StatusPending, StatusPending, StatusPending, StatusCompleted, StatusDeleted}
+	projects := []string{"work", "work.backend", "home", "errands", ""}
+	tags := [][]string{{"next"}, {"next", "urgent"}, {"review"}, nil, {"someday"}}
+
+	tasks := make([]map[string]any, n)
+	for i := range tasks {
+		task := map[string]any{
+			"uuid":        fmt.Sprintf("%08x-0000-4000-8000-%012x", i, i),
+			"description": fmt.Sprintf("Task number %d", i),
+			"status":      statuses[i%len(statuses)],
+			"entry":       "20260101T120000Z",
+			"modified":    "20260102T083000Z",
+			"urgency":     float64(i%20) / 2,
+		}
+		if project := projects[i%len(projects)]; project != "" {
+			task["project"] = project
+		}
+		if tagSet := tags[(i/len(statuses))%len(tags)]; tagSet != nil {
+			task["tags"] = tagSet
+		}
+		if task["status"] == StatusPending {
+			task["id"] = i + 1
+		}
+		tasks[i] = task
+	}
+	return tasks
+}
+
+// fakeExport puts a task script on PATH that prints the file it returns.
+// The file starts out holding all of tasks; write replaces it with the
+// tasks matching filter, standing in for Taskwarrior's own filtering.
+func fakeExport(tb testing.TB, raw []map[string]any) (write func(filter TaskFilter)) {
+	tb.Helper()
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var tasks []Task
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		tb.Fatal(err)
+	}
+
+	dir := tb.TempDir()
+	output := filepath.Join(dir, "export.json")
+	script := "#!/bin/sh\nexec cat " + output + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "task"), []byte(script), 0o755); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	write = func(filter TaskFilter) {
+		var matching []map[string]any
+		for i, task := range tasks {
+			if matchesFilter(task, filter) {
+				matching = append(matching, raw[i])
+			}
+		}
+		data, err := json.Marshal(matching)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(output, data, 0o644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	write(TaskFilter{})
+	return write
+}
+
+// benchQueries are filters the index can answer, as the handlers send them
+var benchQueries = []struct {
+	name string
+	args []string
+}{
+	{"uuid", []string{"00000007-0000-4000-8000-000000000007"}},
+	{"status", []string{"status:pending"}},
+	{"project", []string{"status:pending", "project:work"}},
+	{"tags", []string{"status:pending", "+next", "+urgent"}},
+}
+
+// BenchmarkExport compares answering filters from the index with running
+// task export for them
+func BenchmarkExport(b *testing.B) {
+	raw := benchTasks(benchTaskCount)
+
+	for _, q := range benchQueries {
+		filter, ok := parseSimpleFilter(q.args)
+		if !ok {
+			b.Fatalf("%v cannot be answered from the index", q.args)
+		}
+
+		b.Run("uncached/"+q.name, func(b *testing.B) {
+			write := fakeExport(b, raw)
+			write(filter)
+			client := NewClient(b.TempDir(), "")
+
+			for b.Loop() {
+				if _, err := client.exportCommand(q.args, ""); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run("cached/"+q.name, func(b *testing.B) {
+			write := fakeExport(b, raw)
+			client := NewClient(b.TempDir(), "")
+			client.cache = newCache(func() ([]Task, error) {
+				return client.exportCommand(nil, "")
+			})
+
+			// The uncached export of the same filter must find the same
+			// tasks, or the comparison is meaningless
+			cached, err := client.Export(q.args...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			write(filter)
+			exported, err := client.exportCommand(q.args, "")
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(cached) != len(exported) {
+				b.Fatalf("index found %d tasks, export %d", len(cached), len(exported))
+			}
+
+			for b.Loop() {
+				if _, err := client.Export(q.args...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkParseSimpleFilter measures deciding whether the index can
+// answer a query, which every export pays before either path
+func BenchmarkParseSimpleFilter(b *testing.B) {
+	for _, q := range benchQueries {
+		b.Run(q.name, func(b *testing.B) {
+			for b.Loop() {
+				parseSimpleFilter(q.args)
+			}
+		})
+	}
+
+	b.Run("rejected", func(b *testing.B) {
+		args := []string{"status:pending", "due.before:tomorrow"}
+		for b.Loop() {
+			parseSimpleFilter(args)
+		}
+	})
+}
+
+// BenchmarkCacheInvalidation measures reads after writes, each of which
+// invalidates the index
+func BenchmarkCacheInvalidation(b *testing.B) {
+	raw := benchTasks(benchTaskCount)
+
+	// Every read follows a write, so every read reloads the whole index
+	b.Run("reload", func(b *testing.B) {
+		fakeExport(b, raw)
+		client := NewClient(b.TempDir(), "")
+		cache := newCache(func() ([]Task, error) {
+			return client.exportCommand(nil, "")
+		})
+
+		for b.Loop() {
+			cache.Invalidate()
+			if _, err := cache.Filter(TaskFilter{Status: StatusPending}); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if stats := cache.Stats(); stats.Reloads != stats.Misses {
+			b.Fatalf("%d misses but %d reloads", stats.Misses, stats.Reloads)
+		}
+	})
+
+	// Concurrent readers with one write every 100 reads share the reloads
+	b.Run("parallel", func(b *testing.B) {
+		fakeExport(b, raw)
+		client := NewClient(b.TempDir(), "")
+		cache := newCache(func() ([]Task, error) {
+			return client.exportCommand(nil, "")
+		})
+
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				if i%100 == 0 {
+					cache.Invalidate()
+				}
+				if _, err := cache.Filter(TaskFilter{Status: StatusPending, Tags: []string{"next"}}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		stats := cache.Stats()
+		b.ReportMetric(float64(stats.Reloads)/float64(b.N), "reloads/op")
+	})
+}
@@ -2,18 +2,51 @@ package taskwarrior
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/dotbinio/taskwarrior-api/internal/logging"
+	"github.com/dotbinio/taskwarrior-api/internal/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Client wraps the Taskwarrior CLI
 type Client struct {
 	dataLocation   string
 	taskrcLocation string
+	cache          *Cache
+	// recordCommand, when set, receives the argv of every command that
+	// changes tasks
+	recordCommand func(argv []string)
+	// logger, when set, logs the commands run for a request
+	logger *slog.Logger
+	// ctx, when set, holds the span commands are traced under
+	ctx context.Context
+	// version and adapter are set by Detect
+	version Version
+	adapter adapter
+	// writeMu serializes commands that change tasks, including sync. It
+	// is shared by all copies of the client.
+	writeMu *sync.Mutex
+	// writeLocked is set on the copy CheckedWrite hands to its write
+	// function, which runs with writeMu already held
+	writeLocked bool
+	// expectWrite, when set, receives the UUIDs of the tasks a write is
+	// about to change, with writeMu held
+	expectWrite func(uuids []string)
 }
 
 // NewClient creates a new Taskwarrior client
@@ -21,6 +54,117 @@ func NewClient(dataLocation, taskrcLocation string) *Client {
 	return &Client{
 		dataLocation:   dataLocation,
 		taskrcLocation: taskrcLocation,
+		writeMu:        &sync.Mutex{},
+	}
+}
+
+// EnableCache serves simple queries from an in-memory index of all tasks.
+// The index is invalidated after every write made through the client and
+// whenever files reports a change to the data files.
+func (c *Client) EnableCache(files *FileWatcher) *Cache {
+	c.cache = newCache(func() ([]Task, error) {
+		return c.exportCommand(nil, "")
+	})
+	files.OnChange(c.cache.Invalidate)
+	return c.cache
+}
+
+// Cache returns the task index, or nil when caching is disabled
+func (c *Client) Cache() *Cache {
+	return c.cache
+}
+
+// WithCommandRecorder returns a copy of the client sharing its cache that
+// passes the argv of every command changing tasks to record
+func (c *Client) WithCommandRecorder(record func(argv []string)) *Client {
+	clone := *c
+	clone.recordCommand = record
+	return &clone
+}
+
+// WithLogger returns a copy of the client sharing its cache that logs to
+// logger, e.g. to tag commands with the ID of the request running them
+func (c *Client) WithLogger(logger *slog.Logger) *Client {
+	clone := *c
+	clone.logger = logger
+	return &clone
+}
+
+// WithContext returns a copy of the client sharing its cache whose
+// commands are traced as children of the span in ctx
+func (c *Client) WithContext(ctx context.Context) *Client {
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
+
+// WithWriteObserver returns a copy of the client sharing its cache that
+// passes expect the UUIDs of the tasks each write is about to change. It
+// is called with the write lock held, before the write for tasks that
+// exist and before the lock is released for new ones, so a reader holding
+// the lock through Locked never sees a change it was not told about.
+func (c *Client) WithWriteObserver(expect func(uuids []string)) *Client {
+	clone := *c
+	clone.expectWrite = expect
+	return &clone
+}
+
+// Locked runs fn while holding the write lock, so no write through any
+// copy of the client runs at the same time. fn must use the client it is
+// given.
+func (c *Client) Locked(fn func(locked *Client) error) error {
+	return c.write(nil, fn)
+}
+
+// write runs fn with the write lock held, after passing uuids to the
+// write observer
+func (c *Client) write(uuids []string, fn func(locked *Client) error) error {
+	locked := c
+	if !c.writeLocked {
+		c.writeMu.Lock()
+		defer c.writeMu.Unlock()
+
+		clone := *c
+		clone.writeLocked = true
+		locked = &clone
+	}
+
+	locked.expect(uuids...)
+	return fn(locked)
+}
+
+// expect passes uuids to the write observer
+func (c *Client) expect(uuids ...string) {
+	if c.expectWrite != nil && len(uuids) > 0 {
+		c.expectWrite(uuids)
+	}
+}
+
+// CheckedWrite reads the task with uuid and passes it, or the error
+// reading it, to check while holding the write lock. Only if check returns
+// nil does write run, before the lock is released, so no other write can
+// change the task between the check and the write. write must use the
+// client it is given. The error is check's or write's.
+func (c *Client) CheckedWrite(uuid string, check func(task *Task, err error) error, write func(locked *Client) error) error {
+	return c.write(nil, func(locked *Client) error {
+		if err := check(locked.GetByUUID(uuid)); err != nil {
+			return err
+		}
+		return write(locked)
+	})
+}
+
+func (c *Client) log() *slog.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+	return slog.Default()
+}
+
+// invalidateCache marks the task index as stale after a write
+func (c *Client) invalidateCache() {
+	if c.cache != nil {
+		c.cache.Invalidate()
 	}
 }
 
@@ -30,6 +174,19 @@ func (c *Client) Export(filters ...string) ([]Task, error) {
 
 // ExportReport retrieves all tasks with the provided report and matching the filter as JSON
 func (c *Client) ExportReport(filters []string, report string) ([]Task, error) {
+	// Reports apply their own filters and sorting, so only plain exports
+	// can be answered from the index
+	if c.cache != nil && report == "" {
+		if filter, ok := parseSimpleFilter(filters); ok {
+			return c.cache.Filter(filter)
+		}
+	}
+
+	return c.exportCommand(filters, report)
+}
+
+// exportCommand runs task export
+func (c *Client) exportCommand(filters []string, report string) ([]Task, error) {
 	args := []string{}
 
 	// Add filter arguments
@@ -37,13 +194,14 @@ func (c *Client) ExportReport(filters []string, report string) ([]Task, error) {
 
 	args = append(args, "export")
 
-	// TODO: validation for report
-	if report != "" {
-		args = append(args, report)
+	reportArgs, err := c.reportArgs(report)
+	if err != nil {
+		return nil, err
 	}
+	args = append(args, reportArgs...)
 
 	cmd := c.buildCommand(args...)
-	output, err := cmd.Output()
+	output, err := c.output(cmd)
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			return nil, fmt.Errorf("task export failed: %s", string(exitErr.Stderr))
@@ -57,13 +215,25 @@ func (c *Client) ExportReport(filters []string, report string) ([]Task, error) {
 	}
 
 	if err := json.Unmarshal(output, &tasks); err != nil {
-		log.Printf("Failed to parse JSON output: %s", string(output))
+		// The output itself is task data and is not logged
+		c.log().Error("Failed to parse task export", "bytes", len(output), "error", err)
 		return nil, fmt.Errorf("failed to parse task export: %w", err)
 	}
 
 	return tasks, nil
 }
 
+// ExportModifiedSince retrieves all tasks, including deleted ones, whose
+// modification time is at or after since
+func (c *Client) ExportModifiedSince(since time.Time) ([]Task, error) {
+	// Taskwarrior compares with a strict "after", so step back one second
+	// to include tasks modified within the same second as since
+	return c.Export(fmt.Sprintf("modified.after:%d", since.Unix()-1))
+}
+
+// ErrTaskNotFound is returned by GetByUUID when no task has the UUID
+var ErrTaskNotFound = errors.New("task not found")
+
 // GetByUUID retrieves a single task by UUID
 func (c *Client) GetByUUID(uuid string) (*Task, error) {
 	tasks, err := c.Export(uuid)
@@ -72,7 +242,7 @@ func (c *Client) GetByUUID(uuid string) (*Task, error) {
 	}
 
 	if len(tasks) == 0 {
-		return nil, fmt.Errorf("task not found: %s", uuid)
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, uuid)
 	}
 
 	return &tasks[0], nil
@@ -80,7 +250,10 @@ func (c *Client) GetByUUID(uuid string) (*Task, error) {
 
 // Add creates a new task
 func (c *Client) Add(task TaskCreate) (string, error) {
-	args := []string{"add"}
+	defer c.invalidateCache()
+
+	// Print the UUID of the new task rather than its ID
+	args := []string{"rc.verbose=new-uuid", "add"}
 
 	// Description is required
 	args = append(args, task.Description)
@@ -120,26 +293,87 @@ func (c *Client) Add(task TaskCreate) (string, error) {
 		args = append(args, fmt.Sprintf("depends:%s", dep))
 	}
 
-	cmd := c.buildCommand(args...)
+	cmd := c.buildWriteCommand(args...)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	output, err := cmd.Output()
+	// The UUID is only known once the task exists, so it is passed to the
+	// write observer before the lock is released
+	var uuid string
+	err := c.write(nil, func(locked *Client) error {
+		output, err := locked.output(cmd)
+		if err != nil {
+			return fmt.Errorf("task add failed: %s", stderr.String())
+		}
+
+		// Extract UUID from output
+		uuid, err = locked.createdUUID(string(output))
+		if err != nil {
+			return err
+		}
+		locked.expect(uuid)
+		return nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("task add failed: %s", stderr.String())
+		return "", err
 	}
 
-	// Extract UUID from output
-	uuid := c.extractUUIDFromOutput(string(output))
-	if uuid == "" {
-		return "", fmt.Errorf("failed to extract UUID from task add output")
+	return uuid, nil
+}
+
+// AddWithUUID creates a task under uuid with task import, for clients
+// that name new tasks themselves. A deleted task with the UUID is
+// replaced. Recurring tasks cannot be created this way.
+func (c *Client) AddWithUUID(uuid string, task TaskCreate) error {
+	if !ValidateTaskUUID(uuid) {
+		return fmt.Errorf("invalid task UUID %q", uuid)
+	}
+	if task.Recur != "" {
+		return errors.New("recurring tasks cannot be created with a UUID")
 	}
 
-	return uuid, nil
+	now := time.Now().UTC()
+	item := map[string]any{
+		"uuid":        uuid,
+		"description": task.Description,
+		"status":      StatusPending,
+		"entry":       now.Format(TimeLayout),
+		"modified":    now.Format(TimeLayout),
+	}
+	if task.Project != "" {
+		item["project"] = task.Project
+	}
+	if task.Priority != "" {
+		item["priority"] = task.Priority
+	}
+	for name, t := range map[string]*time.Time{"due": task.Due, "wait": task.Wait, "scheduled": task.Scheduled} {
+		if t != nil {
+			item[name] = t.UTC().Format(TimeLayout)
+		}
+	}
+	if task.Wait != nil && task.Wait.After(now) {
+		item["status"] = StatusWaiting
+	}
+	if len(task.Tags) > 0 {
+		item["tags"] = task.Tags
+	}
+	if len(task.Depends) > 0 {
+		item["depends"] = task.Depends
+	}
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	defer c.invalidateCache()
+	return c.importCommand([]json.RawMessage{data})
 }
 
 // Modify updates an existing task
 func (c *Client) Modify(uuid string, modify TaskModify) error {
+	defer c.invalidateCache()
+
 	args := []string{uuid, "modify"}
 
 	if modify.Description != nil {
@@ -185,11 +419,11 @@ func (c *Client) Modify(uuid string, modify TaskModify) error {
 		args = append(args, fmt.Sprintf("depends:%s", dep))
 	}
 
-	cmd := c.buildCommand(args...)
+	cmd := c.buildWriteCommand(args...)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
+	if err := c.runWrite(uuid, cmd); err != nil {
 		return fmt.Errorf("task modify failed: %s", stderr.String())
 	}
 
@@ -198,11 +432,13 @@ func (c *Client) Modify(uuid string, modify TaskModify) error {
 
 // Delete deletes a task
 func (c *Client) Delete(uuid string) error {
-	cmd := c.buildCommand(uuid, "delete", "rc.confirmation=off")
+	defer c.invalidateCache()
+
+	cmd := c.buildWriteCommand(uuid, "delete", "rc.confirmation=off")
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
+	if err := c.runWrite(uuid, cmd); err != nil {
 		return fmt.Errorf("task delete failed: %s", stderr.String())
 	}
 
@@ -211,11 +447,13 @@ func (c *Client) Delete(uuid string) error {
 
 // Done marks a task as completed
 func (c *Client) Done(uuid string) error {
-	cmd := c.buildCommand(uuid, "done")
+	defer c.invalidateCache()
+
+	cmd := c.buildWriteCommand(uuid, "done")
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
+	if err := c.runWrite(uuid, cmd); err != nil {
 		return fmt.Errorf("task done failed: %s", stderr.String())
 	}
 
@@ -224,11 +462,13 @@ func (c *Client) Done(uuid string) error {
 
 // Start starts a task
 func (c *Client) Start(uuid string) error {
-	cmd := c.buildCommand(uuid, "start")
+	defer c.invalidateCache()
+
+	cmd := c.buildWriteCommand(uuid, "start")
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
+	if err := c.runWrite(uuid, cmd); err != nil {
 		return fmt.Errorf("task start failed: %s", stderr.String())
 	}
 
@@ -237,11 +477,13 @@ func (c *Client) Start(uuid string) error {
 
 // Stop stops a task
 func (c *Client) Stop(uuid string) error {
-	cmd := c.buildCommand(uuid, "stop")
+	defer c.invalidateCache()
+
+	cmd := c.buildWriteCommand(uuid, "stop")
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
+	if err := c.runWrite(uuid, cmd); err != nil {
 		return fmt.Errorf("task stop failed: %s", stderr.String())
 	}
 
@@ -251,7 +493,7 @@ func (c *Client) Stop(uuid string) error {
 // Show executes task _show and returns the output
 func (c *Client) Show() (string, error) {
 	cmd := c.buildCommand("_show")
-	output, err := cmd.Output()
+	output, err := c.output(cmd)
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			return "", fmt.Errorf("task _show failed: %s", string(exitErr.Stderr))
@@ -262,6 +504,41 @@ func (c *Client) Show() (string, error) {
 	return string(output), nil
 }
 
+// ErrUnknownReport is returned by exports for a report that is not
+// defined in the taskrc
+var ErrUnknownReport = errors.New("unknown report")
+
+// reportArgs returns the arguments following export for report. Only
+// reports defined in the taskrc are accepted, as anything else in that
+// position, such as rc.data.location=..., is taken as an override.
+func (c *Client) reportArgs(report string) ([]string, error) {
+	if report == "" {
+		return nil, nil
+	}
+	if !isReportName(report) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownReport, report)
+	}
+
+	reports, err := c.GetReports()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range reports {
+		if r.Name == report {
+			return []string{report}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownReport, report)
+}
+
+// isReportName reports whether name is a bare identifier, which rules out
+// overrides such as rc.verbose=off or rc:/path/to/taskrc
+func isReportName(name string) bool {
+	return name != "" && !strings.ContainsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-'
+	})
+}
+
 // GetReports retrieves all available Taskwarrior reports
 func (c *Client) GetReports() ([]ReportInfo, error) {
 	output, err := c.Show()
@@ -269,11 +546,11 @@ func (c *Client) GetReports() ([]ReportInfo, error) {
 		return nil, err
 	}
 
-	return parseReports(output), nil
+	return ParseReports(output), nil
 }
 
-// parseReports extracts report information from task _show output
-func parseReports(output string) []ReportInfo {
+// ParseReports extracts report information from task _show output
+func ParseReports(output string) []ReportInfo {
 	reports := make(map[string]*ReportInfo)
 
 	lines := strings.Split(output, "\n")
@@ -332,13 +609,77 @@ func parseReports(output string) []ReportInfo {
 	return result
 }
 
-// GetProjects retrieves all unique projects
-func (c *Client) GetProjects() ([]Project, error) {
-	tasks, err := c.Export("status:pending")
+// GetContexts retrieves the defined Taskwarrior contexts
+func (c *Client) GetContexts() ([]ContextInfo, error) {
+	output, err := c.Show()
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseContexts(output), nil
+}
+
+// ParseContexts extracts the contexts from task _show output. Taskwarrior
+// 2.6 also accepts context.{name}={filter}, which is used for reading and
+// writing.
+func ParseContexts(output string) []ContextInfo {
+	contexts := make(map[string]*ContextInfo)
+	active := ""
+
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		if key == "context" {
+			active = value
+			continue
+		}
+		if !strings.HasPrefix(key, "context.") {
+			continue
+		}
+
+		name, field, _ := strings.Cut(strings.TrimPrefix(key, "context."), ".")
+		if name == "" {
+			continue
+		}
+		if contexts[name] == nil {
+			contexts[name] = &ContextInfo{Name: name}
+		}
+
+		switch field {
+		case "":
+			contexts[name].Read = value
+			contexts[name].Write = value
+		case "read":
+			contexts[name].Read = value
+		case "write":
+			contexts[name].Write = value
+		}
+	}
+
+	result := make([]ContextInfo, 0, len(contexts))
+	for _, info := range contexts {
+		info.Active = info.Name == active
+		result = append(result, *info)
+	}
+
+	return result
+}
+
+// GetProjects retrieves all unique projects of pending tasks within the
+// restriction
+func (c *Client) GetProjects(restriction Restriction) ([]Project, error) {
+	tasks, err := c.Export(append([]string{"status:pending"}, restriction.Filters()...)...)
 	if err != nil {
 		return nil, err
 	}
 
+	return ProjectsOf(restriction.Apply(tasks)), nil
+}
+
+// ProjectsOf counts the tasks of each project in tasks
+func ProjectsOf(tasks []Task) []Project {
 	projectMap := make(map[string]int)
 	for _, task := range tasks {
 		if task.Project != "" {
@@ -354,27 +695,19 @@ func (c *Client) GetProjects() ([]Project, error) {
 		})
 	}
 
-	return projects, nil
+	return projects
+}
+
+// DataLocation returns the data directory with the home directory expanded
+func (c *Client) DataLocation() string {
+	return expandHome(c.dataLocation)
 }
 
 // buildCommand creates an exec.Cmd with the data location set
 func (c *Client) buildCommand(args ...string) *exec.Cmd {
 	// Expand home directory if needed
-	dataLocation := c.dataLocation
-	if strings.HasPrefix(dataLocation, "~/") {
-		home, err := os.UserHomeDir()
-		if err == nil {
-			dataLocation = strings.Replace(dataLocation, "~", home, 1)
-		}
-	}
-
-	taskrcLocation := c.taskrcLocation
-	if strings.HasPrefix(taskrcLocation, "~/") {
-		home, err := os.UserHomeDir()
-		if err == nil {
-			taskrcLocation = strings.Replace(taskrcLocation, "~", home, 1)
-		}
-	}
+	dataLocation := expandHome(c.dataLocation)
+	taskrcLocation := expandHome(c.taskrcLocation)
 
 	// Prepend data location and taskrc location overrides
 	allArgs := []string{fmt.Sprintf("rc.data.location=%s", dataLocation)}
@@ -382,28 +715,175 @@ func (c *Client) buildCommand(args ...string) *exec.Cmd {
 		allArgs = append(allArgs, fmt.Sprintf("rc:%s", taskrcLocation))
 	}
 	allArgs = append(allArgs, args...)
-	log.Printf("Running command: task %s", strings.Join(allArgs, " "))
+	c.log().Debug("Running command", "args", redactArgs(allArgs))
 	cmd := exec.Command("task", allArgs...)
 	return cmd
 }
 
-// extractUUIDFromOutput extracts UUID from task command output
-func (c *Client) extractUUIDFromOutput(output string) string {
-	// Taskwarrior typically outputs: "Created task N."
-	// We need to export to get the UUID
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "Created task") {
-			// Extract task ID and query for UUID
-			fields := strings.Fields(line)
-			if len(fields) >= 3 {
-				// Try to get the actual UUID by querying the last task
-				tasks, err := c.Export("limit:1")
-				if err == nil && len(tasks) > 0 {
-					return tasks[0].UUID
-				}
-			}
+// buildWriteCommand creates a command that changes tasks, passing its argv
+// to the command recorder
+func (c *Client) buildWriteCommand(args ...string) *exec.Cmd {
+	cmd := c.buildCommand(args...)
+	if c.recordCommand != nil {
+		c.recordCommand(cmd.Args)
+	}
+	return cmd
+}
+
+var (
+	commandsTotal = metrics.NewCounterVec("taskwarrior_commands_total",
+		"Task subprocesses run, by subcommand.", "subcommand")
+	commandFailures = metrics.NewCounterVec("taskwarrior_command_failures_total",
+		"Task subprocesses that failed, by subcommand.", "subcommand")
+	commandDuration = metrics.NewHistogramVec("taskwarrior_command_duration_seconds",
+		"Duration of task subprocesses, by subcommand.", metrics.DefaultBuckets, "subcommand")
+	writesInFlight = metrics.NewGaugeVec("taskwarrior_writes_in_flight",
+		"Task subprocesses changing tasks that are running or waiting for the data files.")
+)
+
+// writeCommands are the commands that change tasks
+var writeCommands = map[string]bool{
+	"add": true, "modify": true, "delete": true, "done": true, "start": true,
+	"stop": true, "annotate": true, "denotate": true, "import": true, "log": true,
+	"sync": true,
+}
+
+// run runs cmd, recording its duration and outcome
+func (c *Client) run(cmd *exec.Cmd) error {
+	return c.observe(cmd, cmd.Run)
+}
+
+// runWrite runs cmd, which changes the task with uuid, under the write
+// lock
+func (c *Client) runWrite(uuid string, cmd *exec.Cmd) error {
+	return c.write([]string{uuid}, func(locked *Client) error {
+		return locked.run(cmd)
+	})
+}
+
+// output runs cmd and returns its standard output, recording its duration
+// and outcome
+func (c *Client) output(cmd *exec.Cmd) ([]byte, error) {
+	var out []byte
+	err := c.observe(cmd, func() (err error) {
+		out, err = cmd.Output()
+		return err
+	})
+	return out, err
+}
+
+var tracer = otel.Tracer("github.com/dotbinio/taskwarrior-api/internal/taskwarrior")
+
+func (c *Client) observe(cmd *exec.Cmd, run func() error) error {
+	subcommand := subcommandOf(cmd.Args)
+	if writeCommands[subcommand] {
+		writesInFlight.Add(1)
+		defer writesInFlight.Add(-1)
+		if !c.writeLocked {
+			c.writeMu.Lock()
+			defer c.writeMu.Unlock()
+		}
+	}
+
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, span := tracer.Start(ctx, "task "+subcommand,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attribute.String("taskwarrior.subcommand", subcommand)))
+	defer span.End()
+
+	start := time.Now()
+	err := run()
+	duration := time.Since(start)
+	commandDuration.Observe(duration.Seconds(), subcommand)
+	commandsTotal.Inc(subcommand)
+
+	// The exit code is -1 if the command could not be started
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	span.SetAttributes(
+		attribute.Int("process.exit.code", exitCode),
+		attribute.Float64("taskwarrior.duration_ms", float64(duration.Microseconds())/1000),
+	)
+	if err != nil {
+		commandFailures.Inc(subcommand)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "task "+subcommand+" failed")
+	}
+	return err
+}
+
+// subcommandOf returns the Taskwarrior command in argv
+func subcommandOf(argv []string) string {
+	for _, arg := range argv {
+		if commandWords[arg] && !strings.HasPrefix(arg, "rc.") {
+			return arg
 		}
 	}
-	return ""
+	return "other"
+}
+
+// commandWords are the Taskwarrior commands and options the client runs,
+// which are logged as they are
+var commandWords = map[string]bool{
+	"add": true, "modify": true, "delete": true, "done": true, "start": true,
+	"stop": true, "export": true, "_show": true, "annotate": true,
+	"denotate": true, "import": true, "log": true, "_version": true,
+	"diagnostics": true, "sync": true, "rc.confirmation=off": true, "rc.verbose=new-uuid": true,
+}
+
+// redactArgs returns the arguments of a command for logging. Descriptions,
+// filters and attribute values may contain anything the user wrote, so
+// only the data location, UUIDs, commands and attribute names are kept.
+func redactArgs(args []string) string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "rc.data.location="), strings.HasPrefix(arg, "rc:"),
+			commandWords[arg], ValidateTaskUUID(arg):
+			redacted[i] = arg
+		case strings.Contains(arg, ":"):
+			name, _, _ := strings.Cut(arg, ":")
+			redacted[i] = name + ":" + logging.Redacted
+		default:
+			redacted[i] = logging.Redacted
+		}
+	}
+	return strings.Join(redacted, " ")
+}
+
+// expandHome expands a leading "~/" to the user's home directory
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			return strings.Replace(path, "~", home, 1)
+		}
+	}
+	return path
+}
+
+// createdUUID returns the UUID of the task created by task add. Releases
+// that only print the ID are asked for the UUID of that ID.
+func (c *Client) createdUUID(output string) (string, error) {
+	uuid, id := c.compat().parseCreated(output)
+	if uuid != "" {
+		return uuid, nil
+	}
+	if id == 0 {
+		return "", fmt.Errorf("failed to extract UUID from task add output")
+	}
+
+	tasks, err := c.exportCommand([]string{strconv.Itoa(id)}, "")
+	if err != nil {
+		return "", err
+	}
+	if len(tasks) == 0 {
+		return "", fmt.Errorf("created task %d not found", id)
+	}
+	return tasks[0].UUID, nil
 }
@@ -0,0 +1,138 @@
+package taskwarrior
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// adapter holds the behavior that differs between major Taskwarrior
+// releases. Everything else in Client works the same with both.
+type adapter interface {
+	// dataFiles are the files in the data location that hold tasks
+	dataFiles() []string
+	// parseCreated returns the UUID of the task created by task add, or
+	// failing that its ID
+	parseCreated(output string) (uuid string, id int)
+	// countsSync reports whether task sync prints how many changes it
+	// transferred
+	countsSync() bool
+	// parseSync returns the changes uploaded and downloaded by task sync
+	parseSync(output string) (uploaded, downloaded *int)
+}
+
+// adapterFor returns the adapter for a release
+func adapterFor(v Version) adapter {
+	if v.Major >= 3 {
+		return v3Adapter{}
+	}
+	return v2Adapter{}
+}
+
+var (
+	// With rc.verbose=new-uuid, task add prints "Created task <uuid>."
+	createdUUIDPattern = regexp.MustCompile(`Created task ([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})`)
+	// Otherwise it prints "Created task N." or, for recurring tasks,
+	// "Created task N (recurrence template)."
+	createdIDPattern = regexp.MustCompile(`Created task (\d+)`)
+
+	// Taskwarrior 2.x syncs with a Taskserver and prints "Sync successful."
+	// followed by the number of changes in each direction, or "No changes."
+	syncUploadedPattern   = regexp.MustCompile(`(\d+) changes? uploaded`)
+	syncDownloadedPattern = regexp.MustCompile(`(\d+) changes? downloaded`)
+)
+
+// v2Adapter supports Taskwarrior 2.6, which keeps tasks in text files
+type v2Adapter struct{}
+
+func (v2Adapter) dataFiles() []string {
+	return []string{"pending.data", "completed.data", "undo.data", "backlog.data"}
+}
+
+func (v2Adapter) parseCreated(output string) (string, int) {
+	return parseCreated(output)
+}
+
+func (v2Adapter) countsSync() bool {
+	return true
+}
+
+func (v2Adapter) parseSync(output string) (*int, *int) {
+	count := func(pattern *regexp.Regexp) *int {
+		n := 0
+		if m := pattern.FindStringSubmatch(output); m != nil {
+			n, _ = strconv.Atoi(m[1])
+		}
+		return &n
+	}
+	return count(syncUploadedPattern), count(syncDownloadedPattern)
+}
+
+// v3Adapter supports Taskwarrior 3, which keeps tasks in a TaskChampion
+// SQLite database
+type v3Adapter struct{}
+
+func (v3Adapter) dataFiles() []string {
+	// Writes land in the write-ahead log before the database itself
+	return []string{"taskchampion.sqlite3", "taskchampion.sqlite3-wal"}
+}
+
+func (v3Adapter) parseCreated(output string) (string, int) {
+	return parseCreated(output)
+}
+
+// Taskwarrior 3 syncs through TaskChampion and does not report how many
+// changes it transferred
+func (v3Adapter) countsSync() bool {
+	return false
+}
+
+func (v3Adapter) parseSync(string) (*int, *int) {
+	return nil, nil
+}
+
+func parseCreated(output string) (string, int) {
+	if m := createdUUIDPattern.FindStringSubmatch(output); m != nil {
+		return m[1], 0
+	}
+	if m := createdIDPattern.FindStringSubmatch(output); m != nil {
+		id, _ := strconv.Atoi(m[1])
+		return "", id
+	}
+	return "", 0
+}
+
+// Detect determines the installed Taskwarrior release, so that behavior
+// specific to it is used. Until it succeeds, 2.x behavior is assumed and
+// all files in the data location are watched.
+func (c *Client) Detect() (Version, error) {
+	version, err := c.Version()
+	if err != nil {
+		return Version{}, err
+	}
+	c.version = version
+	c.adapter = adapterFor(version)
+	return version, nil
+}
+
+// DetectedVersion returns the release found by Detect, or the zero
+// version if it has not succeeded
+func (c *Client) DetectedVersion() Version {
+	return c.version
+}
+
+// DataFiles returns the names of the files in the data location holding
+// tasks, or nil if the release is unknown
+func (c *Client) DataFiles() []string {
+	if c.adapter == nil {
+		return nil
+	}
+	return c.adapter.dataFiles()
+}
+
+// compat returns the adapter of the detected release
+func (c *Client) compat() adapter {
+	if c.adapter == nil {
+		return v2Adapter{}
+	}
+	return c.adapter
+}
@@ -0,0 +1,181 @@
+package taskwarrior
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidFilter is returned by ParseFilter for expressions outside the
+// supported filter language
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// maxFilterLength limits the size of a filter expression
+const maxFilterLength = 1024
+
+// filterAttributes are the attributes a filter term may name
+var filterAttributes = map[string]bool{
+	"description": true, "project": true, "status": true, "priority": true,
+	"tags": true, "uuid": true, "parent": true, "depends": true, "recur": true,
+	"urgency": true, "entry": true, "modified": true, "start": true, "end": true,
+	"due": true, "until": true, "wait": true, "scheduled": true,
+}
+
+// filterModifiers are the attribute modifiers a filter term may use
+var filterModifiers = map[string]bool{
+	"before": true, "after": true, "by": true, "under": true, "over": true,
+	"below": true, "above": true, "none": true, "any": true, "is": true,
+	"equals": true, "isnt": true, "not": true, "has": true, "contains": true,
+	"hasnt": true, "startswith": true, "left": true, "endswith": true,
+	"right": true, "word": true, "noword": true,
+}
+
+// ParseFilter parses a filter expression written by an API client, such
+// as "project:work and (+urgent or due.before:2026-02-01)", into arguments
+// for Export. Only attribute terms, tags, UUIDs, parentheses and the
+// operators and, or and xor are accepted, so an expression cannot
+// override settings or run a command. The result is wrapped in
+// parentheses, so it combines with further filters by and.
+func ParseFilter(expr string) ([]string, error) {
+	if len(expr) > maxFilterLength {
+		return nil, fmt.Errorf("%w: longer than %d bytes", ErrInvalidFilter, maxFilterLength)
+	}
+
+	tokens := splitFilter(expr)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	depth := 0
+	// operand is true where the next token has to start an operand: at
+	// the start, after an operator and after an opening parenthesis
+	operand := true
+	for _, token := range tokens {
+		switch token {
+		case "(":
+			depth++
+			operand = true
+			continue
+		case ")":
+			if depth == 0 {
+				return nil, fmt.Errorf("%w: unbalanced parentheses", ErrInvalidFilter)
+			}
+			if operand {
+				return nil, fmt.Errorf("%w: missing term before %q", ErrInvalidFilter, token)
+			}
+			depth--
+			continue
+		case "and", "or", "xor":
+			if operand {
+				return nil, fmt.Errorf("%w: missing term before %q", ErrInvalidFilter, token)
+			}
+			operand = true
+			continue
+		}
+
+		if err := checkFilterTerm(token); err != nil {
+			return nil, err
+		}
+		operand = false
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("%w: unbalanced parentheses", ErrInvalidFilter)
+	}
+	if operand {
+		return nil, fmt.Errorf("%w: missing term at the end", ErrInvalidFilter)
+	}
+
+	return append(append([]string{"("}, tokens...), ")"), nil
+}
+
+// FilterTerms builds the terms selecting status, project and tags, each of
+// which may be empty. Every term is checked as by ParseFilter, so a value
+// cannot add terms of its own or override settings.
+func FilterTerms(status, project string, tags []string) ([]string, error) {
+	var terms []string
+	if status != "" {
+		terms = append(terms, "status:"+status)
+	}
+	if project != "" {
+		terms = append(terms, "project:"+project)
+	}
+	for _, tag := range tags {
+		terms = append(terms, "+"+tag)
+	}
+
+	for _, term := range terms {
+		if tokens := splitFilter(term); len(tokens) != 1 || tokens[0] != term {
+			return nil, fmt.Errorf("%w: spaces and parentheses are not allowed in %q", ErrInvalidFilter, term)
+		}
+		if err := checkFilterTerm(term); err != nil {
+			return nil, err
+		}
+	}
+	return terms, nil
+}
+
+// splitFilter splits an expression at whitespace, with parentheses as
+// tokens of their own
+func splitFilter(expr string) []string {
+	var tokens []string
+	for _, field := range strings.Fields(expr) {
+		start := 0
+		for i, r := range field {
+			if r != '(' && r != ')' {
+				continue
+			}
+			if i > start {
+				tokens = append(tokens, field[start:i])
+			}
+			tokens = append(tokens, string(r))
+			start = i + 1
+		}
+		if start < len(field) {
+			tokens = append(tokens, field[start:])
+		}
+	}
+	return tokens
+}
+
+// checkFilterTerm accepts a UUID, a tag or an attribute term
+func checkFilterTerm(term string) error {
+	if strings.HasPrefix(term, "rc.") || strings.HasPrefix(term, "rc:") {
+		return fmt.Errorf("%w: configuration overrides are not allowed", ErrInvalidFilter)
+	}
+	if commandWords[term] {
+		return fmt.Errorf("%w: commands are not allowed", ErrInvalidFilter)
+	}
+	if strings.ContainsFunc(term, func(r rune) bool {
+		return r == '"' || r == '\'' || r == '\\' || unicode.IsControl(r)
+	}) {
+		return fmt.Errorf("%w: quotes and control characters are not allowed in %q", ErrInvalidFilter, term)
+	}
+
+	if ValidateTaskUUID(term) {
+		return nil
+	}
+
+	if term[0] == '+' || term[0] == '-' {
+		tag := term[1:]
+		if tag == "" || strings.ContainsFunc(tag, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("_-.@", r)
+		}) || tag[0] == '-' {
+			return fmt.Errorf("%w: invalid tag %q", ErrInvalidFilter, term)
+		}
+		return nil
+	}
+
+	name, _, ok := strings.Cut(term, ":")
+	if !ok {
+		return fmt.Errorf("%w: unsupported term %q", ErrInvalidFilter, term)
+	}
+	attribute, modifier, hasModifier := strings.Cut(name, ".")
+	if !filterAttributes[attribute] {
+		return fmt.Errorf("%w: unsupported attribute %q", ErrInvalidFilter, attribute)
+	}
+	if hasModifier && !filterModifiers[modifier] {
+		return fmt.Errorf("%w: unsupported modifier %q", ErrInvalidFilter, modifier)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package taskwarrior
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"", nil},
+		{"   ", nil},
+		{"project:work", []string{"(", "project:work", ")"}},
+		{"+urgent -someday", []string{"(", "+urgent", "-someday", ")"}},
+		{
+			"project:work and (+urgent or due.before:2026-02-01)",
+			[]string{"(", "project:work", "and", "(", "+urgent", "or", "due.before:2026-02-01", ")", ")"},
+		},
+		{"((+a)xor(+b))", []string{"(", "(", "(", "+a", ")", "xor", "(", "+b", ")", ")", ")"}},
+		{
+			"a360fc44-315c-4366-b70c-ea7e7520b749 or 0f6b2c1e-9d4a-4c3b-8e2f-7a1b5c9d3e4f",
+			[]string{"(", "a360fc44-315c-4366-b70c-ea7e7520b749", "or", "0f6b2c1e-9d4a-4c3b-8e2f-7a1b5c9d3e4f", ")"},
+		},
+		{"description.has:café +旅行", []string{"(", "description.has:café", "+旅行", ")"}},
+		{"project:", []string{"(", "project:", ")"}},
+		{"due.any: +ACTIVE", []string{"(", "due.any:", "+ACTIVE", ")"}},
+		{"urgency.over:5 status:pending", []string{"(", "urgency.over:5", "status:pending", ")"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterRejects(t *testing.T) {
+	tests := []string{
+		// Settings and commands
+		"rc.data.location=/tmp",
+		"rc.confirmation:off",
+		"rc:/etc/taskrc",
+		"project:work rc.hooks=on",
+		"delete",
+		"+a modify",
+		"_show",
+		"rc.verbose=new-uuid",
+		// Terms outside the allowlist
+		"write docs",
+		"/regex/",
+		"pro:work",
+		"bogus:1",
+		"due.sometime:tomorrow",
+		"42",
+		"+",
+		"--x",
+		"+a;b",
+		`project:"work"`,
+		"description:it's",
+		"project:work\x00",
+		// Structure
+		"(project:work",
+		"project:work)",
+		"()",
+		"and +a",
+		"+a or",
+		"+a and or +b",
+		"(or +a)",
+		strings.Repeat("+a ", 400),
+	}
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			got, err := ParseFilter(expr)
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("got %q, %v; want ErrInvalidFilter", got, err)
+			}
+		})
+	}
+}
+
+func TestFilterTerms(t *testing.T) {
+	terms, err := FilterTerms("pending", "work.release", []string{"docs", "next"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"status:pending", "project:work.release", "+docs", "+next"}; !slices.Equal(terms, want) {
+		t.Errorf("got %q, want %q", terms, want)
+	}
+
+	for _, tt := range []struct {
+		status, project string
+		tags            []string
+	}{
+		{project: "work rc.data.location=/other/tenant"},
+		{project: "work) or (project:home"},
+		{status: "pending or status:deleted"},
+		{tags: []string{"docs rc:/other/taskrc"}},
+		{tags: []string{""}},
+	} {
+		if terms, err := FilterTerms(tt.status, tt.project, tt.tags); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("%+v: got %q, %v; want ErrInvalidFilter", tt, terms, err)
+		}
+	}
+}
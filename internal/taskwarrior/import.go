@@ -0,0 +1,343 @@
+package taskwarrior
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+// Import outcomes of a single task
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+)
+
+// ErrImportAfterState is returned by Import together with its results when
+// the tasks were written but could not be read back afterwards. The
+// results keep their outcomes, without the states after the import.
+var ErrImportAfterState = errors.New("imported tasks could not be read back")
+
+// ImportResult is the outcome of importing one task of a payload
+type ImportResult struct {
+	Index  int    `json:"index"`
+	UUID   string `json:"uuid,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	// Before and After are the task's states around the import
+	Before *Task `json:"-"`
+	After  *Task `json:"-"`
+}
+
+// ParseImport reads a JSON array of tasks, as written by task export, or
+// a stream of JSON objects such as NDJSON
+func ParseImport(r io.Reader) ([]json.RawMessage, error) {
+	br := bufio.NewReader(r)
+	first, err := peekNonSpace(br)
+	if err == io.EOF {
+		return nil, errors.New("no tasks")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if first == '[' {
+		var items []json.RawMessage
+		if err := json.NewDecoder(br).Decode(&items); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+		return items, nil
+	}
+
+	var items []json.RawMessage
+	dec := json.NewDecoder(br)
+	for {
+		var item json.RawMessage
+		if err := dec.Decode(&item); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid JSON at task %d: %w", len(items), err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b)) {
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+// Import imports tasks with task import. Tasks are matched by UUID, so
+// importing the same payload twice changes nothing; tasks whose fields
+// all match the existing task are skipped. With validateOnly, the outcome
+// is determined without importing anything. If reading the tasks back
+// fails after they were written, the results are returned with an error
+// wrapping ErrImportAfterState.
+func (c *Client) Import(items []json.RawMessage, validateOnly bool) ([]ImportResult, error) {
+	existing, err := c.exportCommand(nil, "")
+	if err != nil {
+		return nil, err
+	}
+	byUUID := make(map[string]Task, len(existing))
+	for _, task := range existing {
+		byUUID[task.UUID] = task
+	}
+
+	results := make([]ImportResult, len(items))
+	// payload holds the items as passed to task import
+	payload := make([]json.RawMessage, len(items))
+	var pending []int
+	seen := make(map[string]bool, len(items))
+	for i, item := range items {
+		result := &results[i]
+		result.Index = i
+
+		task, fields, err := validateImportItem(item)
+		result.UUID = task.UUID
+		if err == nil {
+			payload[i], err = normalizeImportItem(fields, task)
+		}
+		switch {
+		case err != nil:
+			result.Status, result.Error = ImportFailed, err.Error()
+			continue
+		case seen[task.UUID]:
+			result.Status, result.Error = ImportFailed, "duplicate uuid in payload"
+			continue
+		}
+		seen[task.UUID] = true
+
+		if current, ok := byUUID[task.UUID]; !ok {
+			result.Status = ImportCreated
+		} else if sameImportFields(fields, task, current) {
+			result.Status = ImportSkipped
+			continue
+		} else {
+			result.Status = ImportUpdated
+			result.Before = &current
+		}
+		pending = append(pending, i)
+	}
+
+	if validateOnly || len(pending) == 0 {
+		return results, nil
+	}
+
+	defer c.invalidateCache()
+
+	// Import in one go, and task by task if that fails so the failing
+	// tasks can be reported
+	batch := make([]json.RawMessage, len(pending))
+	for j, i := range pending {
+		batch[j] = payload[i]
+	}
+	if err := c.importCommand(batch); err != nil {
+		for _, i := range pending {
+			if err := c.importCommand(payload[i : i+1]); err != nil {
+				results[i].Status, results[i].Error, results[i].Before = ImportFailed, err.Error(), nil
+			}
+		}
+	}
+
+	imported, err := c.exportCommand(nil, "")
+	if err != nil {
+		return results, fmt.Errorf("%w: %v", ErrImportAfterState, err)
+	}
+	pendingByUUID := make(map[string]int, len(pending))
+	for _, i := range pending {
+		if results[i].Status != ImportFailed {
+			pendingByUUID[results[i].UUID] = i
+		}
+	}
+	for _, task := range imported {
+		if i, ok := pendingByUUID[task.UUID]; ok {
+			after := task
+			results[i].After = &after
+		}
+	}
+
+	return results, nil
+}
+
+// importCommand runs task import with items on standard input
+func (c *Client) importCommand(items []json.RawMessage) error {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	cmd := c.buildWriteCommand("import", "-")
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	uuids := make([]string, 0, len(items))
+	for _, item := range items {
+		var task struct {
+			UUID string `json:"uuid"`
+		}
+		if json.Unmarshal(item, &task) == nil && task.UUID != "" {
+			uuids = append(uuids, task.UUID)
+		}
+	}
+
+	err = c.write(uuids, func(locked *Client) error {
+		return locked.run(cmd)
+	})
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("task import failed: %s", strings.TrimSpace(stderr.String()))
+		}
+		return fmt.Errorf("task import failed: %w", err)
+	}
+	return nil
+}
+
+// validateImportItem checks that item is a task with a UUID and a
+// description, and returns it together with the fields it sets
+func validateImportItem(item json.RawMessage) (Task, map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(item, &fields); err != nil || fields == nil {
+		return Task{}, nil, errors.New("task must be a JSON object")
+	}
+
+	var task Task
+	if err := json.Unmarshal(item, &task); err != nil {
+		return Task{}, nil, fmt.Errorf("invalid task: %w", err)
+	}
+
+	switch {
+	case task.UUID == "":
+		return task, nil, errors.New("uuid is required")
+	case !ValidateTaskUUID(task.UUID):
+		return task, nil, errors.New("invalid uuid")
+	case strings.TrimSpace(task.Description) == "":
+		return task, nil, errors.New("description is required")
+	}
+
+	switch task.Status {
+	case "", StatusPending, StatusCompleted, StatusDeleted, StatusWaiting, StatusRecurring:
+	default:
+		return task, nil, fmt.Errorf("invalid status %q", task.Status)
+	}
+
+	for _, dep := range task.Depends {
+		if !ValidateTaskUUID(dep) {
+			return task, nil, fmt.Errorf("invalid dependency %q", dep)
+		}
+	}
+
+	return task, fields, nil
+}
+
+// importTimeFields are the fields holding times
+var importTimeFields = map[string]func(*Task) *TaskwarriorTime{
+	"entry":     func(t *Task) *TaskwarriorTime { return t.Entry },
+	"modified":  func(t *Task) *TaskwarriorTime { return t.Modified },
+	"start":     func(t *Task) *TaskwarriorTime { return t.Start },
+	"end":       func(t *Task) *TaskwarriorTime { return t.End },
+	"due":       func(t *Task) *TaskwarriorTime { return t.Due },
+	"until":     func(t *Task) *TaskwarriorTime { return t.Until },
+	"wait":      func(t *Task) *TaskwarriorTime { return t.Wait },
+	"scheduled": func(t *Task) *TaskwarriorTime { return t.Scheduled },
+}
+
+// normalizeImportItem rewrites the times of a validated item in
+// Taskwarrior's own format, which is all task import reads
+func normalizeImportItem(fields map[string]json.RawMessage, task Task) (json.RawMessage, error) {
+	normalized := maps.Clone(fields)
+	for key, get := range importTimeFields {
+		if t := get(&task); t != nil && !t.IsZero() && fields[key] != nil {
+			normalized[key], _ = json.Marshal(t.UTC().Format(TimeLayout))
+		}
+	}
+
+	if _, ok := fields["annotations"]; ok && len(task.Annotations) > 0 {
+		var annotations []map[string]json.RawMessage
+		if err := json.Unmarshal(fields["annotations"], &annotations); err != nil {
+			return nil, fmt.Errorf("invalid annotations: %w", err)
+		}
+		for i, annotation := range annotations {
+			if entry := task.Annotations[i].Entry; !entry.IsZero() {
+				annotation["entry"], _ = json.Marshal(entry.UTC().Format(TimeLayout))
+			}
+		}
+		normalized["annotations"], _ = json.Marshal(annotations)
+	}
+
+	return json.Marshal(normalized)
+}
+
+// sameImportFields reports whether every field set in an imported task
+// already has the same value in the existing task. Fields the client does
+// not model, such as UDAs, cannot be compared and count as changed.
+func sameImportFields(fields map[string]json.RawMessage, incoming, current Task) bool {
+	for key := range fields {
+		var same bool
+		switch key {
+		case "id", "urgency", "uuid":
+			same = true
+		case "description":
+			same = incoming.Description == current.Description
+		case "status":
+			same = incoming.Status == current.Status
+		case "entry":
+			same = sameTime(incoming.Entry, current.Entry)
+		case "modified":
+			same = sameTime(incoming.Modified, current.Modified)
+		case "start":
+			same = sameTime(incoming.Start, current.Start)
+		case "end":
+			same = sameTime(incoming.End, current.End)
+		case "due":
+			same = sameTime(incoming.Due, current.Due)
+		case "until":
+			same = sameTime(incoming.Until, current.Until)
+		case "wait":
+			same = sameTime(incoming.Wait, current.Wait)
+		case "scheduled":
+			same = sameTime(incoming.Scheduled, current.Scheduled)
+		case "project":
+			same = incoming.Project == current.Project
+		case "tags":
+			same = slices.Equal(incoming.Tags, current.Tags)
+		case "priority":
+			same = incoming.Priority == current.Priority
+		case "depends":
+			same = slices.Equal(incoming.Depends, current.Depends)
+		case "annotations":
+			same = slices.EqualFunc(incoming.Annotations, current.Annotations, func(a, b Annotation) bool {
+				return a.Description == b.Description && a.Entry.Time.Equal(b.Entry.Time)
+			})
+		case "mask":
+			same = incoming.Mask == current.Mask
+		case "imask":
+			same = incoming.Imask == current.Imask
+		case "parent":
+			same = incoming.Parent == current.Parent
+		case "recur":
+			same = incoming.Recur == current.Recur
+		}
+		if !same {
+			return false
+		}
+	}
+	return true
+}
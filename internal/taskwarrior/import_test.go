@@ -0,0 +1,145 @@
+package taskwarrior
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dotbinio/taskwarrior-api/internal/tasktest"
+)
+
+func TestMain(m *testing.M) {
+	tasktest.Main()
+	os.Exit(m.Run())
+}
+
+const (
+	existingUUID = "a360fc44-315c-4366-b70c-ea7e7520b749"
+	newUUID      = "0f6b2c1e-9d4a-4c3b-8e2f-7a1b5c9d3e4f"
+	skippedUUID  = "5e7d9c3a-2b1f-4e8d-a6c4-3f2e1d0c9b8a"
+)
+
+func importFixture(t *testing.T) (*tasktest.Fake, []json.RawMessage) {
+	t.Helper()
+
+	fake := tasktest.Install(t, []map[string]any{
+		{"uuid": existingUUID, "description": "Write docs", "status": "pending", "entry": "20260101T090000Z"},
+		{"uuid": skippedUUID, "description": "Water plants", "status": "pending", "entry": "20260101T090000Z"},
+	})
+	items := []json.RawMessage{
+		json.RawMessage(`{"uuid":"` + existingUUID + `","description":"Write the docs","status":"pending"}`),
+		json.RawMessage(`{"uuid":"` + newUUID + `","description":"Release 1.0","status":"pending"}`),
+		json.RawMessage(`{"uuid":"` + skippedUUID + `","description":"Water plants"}`),
+		json.RawMessage(`{"description":"No uuid"}`),
+	}
+	return fake, items
+}
+
+func TestImport(t *testing.T) {
+	fake, items := importFixture(t)
+
+	results, err := NewClient(fake.DataLocation(), "").Import(items, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{ImportUpdated, ImportCreated, ImportSkipped, ImportFailed}
+	for i, result := range results {
+		if result.Status != want[i] {
+			t.Errorf("result %d: %s, want %s", i, result.Status, want[i])
+		}
+	}
+	if results[0].Before == nil || results[0].After == nil || results[0].After.Description != "Write the docs" {
+		t.Errorf("updated task: before %v, after %v", results[0].Before, results[0].After)
+	}
+	if results[1].After == nil || results[1].After.Description != "Release 1.0" {
+		t.Errorf("created task: after %v", results[1].After)
+	}
+}
+
+// When the tasks were written but cannot be read back, the outcomes of
+// the write are still reported
+func TestImportAfterStateMissing(t *testing.T) {
+	fake, items := importFixture(t)
+	// The export before the import succeeds, the one after fails
+	fake.FailAfter("export", 1)
+
+	results, err := NewClient(fake.DataLocation(), "").Import(items, false)
+	if !errors.Is(err, ErrImportAfterState) {
+		t.Fatalf("got %v, want ErrImportAfterState", err)
+	}
+	if len(results) != len(items) {
+		t.Fatalf("got %d results, want %d", len(results), len(items))
+	}
+
+	want := []string{ImportUpdated, ImportCreated, ImportSkipped, ImportFailed}
+	for i, result := range results {
+		if result.Status != want[i] {
+			t.Errorf("result %d: %s, want %s", i, result.Status, want[i])
+		}
+		if result.After != nil {
+			t.Errorf("result %d has an after-state", i)
+		}
+	}
+	if results[0].Before == nil {
+		t.Error("the updated task lost its before-state")
+	}
+	if task := fake.Task(newUUID); task == nil {
+		t.Error("the new task was not written")
+	}
+}
+
+func TestImportExistingUnreadable(t *testing.T) {
+	fake, items := importFixture(t)
+	fake.Fail("export", true)
+
+	results, err := NewClient(fake.DataLocation(), "").Import(items, false)
+	if err == nil || errors.Is(err, ErrImportAfterState) || results != nil {
+		t.Fatalf("got %v, %v; want a plain error and no results", results, err)
+	}
+	if calls := fake.CallsOf("import"); len(calls) != 0 {
+		t.Errorf("imported although existing tasks could not be read: %q", calls)
+	}
+}
+
+// Dates as this API returns them and RFC 3339 times from other tools are
+// imported in Taskwarrior's format
+func TestImportTimeFormats(t *testing.T) {
+	fake := tasktest.Install(t, nil)
+	items := []json.RawMessage{
+		json.RawMessage(`{"uuid":"` + existingUUID + `","description":"From the API","due":"2026-02-01","annotations":[{"entry":"2026-01-02","description":"Note"}]}`),
+		json.RawMessage(`{"uuid":"` + newUUID + `","description":"From elsewhere","due":"2026-02-01T18:00:00+01:00"}`),
+		json.RawMessage(`{"uuid":"` + skippedUUID + `","description":"From Taskwarrior","due":"20260201T170000Z"}`),
+	}
+
+	results, err := NewClient(fake.DataLocation(), "").Import(items, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range results {
+		if result.Status != ImportCreated {
+			t.Errorf("result %d: %s %s", i, result.Status, result.Error)
+		}
+	}
+
+	for uuid, want := range map[string]string{
+		existingUUID: "20260201T000000Z",
+		newUUID:      "20260201T170000Z",
+		skippedUUID:  "20260201T170000Z",
+	} {
+		if got := fake.Task(uuid)["due"]; got != want {
+			t.Errorf("%s: due %v, want %s", uuid, got, want)
+		}
+	}
+	annotations, _ := fake.Task(existingUUID)["annotations"].([]any)
+	if len(annotations) != 1 || annotations[0].(map[string]any)["entry"] != "20260102T000000Z" {
+		t.Errorf("annotations %v", annotations)
+	}
+
+	// The items passed in are left as they were
+	if !strings.Contains(string(items[0]), `"2026-02-01"`) {
+		t.Errorf("item rewritten: %s", items[0])
+	}
+}
@@ -25,8 +25,8 @@ func matchesFilter(task Task, filter TaskFilter) bool {
 		return false
 	}
 
-	// Filter by project
-	if filter.Project != "" && task.Project != filter.Project {
+	// Filter by project, including subprojects as Taskwarrior does
+	if filter.Project != "" && task.Project != filter.Project && !strings.HasPrefix(task.Project, filter.Project+".") {
 		return false
 	}
 
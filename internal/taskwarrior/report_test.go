@@ -0,0 +1,60 @@
+package taskwarrior
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/dotbinio/taskwarrior-api/internal/tasktest"
+)
+
+func reportFixture(t *testing.T) *tasktest.Fake {
+	t.Helper()
+
+	fake := tasktest.Install(t, []map[string]any{
+		{"uuid": existingUUID, "description": "Write docs", "status": "pending", "entry": "20260101T090000Z"},
+	})
+	fake.SetShow("report.next.description=Most urgent tasks\nreport.next.filter=status:pending\n")
+	return fake
+}
+
+func TestExportReport(t *testing.T) {
+	fake := reportFixture(t)
+
+	tasks, err := NewClient(fake.DataLocation(), "").ExportReport(nil, "next")
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("got %v, %v", tasks, err)
+	}
+	calls := fake.CallsOf("export")
+	if len(calls) != 1 || !slices.Equal(calls[0], []string{"export", "next"}) {
+		t.Errorf("ran %q", calls)
+	}
+}
+
+// Only reports defined in the taskrc reach the command line, so a report
+// cannot override settings such as the data location
+func TestExportReportRejected(t *testing.T) {
+	for _, report := range []string{
+		"rc.data.location=/other/tenant",
+		"rc:/other/taskrc",
+		"next rc.data.location=/other/tenant",
+		"../next",
+		"undefined",
+	} {
+		t.Run(report, func(t *testing.T) {
+			fake := reportFixture(t)
+			client := NewClient(fake.DataLocation(), "")
+
+			if _, err := client.ExportReport(nil, report); !errors.Is(err, ErrUnknownReport) {
+				t.Errorf("ExportReport: got %v, want ErrUnknownReport", err)
+			}
+			err := client.ExportEach(nil, report, func(Task) error { return nil })
+			if !errors.Is(err, ErrUnknownReport) {
+				t.Errorf("ExportEach: got %v, want ErrUnknownReport", err)
+			}
+			if calls := fake.CallsOf("export"); len(calls) != 0 {
+				t.Errorf("exported with an unknown report: %q", calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package taskwarrior
+
+import (
+	"slices"
+	"strings"
+)
+
+// Restriction limits the tasks a token can see and change to a project
+// (including its subprojects) and to tasks carrying all of a set of tags.
+// The zero value allows every task.
+type Restriction struct {
+	Project string   `json:"project,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+// IsZero reports whether the restriction allows every task
+func (r Restriction) IsZero() bool {
+	return r.Project == "" && len(r.Tags) == 0
+}
+
+// Filters returns the Taskwarrior filter arguments implementing the
+// restriction
+func (r Restriction) Filters() []string {
+	var filters []string
+	if r.Project != "" {
+		filters = append(filters, "project:"+r.Project)
+	}
+	for _, tag := range r.Tags {
+		filters = append(filters, "+"+tag)
+	}
+	return filters
+}
+
+// Allows reports whether task is within the restriction
+func (r Restriction) Allows(task Task) bool {
+	return matchesFilter(task, TaskFilter{Project: r.Project, Tags: r.Tags})
+}
+
+// AllowsProject reports whether a task in project is within the project
+// part of the restriction
+func (r Restriction) AllowsProject(project string) bool {
+	return r.Project == "" || project == r.Project || strings.HasPrefix(project, r.Project+".")
+}
+
+// Confine places a new task inside the restriction. A missing project
+// defaults to the restricted project and the restricted tags are added.
+// It reports false if the task's project is outside the restriction.
+func (r Restriction) Confine(task *TaskCreate) bool {
+	if task.Project == "" {
+		task.Project = r.Project
+	}
+	if !r.AllowsProject(task.Project) {
+		return false
+	}
+
+	for _, tag := range r.Tags {
+		if !slices.Contains(task.Tags, tag) {
+			task.Tags = append(task.Tags, tag)
+		}
+	}
+	return true
+}
+
+// Apply removes tasks outside the restriction. Filters passed to the task
+// binary are the first line of defence; this guards against filter
+// arguments that Taskwarrior parses into something broader.
+func (r Restriction) Apply(tasks []Task) []Task {
+	if r.IsZero() {
+		return tasks
+	}
+	return FilterTasks(tasks, TaskFilter{Project: r.Project, Tags: r.Tags})
+}
@@ -0,0 +1,112 @@
+package taskwarrior
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExportEach passes each task of an export to fn as it is decoded, so
+// large exports are never held in memory. It stops at the first error
+// returned by fn.
+func (c *Client) ExportEach(filters []string, report string, fn func(Task) error) error {
+	// Plain exports are answered from the index, which is in memory anyway
+	if c.cache != nil && report == "" {
+		if filter, ok := parseSimpleFilter(filters); ok {
+			tasks, err := c.cache.Filter(filter)
+			if err != nil {
+				return err
+			}
+			for _, task := range tasks {
+				if err := fn(task); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	reportArgs, err := c.reportArgs(report)
+	if err != nil {
+		return err
+	}
+	args := append(append(append([]string{}, filters...), "export"), reportArgs...)
+
+	cmd := c.buildCommand(args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("task export failed: %w", err)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	var fnErr error
+	err = c.observe(cmd, func() error {
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		// Stop the export once the request it is for has gone
+		if c.ctx != nil {
+			stop := context.AfterFunc(c.ctx, func() { cmd.Process.Kill() })
+			defer stop()
+		}
+		decodeErr := decodeTasks(stdout, func(task Task) error {
+			if err := fn(task); err != nil {
+				fnErr = err
+				return err
+			}
+			return nil
+		})
+		if decodeErr != nil {
+			// Nobody reads the rest of the export
+			cmd.Process.Kill()
+		}
+		waitErr := cmd.Wait()
+		if decodeErr != nil {
+			return decodeErr
+		}
+		return waitErr
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		if stderr.Len() > 0 {
+			return fmt.Errorf("task export failed: %s", strings.TrimSpace(stderr.String()))
+		}
+		return fmt.Errorf("task export failed: %w", err)
+	}
+	return nil
+}
+
+// decodeTasks reads a JSON array of tasks one element at a time
+func decodeTasks(r io.Reader, fn func(Task) error) error {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse task export: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("failed to parse task export: expected an array")
+	}
+
+	for dec.More() {
+		var task Task
+		if err := dec.Decode(&task); err != nil {
+			return fmt.Errorf("failed to parse task export: %w", err)
+		}
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("failed to parse task export: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package taskwarrior
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/tasktest"
+)
+
+// An export is stopped once its request has gone, rather than read to
+// the end
+func TestExportEachStopsWithContext(t *testing.T) {
+	// Enough tasks that the export does not fit in the pipe
+	var tasks []map[string]any
+	for i := range 3000 {
+		tasks = append(tasks, map[string]any{
+			"uuid":        fmt.Sprintf("a360fc44-315c-4366-b70c-%012d", i),
+			"description": fmt.Sprintf("Task number %d with a description long enough to fill the pipe", i),
+			"status":      "pending",
+			"entry":       "20260101T090000Z",
+		})
+	}
+	fake := tasktest.Install(t, tasks)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := NewClient(fake.DataLocation(), "").WithContext(ctx)
+
+	read := 0
+	err := client.ExportEach(nil, "", func(Task) error {
+		if read == 0 {
+			cancel()
+			// Give the export time to be stopped
+			time.Sleep(100 * time.Millisecond)
+		}
+		read++
+		return nil
+	})
+	if err == nil || read == len(tasks) {
+		t.Errorf("read %d of %d tasks, error %v; want the export stopped", read, len(tasks), err)
+	}
+}
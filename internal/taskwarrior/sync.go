@@ -0,0 +1,83 @@
+package taskwarrior
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// SyncResult is the outcome of task sync. Uploaded and Downloaded are nil
+// when the release does not report them.
+type SyncResult struct {
+	Output     string
+	Stderr     string
+	Uploaded   *int
+	Downloaded *int
+}
+
+// Sync runs task sync. Taskwarrior 3 does not report how many changes it
+// transferred, so the tasks that changed are counted as downloaded.
+func (c *Client) Sync() (SyncResult, error) {
+	defer c.invalidateCache()
+
+	counted := c.compat().countsSync()
+	var before []Task
+	if !counted {
+		var err error
+		if before, err = c.exportCommand(nil, ""); err != nil {
+			return SyncResult{}, err
+		}
+	}
+
+	cmd := c.buildWriteCommand("sync")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := c.run(cmd)
+	result := SyncResult{
+		Output: strings.TrimSpace(stdout.String()),
+		Stderr: strings.TrimSpace(stderr.String()),
+	}
+	if err != nil {
+		return result, fmt.Errorf("task sync failed: %w", err)
+	}
+
+	if counted {
+		result.Uploaded, result.Downloaded = c.compat().parseSync(stdout.String() + stderr.String())
+		return result, nil
+	}
+
+	after, err := c.exportCommand(nil, "")
+	if err != nil {
+		return result, err
+	}
+	changed := countChanged(before, after)
+	result.Downloaded = &changed
+	return result, nil
+}
+
+// countChanged returns how many tasks were added or modified between two
+// exports
+func countChanged(before, after []Task) int {
+	previous := make(map[string]Task, len(before))
+	for _, task := range before {
+		previous[task.UUID] = task
+	}
+
+	changed := 0
+	for _, task := range after {
+		old, ok := previous[task.UUID]
+		if !ok || old.Status != task.Status || !sameTime(old.Modified, task.Modified) {
+			changed++
+		}
+	}
+	return changed
+}
+
+func sameTime(a, b *TaskwarriorTime) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Time.Equal(b.Time)
+}
@@ -5,6 +5,12 @@ import (
 	"time"
 )
 
+// TimeLayout is the layout of times in Taskwarrior's JSON, always in UTC
+const TimeLayout = "20060102T150405Z"
+
+// timeLayouts are the layouts TaskwarriorTime reads, in order
+var timeLayouts = []string{TimeLayout, "20060102T150405Z0700", time.RFC3339, "2006-01-02"}
+
 // TaskwarriorTime is a custom time type that handles Taskwarrior's datetime format
 type TaskwarriorTime struct {
 	time.Time
@@ -23,15 +29,18 @@ func (t *TaskwarriorTime) UnmarshalJSON(data []byte) error {
 		return nil
 	}
 
-	// Taskwarrior format: 20260101T131042Z
-	parsed, err := time.Parse("20060102T150405Z", s)
-	if err != nil {
-		// Try alternative format with timezone
-		parsed, err = time.Parse("20060102T150405Z0700", s)
-		if err != nil {
-			return err
+	// Taskwarrior writes 20260101T131042Z. The dates this API returns and
+	// RFC 3339 times are accepted too, so exports read back in.
+	var parsed time.Time
+	var err error
+	for _, layout := range timeLayouts {
+		if parsed, err = time.Parse(layout, s); err == nil {
+			break
 		}
 	}
+	if err != nil {
+		return err
+	}
 
 	t.Time = parsed
 	return nil
@@ -143,3 +152,11 @@ type ReportInfo struct {
 	Sort        string `json:"sort"`
 	Context     string `json:"context"`
 }
+
+// ContextInfo holds the filters of a Taskwarrior context
+type ContextInfo struct {
+	Name   string `json:"name"`
+	Read   string `json:"read"`
+	Write  string `json:"write"`
+	Active bool   `json:"active"`
+}
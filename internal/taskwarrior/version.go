@@ -0,0 +1,90 @@
+package taskwarrior
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Version is a Taskwarrior release
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// Supported Taskwarrior releases are 2.6.0 up to, but not including, 4.0.0
+var (
+	MinSupportedVersion = Version{2, 6, 0}
+	maxVersion          = Version{4, 0, 0}
+)
+
+// ParseVersion parses version output such as "3.1.0" or "2.6.2 (abc123)"
+func ParseVersion(s string) (Version, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return Version{}, fmt.Errorf("empty version")
+	}
+
+	parts := strings.SplitN(fields[0], ".", 3)
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		// Drop suffixes such as "-beta1"
+		if j := strings.IndexFunc(part, func(r rune) bool { return r < '0' || r > '9' }); j >= 0 {
+			part = part[:j]
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version %q", fields[0])
+		}
+		numbers[i] = n
+	}
+
+	return Version{numbers[0], numbers[1], numbers[2]}, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Less reports whether v is an earlier release than other
+func (v Version) Less(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor < other.Minor
+	}
+	return v.Patch < other.Patch
+}
+
+// Supported reports whether the API works with this release
+func (v Version) Supported() bool {
+	return !v.Less(MinSupportedVersion) && v.Less(maxVersion)
+}
+
+// Version runs task _version. Since the taskrc is loaded first, this also
+// fails when the taskrc cannot be parsed.
+func (c *Client) Version() (Version, error) {
+	output, err := c.output(c.buildCommand("_version"))
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return Version{}, fmt.Errorf("task _version failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return Version{}, fmt.Errorf("task _version failed: %w", err)
+	}
+
+	return ParseVersion(string(output))
+}
+
+// Diagnostics runs task diagnostics and returns its output
+func (c *Client) Diagnostics() (string, error) {
+	output, err := c.output(c.buildCommand("diagnostics"))
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("task diagnostics failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("task diagnostics failed: %w", err)
+	}
+
+	return string(output), nil
+}
@@ -0,0 +1,134 @@
+package taskwarrior
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fileState is the part of a file's metadata used to detect changes
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// FileWatcher polls the files in a Taskwarrior data location and notifies
+// listeners when any of them change. Polling is used instead of inotify so
+// that changes arriving through network mounts or file syncing are seen too.
+type FileWatcher struct {
+	dir       string
+	names     map[string]bool
+	interval  time.Duration
+	mu        sync.Mutex
+	listeners []func()
+	state     map[string]fileState
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewFileWatcher creates a watcher for dir that polls every interval.
+// Only the named files are watched, or all files if none are named.
+func NewFileWatcher(dir string, interval time.Duration, names ...string) *FileWatcher {
+	w := &FileWatcher{
+		dir:      dir,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if len(names) > 0 {
+		w.names = make(map[string]bool, len(names))
+		for _, name := range names {
+			w.names[name] = true
+		}
+	}
+	return w
+}
+
+// OnChange registers fn to be called after the data files have changed
+func (w *FileWatcher) OnChange(fn func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, fn)
+}
+
+// Start records the current state of the data files and begins polling
+func (w *FileWatcher) Start() {
+	w.state = w.scan()
+	go w.run()
+}
+
+// Stop stops polling
+func (w *FileWatcher) Stop() {
+	close(w.stop)
+	<-w.done
+}
+
+func (w *FileWatcher) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			current := w.scan()
+			if sameFiles(w.state, current) {
+				continue
+			}
+			w.state = current
+
+			w.mu.Lock()
+			listeners := append([]func(){}, w.listeners...)
+			w.mu.Unlock()
+			for _, fn := range listeners {
+				fn()
+			}
+		}
+	}
+}
+
+// scan returns the size and modification time of every watched regular
+// file in the data location
+func (w *FileWatcher) scan() map[string]fileState {
+	state := make(map[string]fileState)
+
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		slog.Error("Failed to read data location", "dir", w.dir, "error", err)
+		return state
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || (w.names != nil && !w.names[entry.Name()]) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		state[filepath.Join(w.dir, entry.Name())] = fileState{
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		}
+	}
+
+	return state
+}
+
+func sameFiles(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, sa := range a {
+		sb, ok := b[name]
+		if !ok || sa.size != sb.size || !sa.modTime.Equal(sb.modTime) {
+			return false
+		}
+	}
+	return true
+}
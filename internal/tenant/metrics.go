@@ -0,0 +1,68 @@
+package tenant
+
+import (
+	"github.com/dotbinio/taskwarrior-api/internal/metrics"
+)
+
+// RegisterMetrics reports the task cache, task counts and webhook queue of
+// every tenant. It must be called once.
+func (r *Registry) RegisterMetrics() {
+	tenantLabel := []string{"tenant"}
+
+	metrics.NewCounterFunc("taskwarrior_cache_hits_total",
+		"Queries answered from the task cache.", tenantLabel,
+		func(observe func(float64, ...string)) {
+			for _, t := range r.tenants {
+				if cache := t.Client.Cache(); cache != nil {
+					observe(float64(cache.Stats().Hits), t.Name)
+				}
+			}
+		})
+	metrics.NewCounterFunc("taskwarrior_cache_misses_total",
+		"Queries that had to reload the task cache.", tenantLabel,
+		func(observe func(float64, ...string)) {
+			for _, t := range r.tenants {
+				if cache := t.Client.Cache(); cache != nil {
+					observe(float64(cache.Stats().Misses), t.Name)
+				}
+			}
+		})
+	metrics.NewGaugeFunc("taskwarrior_cache_hit_ratio",
+		"Share of cached queries answered without reloading since startup.", tenantLabel,
+		func(observe func(float64, ...string)) {
+			for _, t := range r.tenants {
+				cache := t.Client.Cache()
+				if cache == nil {
+					continue
+				}
+				if stats := cache.Stats(); stats.Hits+stats.Misses > 0 {
+					observe(float64(stats.Hits)/float64(stats.Hits+stats.Misses), t.Name)
+				}
+			}
+		})
+
+	// Counted from the change watcher's snapshot, so scraping never runs
+	// task
+	metrics.NewGaugeFunc("taskwarrior_tasks",
+		"Pending tasks, and pending tasks that are started (active).", []string{"tenant", "status"},
+		func(observe func(float64, ...string)) {
+			for _, t := range r.tenants {
+				if t.changes == nil {
+					continue
+				}
+				pending, active := t.changes.Counts()
+				observe(float64(pending), t.Name, "pending")
+				observe(float64(active), t.Name, "active")
+			}
+		})
+
+	metrics.NewGaugeFunc("webhook_queue_depth",
+		"Webhook deliveries waiting for a worker.", tenantLabel,
+		func(observe func(float64, ...string)) {
+			for _, t := range r.tenants {
+				if t.Dispatcher != nil {
+					observe(float64(t.Dispatcher.QueueLength()), t.Name)
+				}
+			}
+		})
+}
@@ -0,0 +1,95 @@
+package tenant
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/dotbinio/taskwarrior-api/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// contextKey is the gin context key holding the request's tenant
+const contextKey = "tenant"
+
+// Registry holds all tenants by name
+type Registry struct {
+	tenants []*Tenant
+	byName  map[string]*Tenant
+}
+
+// NewRegistry creates and starts all configured tenants. TW_API_TOKENS and
+// the tokens file belong to the default tenant; tenants from the tenants
+// file get their own data location, taskrc and webhook store.
+func NewRegistry(cfg *config.Config) (*Registry, error) {
+	r := &Registry{
+		byName: make(map[string]*Tenant),
+	}
+
+	opts := Options{
+		WatchInterval: cfg.Taskwarrior.WatchInterval,
+		CacheEnabled:  cfg.Taskwarrior.CacheEnabled,
+		Webhooks:      cfg.Webhooks,
+		Sync:          cfg.Sync,
+	}
+	webhooksDir := config.ExpandPath(cfg.Webhooks.DataLocation)
+
+	if cfg.HasDefaultTenant() {
+		opts.WebhooksDir = webhooksDir
+		t, err := New(config.DefaultTenant, cfg.Taskwarrior.DataLocation, cfg.Taskwarrior.TaskrcLocation, opts)
+		if err != nil {
+			return nil, err
+		}
+		r.add(t)
+	}
+
+	for _, tc := range cfg.Tenants {
+		if err := provision(tc.DataLocation, tc.TaskrcLocation); err != nil {
+			r.Close()
+			return nil, fmt.Errorf("tenant %s: %w", tc.Name, err)
+		}
+
+		opts.WebhooksDir = filepath.Join(webhooksDir, "tenants", tc.Name)
+		t, err := New(tc.Name, tc.DataLocation, tc.TaskrcLocation, opts)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("tenant %s: %w", tc.Name, err)
+		}
+		r.add(t)
+	}
+
+	return r, nil
+}
+
+func (r *Registry) add(t *Tenant) {
+	r.tenants = append(r.tenants, t)
+	r.byName[t.Name] = t
+}
+
+// Tenants returns all tenants
+func (r *Registry) Tenants() []*Tenant {
+	return r.tenants
+}
+
+// Get returns the tenant with the given name
+func (r *Registry) Get(name string) (*Tenant, bool) {
+	t, ok := r.byName[name]
+	return t, ok
+}
+
+// Close stops all tenants
+func (r *Registry) Close() {
+	for _, t := range r.tenants {
+		t.Close()
+	}
+}
+
+// SetContext stores the request's tenant in the gin context
+func SetContext(c *gin.Context, t *Tenant) {
+	c.Set(contextKey, t)
+}
+
+// FromContext returns the request's tenant. It panics when no tenant has
+// been resolved, as that means a route is missing the tenant middleware.
+func FromContext(c *gin.Context) *Tenant {
+	return c.MustGet(contextKey).(*Tenant)
+}
@@ -0,0 +1,155 @@
+package tenant
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/config"
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+	"github.com/dotbinio/taskwarrior-api/internal/tasksync"
+	"github.com/dotbinio/taskwarrior-api/internal/taskwarrior"
+	"github.com/dotbinio/taskwarrior-api/internal/webhooks"
+)
+
+// Tenant is an isolated set of Taskwarrior data together with everything
+// built on top of it. Nothing is shared between tenants.
+type Tenant struct {
+	Name   string
+	Client *taskwarrior.Client
+	Bus    *events.Bus
+	// Dispatcher is nil when webhooks are disabled
+	Dispatcher *webhooks.Dispatcher
+	// Syncer is nil when sync is disabled
+	Syncer *tasksync.Syncer
+
+	files   *taskwarrior.FileWatcher
+	changes *events.ChangeWatcher
+	store   *webhooks.Store
+}
+
+// Options holds the settings shared by all tenants
+type Options struct {
+	WatchInterval time.Duration
+	CacheEnabled  bool
+	Webhooks      config.WebhooksConfig
+	Sync          config.SyncConfig
+	// WebhooksDir is where this tenant's webhooks are stored
+	WebhooksDir string
+}
+
+// New creates a tenant and starts its watchers, webhook dispatcher and
+// syncer
+func New(name, dataLocation, taskrcLocation string, opts Options) (*Tenant, error) {
+	t := &Tenant{
+		Name:   name,
+		Client: taskwarrior.NewClient(dataLocation, taskrcLocation),
+		Bus:    events.NewBus(1000),
+	}
+
+	// Detect the Taskwarrior release, which decides where tasks are stored
+	if version, err := t.Client.Detect(); err != nil {
+		slog.Warn("Failed to detect Taskwarrior version", "tenant", name, "error", err)
+	} else if !version.Supported() {
+		slog.Warn("Unsupported Taskwarrior version", "tenant", name, "version", version.String())
+	} else {
+		slog.Info("Detected Taskwarrior", "tenant", name, "version", version.String())
+	}
+
+	// Watch the data location for changes made outside the API
+	if opts.WatchInterval > 0 {
+		t.files = taskwarrior.NewFileWatcher(t.Client.DataLocation(), opts.WatchInterval, t.Client.DataFiles()...)
+
+		// Enable the cache first so it is invalidated before the change
+		// watcher exports the new state
+		if opts.CacheEnabled {
+			t.Client.EnableCache(t.files)
+		}
+
+		t.changes = events.NewChangeWatcher(t.Client, t.Bus)
+		if err := t.changes.Start(t.files); err != nil {
+			slog.Error("Failed to start change watcher, external changes will not be reported", "tenant", name, "error", err)
+			t.changes = nil
+		}
+		t.files.Start()
+	} else if opts.CacheEnabled {
+		slog.Warn("Task cache disabled because TW_WATCH_INTERVAL is 0", "tenant", name)
+	}
+
+	if opts.Webhooks.Enabled {
+		store, err := webhooks.NewStore(opts.WebhooksDir, 30*24*time.Hour)
+		if err != nil {
+			t.Close()
+			return nil, fmt.Errorf("failed to open webhook store: %w", err)
+		}
+		t.store = store
+
+		t.Dispatcher = webhooks.NewDispatcher(store, webhooks.Options{
+			MaxAttempts: opts.Webhooks.MaxAttempts,
+			Timeout:     opts.Webhooks.Timeout,
+		})
+		t.Dispatcher.Start(t.Bus)
+	}
+
+	if opts.Sync.Enabled {
+		t.Syncer = tasksync.New(t.Client, tasksync.Options{
+			Interval: opts.Sync.Interval,
+			OnChange: opts.Sync.OnChange,
+			Debounce: opts.Sync.Debounce,
+		})
+		t.Syncer.Start(t.Bus)
+	}
+
+	return t, nil
+}
+
+// APIClient returns the tenant's client for requests that publish their
+// changes to Bus, so that the change watcher does not report them again
+func (t *Tenant) APIClient() *taskwarrior.Client {
+	if t.changes == nil {
+		return t.Client
+	}
+	return t.Client.WithWriteObserver(t.changes.Expect)
+}
+
+// Close stops the tenant's syncer, watchers and webhook dispatcher
+func (t *Tenant) Close() {
+	if t.Syncer != nil {
+		t.Syncer.Stop()
+	}
+	if t.files != nil {
+		t.files.Stop()
+	}
+	if t.changes != nil {
+		t.changes.Stop()
+	}
+	if t.Dispatcher != nil {
+		t.Dispatcher.Stop()
+	}
+	if t.store != nil {
+		t.store.Close()
+	}
+}
+
+// provision creates a tenant's data location and an empty taskrc if they
+// do not exist yet, so that new tenants work without manual setup and
+// never fall back to the server user's own taskrc
+func provision(dataLocation, taskrcLocation string) error {
+	if err := os.MkdirAll(config.ExpandPath(dataLocation), 0700); err != nil {
+		return err
+	}
+
+	taskrc := config.ExpandPath(taskrcLocation)
+	if _, err := os.Stat(taskrc); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(taskrc), 0700); err != nil {
+			return err
+		}
+		return os.WriteFile(taskrc, nil, 0600)
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
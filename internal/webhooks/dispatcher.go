@@ -0,0 +1,422 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+)
+
+// Signature and metadata headers sent with every delivery
+const (
+	HeaderEvent     = "X-Taskwarrior-Event"
+	HeaderDelivery  = "X-Taskwarrior-Delivery"
+	HeaderTimestamp = "X-Taskwarrior-Timestamp"
+	HeaderSignature = "X-Taskwarrior-Signature"
+)
+
+// Options configures the dispatcher
+type Options struct {
+	MaxAttempts int
+	Timeout     time.Duration
+	Workers     int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// PruneInterval is how often deliveries past the store's retention
+	// are dropped
+	PruneInterval time.Duration
+}
+
+// Dispatcher delivers task events to registered webhooks with retries
+type Dispatcher struct {
+	store   *Store
+	opts    Options
+	client  *http.Client
+	queue   chan string
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	stopped bool
+	unsub   func()
+
+	// incoming holds published events until the recorder has created
+	// their deliveries, so publishing never waits for the store
+	incomingMu sync.Mutex
+	incoming   []events.Event
+	received   chan struct{}
+}
+
+// NewDispatcher creates a new dispatcher backed by store
+func NewDispatcher(store *Store, opts Options) *Dispatcher {
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 2
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = 5 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = time.Hour
+	}
+	if opts.PruneInterval <= 0 {
+		opts.PruneInterval = time.Hour
+	}
+
+	return &Dispatcher{
+		store:    store,
+		opts:     opts,
+		client:   &http.Client{Timeout: opts.Timeout},
+		queue:    make(chan string, 1024),
+		stop:     make(chan struct{}),
+		received: make(chan struct{}, 1),
+	}
+}
+
+// Start subscribes to the event bus, starts the delivery workers and
+// resumes deliveries that were pending when the server last stopped
+func (d *Dispatcher) Start(bus *events.Bus) {
+	for i := 0; i < d.opts.Workers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+	d.wg.Add(2)
+	go d.recorder()
+	go d.pruner()
+
+	d.unsub = bus.Subscribe(d.handleEvent)
+
+	for _, delivery := range d.store.PendingDeliveries() {
+		delay := time.Duration(0)
+		if delivery.NextAttemptAt != nil {
+			delay = time.Until(*delivery.NextAttemptAt)
+		}
+		d.schedule(delivery.ID, delay)
+	}
+}
+
+// Stop stops accepting events and waits for in-flight deliveries.
+// Events already received are recorded first, and deliveries that are
+// still pending are resumed on the next Start.
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return
+	}
+	d.stopped = true
+	d.mu.Unlock()
+
+	if d.unsub != nil {
+		d.unsub()
+	}
+	close(d.stop)
+	d.wg.Wait()
+}
+
+// Store returns the store backing the dispatcher
+func (d *Dispatcher) Store() *Store {
+	return d.store
+}
+
+// Redeliver queues a new delivery with the payload of an existing one
+func (d *Dispatcher) Redeliver(id string) (*Delivery, error) {
+	original, err := d.store.GetDelivery(id)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook, err := d.store.GetWebhook(original.WebhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	delivery := Delivery{
+		ID:           NewID(),
+		WebhookID:    webhook.ID,
+		EventID:      original.EventID,
+		EventType:    original.EventType,
+		URL:          webhook.URL,
+		Payload:      original.Payload,
+		Status:       DeliveryPending,
+		RedeliveryOf: original.ID,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := d.store.SaveDelivery(delivery); err != nil {
+		return nil, err
+	}
+
+	d.schedule(delivery.ID, 0)
+	return &delivery, nil
+}
+
+// handleEvent hands an event to the recorder. It runs inside
+// Bus.Publish, so it must not wait for the store.
+func (d *Dispatcher) handleEvent(event events.Event) {
+	d.incomingMu.Lock()
+	d.incoming = append(d.incoming, event)
+	d.incomingMu.Unlock()
+
+	select {
+	case d.received <- struct{}{}:
+	default:
+	}
+}
+
+// recorder records the deliveries of received events in event order
+func (d *Dispatcher) recorder() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.received:
+			d.recordIncoming()
+		case <-d.stop:
+			d.recordIncoming()
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) recordIncoming() {
+	d.incomingMu.Lock()
+	received := d.incoming
+	d.incoming = nil
+	d.incomingMu.Unlock()
+
+	for _, event := range received {
+		d.record(event)
+	}
+}
+
+// record creates a delivery for every active webhook subscribed to the
+// event type
+func (d *Dispatcher) record(event events.Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Webhooks: failed to encode event", "event", event.ID, "error", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, webhook := range d.store.ListWebhooks() {
+		if !webhook.Active || !webhook.Subscribes(event.Type) {
+			continue
+		}
+
+		delivery := Delivery{
+			ID:        NewID(),
+			WebhookID: webhook.ID,
+			EventID:   event.ID,
+			EventType: event.Type,
+			URL:       webhook.URL,
+			Payload:   payload,
+			Status:    DeliveryPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := d.store.SaveDelivery(delivery); err != nil {
+			slog.Error("Webhooks: failed to record delivery", "webhook", webhook.ID, "error", err)
+			continue
+		}
+
+		d.schedule(delivery.ID, 0)
+	}
+}
+
+// pruner drops deliveries past the store's retention while running
+func (d *Dispatcher) pruner() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.opts.PruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			if err := d.store.Prune(); err != nil {
+				slog.Error("Webhooks: failed to prune deliveries", "error", err)
+			}
+		}
+	}
+}
+
+// schedule queues a delivery after delay
+func (d *Dispatcher) schedule(id string, delay time.Duration) {
+	if delay <= 0 {
+		d.enqueue(id)
+		return
+	}
+	time.AfterFunc(delay, func() {
+		d.enqueue(id)
+	})
+}
+
+// QueueLength returns the number of deliveries waiting for a worker
+func (d *Dispatcher) QueueLength() int {
+	return len(d.queue)
+}
+
+func (d *Dispatcher) enqueue(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return
+	}
+
+	select {
+	case d.queue <- id:
+	default:
+		// Queue is full; try again later rather than blocking the caller
+		time.AfterFunc(d.opts.BaseBackoff, func() {
+			d.enqueue(id)
+		})
+	}
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case id := <-d.queue:
+			d.attempt(id)
+		}
+	}
+}
+
+// attempt performs a single delivery attempt and records the outcome
+func (d *Dispatcher) attempt(id string) {
+	delivery, err := d.store.GetDelivery(id)
+	if err != nil || delivery.Status != DeliveryPending {
+		return
+	}
+
+	webhook, err := d.store.GetWebhook(delivery.WebhookID)
+	if err != nil || !webhook.Active {
+		delivery.Status = DeliveryFailed
+		delivery.Error = "webhook was removed or deactivated"
+		delivery.NextAttemptAt = nil
+		delivery.UpdatedAt = time.Now().UTC()
+		d.save(delivery)
+		return
+	}
+
+	delivery.Attempts++
+	code, sendErr := d.send(webhook, delivery)
+	delivery.ResponseCode = code
+	delivery.UpdatedAt = time.Now().UTC()
+	delivery.NextAttemptAt = nil
+
+	switch {
+	case sendErr == nil:
+		delivery.Status = DeliverySucceeded
+		delivery.Error = ""
+	case delivery.Attempts >= d.opts.MaxAttempts:
+		delivery.Status = DeliveryFailed
+		delivery.Error = sendErr.Error()
+	default:
+		delivery.Error = sendErr.Error()
+		next := delivery.UpdatedAt.Add(d.backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+
+	d.save(delivery)
+
+	if delivery.Status == DeliveryPending {
+		d.schedule(delivery.ID, time.Until(*delivery.NextAttemptAt))
+	}
+}
+
+// send posts the payload to the webhook URL
+func (d *Dispatcher) send(webhook *Webhook, delivery *Delivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "taskwarrior-api-webhooks")
+	req.Header.Set(HeaderEvent, string(delivery.EventType))
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status: %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.opts.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.opts.MaxBackoff {
+			return d.opts.MaxBackoff
+		}
+	}
+	return delay
+}
+
+func (d *Dispatcher) save(delivery *Delivery) {
+	if err := d.store.SaveDelivery(*delivery); err != nil {
+		slog.Error("Webhooks: failed to record delivery", "delivery", delivery.ID, "error", err)
+	}
+}
+
+// Sign computes the signature header value for a payload. Receivers
+// recompute HMAC-SHA256 over "<timestamp>.<body>" with the shared secret.
+func Sign(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewID returns a random identifier
+func NewID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// NewSecret returns a random signing secret
+func NewSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,193 @@
+package webhooks
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+)
+
+// receiver is a webhook endpoint reporting the events it receives
+func receiver(t *testing.T) (*httptest.Server, chan string) {
+	t.Helper()
+
+	received := make(chan string, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		received <- r.Header.Get(HeaderEvent)
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func newStore(t *testing.T, dir string, maxDeliveryAge time.Duration) *Store {
+	t.Helper()
+
+	store, err := NewStore(dir, maxDeliveryAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func addWebhook(t *testing.T, store *Store, url string) Webhook {
+	t.Helper()
+
+	webhook := Webhook{
+		ID:        NewID(),
+		URL:       url,
+		Events:    []events.Type{events.TaskCreated},
+		Secret:    NewSecret(),
+		Active:    true,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := store.SaveWebhook(webhook); err != nil {
+		t.Fatal(err)
+	}
+	return webhook
+}
+
+func waitFor(t *testing.T, received chan string, want string) {
+	t.Helper()
+
+	select {
+	case got := <-received:
+		if got != want {
+			t.Errorf("received %s, want %s", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %s delivery arrived", want)
+	}
+}
+
+// Publishing must not wait for the store, as Bus.Publish runs in the
+// request that changed the task
+func TestPublishDoesNotWaitForStore(t *testing.T) {
+	server, received := receiver(t)
+	store := newStore(t, t.TempDir(), 0)
+	addWebhook(t, store, server.URL)
+
+	bus := events.NewBus(0)
+	dispatcher := NewDispatcher(store, Options{})
+	dispatcher.Start(bus)
+	t.Cleanup(dispatcher.Stop)
+
+	// Hold the store busy, as a slow disk would
+	store.mu.Lock()
+	published := make(chan struct{})
+	go func() {
+		bus.Publish(events.Event{Type: events.TaskCreated, TaskUUID: "a"})
+		bus.Publish(events.Event{Type: events.TaskCompleted, TaskUUID: "a"})
+		close(published)
+	}()
+	select {
+	case <-published:
+	case <-time.After(5 * time.Second):
+		store.mu.Unlock()
+		t.Fatal("Publish waited for the store")
+	}
+	store.mu.Unlock()
+
+	waitFor(t, received, string(events.TaskCreated))
+	if deliveries := store.ListDeliveries("", 0); len(deliveries) != 1 {
+		t.Errorf("recorded %d deliveries, want 1", len(deliveries))
+	}
+}
+
+// Events received before Stop are recorded, to be delivered after the
+// next Start
+func TestStopRecordsReceivedEvents(t *testing.T) {
+	dir := t.TempDir()
+	store := newStore(t, dir, 0)
+	addWebhook(t, store, "http://127.0.0.1:1/unreachable")
+
+	bus := events.NewBus(0)
+	dispatcher := NewDispatcher(store, Options{})
+	dispatcher.Start(bus)
+
+	store.mu.Lock()
+	bus.Publish(events.Event{Type: events.TaskCreated, TaskUUID: "a"})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		store.mu.Unlock()
+	}()
+	dispatcher.Stop()
+
+	if pending := store.PendingDeliveries(); len(pending) != 1 {
+		t.Errorf("%d pending deliveries after Stop, want 1", len(pending))
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	store := newStore(t, dir, time.Hour)
+
+	old := time.Now().UTC().Add(-2 * time.Hour)
+	recent := time.Now().UTC()
+	deliveries := []Delivery{
+		{ID: "old-succeeded", Status: DeliverySucceeded, CreatedAt: old, UpdatedAt: old},
+		{ID: "old-failed", Status: DeliveryFailed, CreatedAt: old, UpdatedAt: old},
+		{ID: "old-pending", Status: DeliveryPending, CreatedAt: old, UpdatedAt: old},
+		{ID: "recent", Status: DeliverySucceeded, CreatedAt: recent, UpdatedAt: recent},
+	}
+	for _, d := range deliveries {
+		if err := store.SaveDelivery(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	check := func(store *Store) {
+		t.Helper()
+		for _, d := range deliveries {
+			_, err := store.GetDelivery(d.ID)
+			expired := d.Status != DeliveryPending && d.UpdatedAt.Equal(old)
+			if expired != errors.Is(err, ErrDeliveryNotFound) {
+				t.Errorf("%s: got %v, expired %v", d.ID, err, expired)
+			}
+		}
+	}
+	check(store)
+
+	// The log keeps working after it was rewritten
+	if err := store.SaveDelivery(Delivery{ID: "after", Status: DeliveryPending, CreatedAt: recent, UpdatedAt: recent}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	reopened := newStore(t, dir, 0)
+	check(reopened)
+	if _, err := reopened.GetDelivery("after"); err != nil {
+		t.Errorf("delivery saved after pruning: %v", err)
+	}
+}
+
+func TestDispatcherPrunes(t *testing.T) {
+	store := newStore(t, t.TempDir(), time.Hour)
+	old := time.Now().UTC().Add(-2 * time.Hour)
+	if err := store.SaveDelivery(Delivery{ID: "old", Status: DeliverySucceeded, CreatedAt: old, UpdatedAt: old}); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := NewDispatcher(store, Options{PruneInterval: 10 * time.Millisecond})
+	dispatcher.Start(events.NewBus(0))
+	t.Cleanup(dispatcher.Stop)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := store.GetDelivery("old"); errors.Is(err, ErrDeliveryNotFound) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the expired delivery was not pruned")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
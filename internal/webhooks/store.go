@@ -0,0 +1,373 @@
+package webhooks
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dotbinio/taskwarrior-api/internal/events"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+// Delivery status constants
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is a registered endpoint that receives task events
+type Webhook struct {
+	ID        string        `json:"id"`
+	URL       string        `json:"url"`
+	Events    []events.Type `json:"events"`
+	Secret    string        `json:"secret,omitempty"`
+	Active    bool          `json:"active"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// Subscribes checks if the webhook wants events of type t
+func (w *Webhook) Subscribes(t events.Type) bool {
+	for _, e := range w.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery records one attempt to send an event to a webhook
+type Delivery struct {
+	ID            string          `json:"id"`
+	WebhookID     string          `json:"webhook_id"`
+	EventID       uint64          `json:"event_id"`
+	EventType     events.Type     `json:"event_type"`
+	URL           string          `json:"url"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	ResponseCode  int             `json:"response_code,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	RedeliveryOf  string          `json:"redelivery_of,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"`
+}
+
+// Store persists webhooks and their delivery log in a directory.
+// Webhooks are kept in webhooks.json and deliveries are appended to
+// deliveries.jsonl, where the last line for a delivery ID wins.
+type Store struct {
+	mu             sync.RWMutex
+	dir            string
+	webhooks       map[string]*Webhook
+	deliveries     map[string]*Delivery
+	deliveryLog    *os.File
+	maxDeliveryAge time.Duration
+}
+
+// NewStore opens or creates a webhook store in dir. Finished deliveries
+// older than maxDeliveryAge are dropped when the log is compacted, on
+// startup and on every Prune.
+func NewStore(dir string, maxDeliveryAge time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create webhook directory: %w", err)
+	}
+
+	s := &Store{
+		dir:            dir,
+		webhooks:       make(map[string]*Webhook),
+		deliveries:     make(map[string]*Delivery),
+		maxDeliveryAge: maxDeliveryAge,
+	}
+
+	if err := s.loadWebhooks(); err != nil {
+		return nil, err
+	}
+	if err := s.loadDeliveries(); err != nil {
+		return nil, err
+	}
+	if err := s.compactDeliveries(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Close closes the delivery log
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.deliveryLog == nil {
+		return nil
+	}
+	err := s.deliveryLog.Close()
+	s.deliveryLog = nil
+	return err
+}
+
+// ListWebhooks returns all webhooks sorted by creation time
+func (s *Store) ListWebhooks() []Webhook {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Webhook, 0, len(s.webhooks))
+	for _, w := range s.webhooks {
+		result = append(result, *w)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result
+}
+
+// GetWebhook returns a webhook by ID
+func (s *Store) GetWebhook(id string) (*Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	w, ok := s.webhooks[id]
+	if !ok {
+		return nil, ErrWebhookNotFound
+	}
+	webhook := *w
+	return &webhook, nil
+}
+
+// SaveWebhook creates or replaces a webhook
+func (s *Store) SaveWebhook(w Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.webhooks[w.ID] = &w
+	return s.writeWebhooks()
+}
+
+// DeleteWebhook removes a webhook
+func (s *Store) DeleteWebhook(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.webhooks[id]; !ok {
+		return ErrWebhookNotFound
+	}
+	delete(s.webhooks, id)
+	return s.writeWebhooks()
+}
+
+// ListDeliveries returns deliveries, newest first, optionally restricted
+// to a single webhook
+func (s *Store) ListDeliveries(webhookID string, limit int) []Delivery {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Delivery, 0)
+	for _, d := range s.deliveries {
+		if webhookID != "" && d.WebhookID != webhookID {
+			continue
+		}
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// GetDelivery returns a delivery by ID
+func (s *Store) GetDelivery(id string) (*Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	d, ok := s.deliveries[id]
+	if !ok {
+		return nil, ErrDeliveryNotFound
+	}
+	delivery := *d
+	return &delivery, nil
+}
+
+// PendingDeliveries returns deliveries that have not finished yet
+func (s *Store) PendingDeliveries() []Delivery {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Delivery, 0)
+	for _, d := range s.deliveries {
+		if d.Status == DeliveryPending {
+			result = append(result, *d)
+		}
+	}
+	return result
+}
+
+// SaveDelivery appends the current state of a delivery to the log
+func (s *Store) SaveDelivery(d Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deliveries[d.ID] = &d
+	return s.appendDelivery(&d)
+}
+
+// Prune drops finished deliveries older than the retention and rewrites
+// the delivery log without them
+func (s *Store) Prune() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.deliveryLog == nil {
+		return fmt.Errorf("delivery log is closed")
+	}
+	return s.compactDeliveries()
+}
+
+func (s *Store) webhooksPath() string {
+	return filepath.Join(s.dir, "webhooks.json")
+}
+
+func (s *Store) deliveriesPath() string {
+	return filepath.Join(s.dir, "deliveries.jsonl")
+}
+
+func (s *Store) loadWebhooks() error {
+	data, err := os.ReadFile(s.webhooksPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read webhooks: %w", err)
+	}
+
+	var list []Webhook
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("failed to parse webhooks: %w", err)
+	}
+	for i := range list {
+		s.webhooks[list[i].ID] = &list[i]
+	}
+
+	return nil
+}
+
+// writeWebhooks atomically rewrites webhooks.json
+func (s *Store) writeWebhooks() error {
+	list := make([]Webhook, 0, len(s.webhooks))
+	for _, w := range s.webhooks {
+		list = append(list, *w)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.webhooksPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write webhooks: %w", err)
+	}
+	return os.Rename(tmp, s.webhooksPath())
+}
+
+func (s *Store) loadDeliveries() error {
+	f, err := os.Open(s.deliveriesPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open delivery log: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var d Delivery
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			// Skip a torn trailing line from an unclean shutdown
+			continue
+		}
+		s.deliveries[d.ID] = &d
+	}
+
+	return scanner.Err()
+}
+
+// compactDeliveries drops expired deliveries and rewrites the log with
+// one line per delivery
+func (s *Store) compactDeliveries() error {
+	cutoff := time.Now().Add(-s.maxDeliveryAge)
+	list := make([]*Delivery, 0, len(s.deliveries))
+	for id, d := range s.deliveries {
+		if s.maxDeliveryAge > 0 && d.Status != DeliveryPending && d.UpdatedAt.Before(cutoff) {
+			delete(s.deliveries, id)
+			continue
+		}
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+
+	tmp := s.deliveriesPath() + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to compact delivery log: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	for _, d := range list {
+		if err := enc.Encode(d); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to compact delivery log: %w", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.deliveriesPath()); err != nil {
+		return err
+	}
+
+	log, err := os.OpenFile(s.deliveriesPath(), os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if s.deliveryLog != nil {
+		s.deliveryLog.Close()
+	}
+	s.deliveryLog = log
+	return nil
+}
+
+func (s *Store) appendDelivery(d *Delivery) error {
+	if s.deliveryLog == nil {
+		return fmt.Errorf("delivery log is closed")
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	_, err = s.deliveryLog.Write(append(data, '\n'))
+	return err
+}